@@ -0,0 +1,523 @@
+package cwlgo
+
+import (
+	"crypto/sha1"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"io"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"time"
+)
+
+// cacheEntryFile is the file in each cache entry describing the job's result
+const cacheEntryFile = "entry.json"
+
+// CallCache stores the outputs of finished jobs in a directory, keyed by
+// everything that determines them, so that identical jobs can reuse them
+// instead of running again
+type CallCache struct {
+	Dir string
+}
+
+// cacheEntry is the stored result of a job. Paths are relative to the job's
+// output directory.
+type cacheEntry struct {
+	Key             string            `json:"key"`
+	ExitCode        int               `json:"exitCode"`
+	Stdout          string            `json:"stdout,omitempty"`
+	Stderr          string            `json:"stderr,omitempty"`
+	StdoutPath      string            `json:"stdoutPath,omitempty"`
+	StderrPath      string            `json:"stderrPath,omitempty"`
+	StdoutTruncated bool              `json:"stdoutTruncated,omitempty"`
+	StderrTruncated bool              `json:"stderrTruncated,omitempty"`
+	OutputFiles     map[string]string `json:"outputFiles"`
+}
+
+// cacheKeyData is what a cache key is computed from
+type cacheKeyData struct {
+	Tool        *CommandLineTool `json:"tool"`
+	CommandLine []string         `json:"commandLine"`
+	Image       []string         `json:"image,omitempty"`
+	Stdin       string           `json:"stdin,omitempty"`
+	Stdout      string           `json:"stdout,omitempty"`
+	Stderr      string           `json:"stderr,omitempty"`
+	Inputs      []string         `json:"inputs"`
+}
+
+// NewCallCache creates a call cache in the given directory
+func NewCallCache(dir string) (*CallCache, error) {
+	if err := os.MkdirAll(dir, 0755); err != nil {
+		return nil, &CWLError{Err: err, Message: fmt.Sprintf("failed to create cache directory: %s", dir)}
+	}
+	return &CallCache{Dir: dir}, nil
+}
+
+// Key returns the cache key of a job: a hash of the tool's canonical form,
+// its resolved command line, its container image and the checksums of its
+// input Files, including the Files of defaults it uses. Paths of the inputs
+// and of the job's directories are replaced so that the same job run from
+// another directory has the same key.
+func (c *CallCache) Key(tool *CommandLineTool, cmdArgs []string, execCtx *ExecutionContext) (string, error) {
+	data := cacheKeyData{Tool: tool}
+
+	// Each input is known by the checksum of its contents, whether it
+	// appears as a host or a container path
+	var replacements [][2]string
+	for _, path := range collectPaths(jobInputs(tool, execCtx)) {
+		hostPath := execCtx.HostPath(path)
+		checksum, err := pathChecksum(hostPath)
+		if err != nil {
+			return "", &CWLError{Err: err, Message: fmt.Sprintf("failed to compute checksum of input %s", hostPath)}
+		}
+		data.Inputs = append(data.Inputs, checksum+" "+filepath.Base(path))
+		replacements = append(replacements, [2]string{path, checksum}, [2]string{hostPath, checksum})
+	}
+	for _, dir := range []struct{ path, name string }{
+		{execCtx.OutputDir, "$(runtime.outdir)"},
+		{execCtx.TempDir, "$(runtime.tmpdir)"},
+	} {
+		if dir.path == "" {
+			continue
+		}
+		replacements = append(replacements,
+			[2]string{dir.path, dir.name},
+			[2]string{execCtx.ContainerPath(dir.path), dir.name})
+	}
+	sort.Strings(data.Inputs)
+	replacer := newPathReplacer(replacements)
+
+	for _, arg := range cmdArgs {
+		data.CommandLine = append(data.CommandLine, replacer.Replace(arg))
+	}
+	data.Stdin = replacer.Replace(execCtx.Stdin)
+	data.Stdout = execCtx.Stdout
+	data.Stderr = execCtx.Stderr
+
+	if config := execCtx.Container; config != nil {
+		data.Image = []string{config.Image, config.ImageID, config.Load, config.File, config.Import}
+	}
+
+	encoded, err := json.Marshal(data)
+	if err != nil {
+		return "", &CWLError{Err: err, Message: "failed to encode cache key"}
+	}
+	sum := sha256.Sum256(encoded)
+	return hex.EncodeToString(sum[:]), nil
+}
+
+// jobInputs returns the inputs of a job with the defaults of inputs not
+// given, so that the contents of default Files are part of the key.
+// Defaults outside the local filesystem are left to the tool's canonical
+// form, which holds their location.
+func jobInputs(tool *CommandLineTool, execCtx *ExecutionContext) map[string]interface{} {
+	inputs := make(map[string]interface{}, len(tool.Inputs))
+	for inputID, value := range execCtx.Inputs {
+		inputs[inputID] = value
+	}
+	for inputID, inputParam := range tool.Inputs {
+		if _, ok := inputs[inputID]; ok || inputParam.Default == nil {
+			continue
+		}
+		if localFiles(inputParam.Default) {
+			inputs[inputID] = fillFileProperties(inputParam.Default)
+		}
+	}
+	return inputs
+}
+
+// localFiles returns whether every File and Directory in a value has a
+// local path or a file:// location
+func localFiles(value interface{}) bool {
+	switch v := value.(type) {
+	case map[string]interface{}:
+		if class, _ := v["class"].(string); class == "File" || class == "Directory" {
+			if path, _ := v["path"].(string); path != "" {
+				return true
+			}
+			location, _ := v["location"].(string)
+			return strings.HasPrefix(location, "file://")
+		}
+		for _, item := range v {
+			if !localFiles(item) {
+				return false
+			}
+		}
+	case []interface{}:
+		for _, item := range v {
+			if !localFiles(item) {
+				return false
+			}
+		}
+	}
+	return true
+}
+
+// newPathReplacer returns a replacer for the given old/new pairs that
+// prefers the longest match, so that nested paths are replaced whole
+func newPathReplacer(pairs [][2]string) *strings.Replacer {
+	sort.SliceStable(pairs, func(i, j int) bool {
+		return len(pairs[i][0]) > len(pairs[j][0])
+	})
+	var oldnew []string
+	for _, pair := range pairs {
+		if pair[0] != "" {
+			oldnew = append(oldnew, pair[0], pair[1])
+		}
+	}
+	return strings.NewReplacer(oldnew...)
+}
+
+// collectPaths returns the paths of the Files and Directories in a value,
+// sorted
+func collectPaths(value interface{}) []string {
+	seen := make(map[string]bool)
+	var walk func(value interface{})
+	walk = func(value interface{}) {
+		switch v := value.(type) {
+		case map[string]interface{}:
+			if class, _ := v["class"].(string); class == "File" || class == "Directory" {
+				if path, ok := v["path"].(string); ok && path != "" {
+					seen[path] = true
+				}
+				return
+			}
+			for _, item := range v {
+				walk(item)
+			}
+		case []interface{}:
+			for _, item := range v {
+				walk(item)
+			}
+		}
+	}
+	walk(value)
+
+	paths := make([]string, 0, len(seen))
+	for path := range seen {
+		paths = append(paths, path)
+	}
+	sort.Strings(paths)
+	return paths
+}
+
+// pathChecksum returns the SHA-1 checksum of a File, in CWL's "sha1$..."
+// form, or of a Directory's names and contents
+func pathChecksum(path string) (string, error) {
+	info, err := os.Stat(path)
+	if err != nil {
+		return "", err
+	}
+
+	hash := sha1.New()
+	if !info.IsDir() {
+		if err := hashFile(hash, path); err != nil {
+			return "", err
+		}
+		return "sha1$" + hex.EncodeToString(hash.Sum(nil)), nil
+	}
+
+	// WalkDir visits entries in lexical order
+	err = filepath.WalkDir(path, func(entry string, d fs.DirEntry, err error) error {
+		if err != nil {
+			return err
+		}
+		rel, err := filepath.Rel(path, entry)
+		if err != nil {
+			return err
+		}
+		fmt.Fprintf(hash, "%s\x00", filepath.ToSlash(rel))
+		if d.Type().IsRegular() {
+			return hashFile(hash, entry)
+		}
+		return nil
+	})
+	if err != nil {
+		return "", err
+	}
+	return "sha1$" + hex.EncodeToString(hash.Sum(nil)), nil
+}
+
+// hashFile writes the contents of a file to a hash
+func hashFile(hash io.Writer, path string) error {
+	file, err := os.Open(path)
+	if err != nil {
+		return err
+	}
+	defer file.Close()
+
+	_, err = io.Copy(hash, file)
+	return err
+}
+
+// entryDir returns the directory of the cache entry with the given key
+func (c *CallCache) entryDir(key string) string {
+	return filepath.Join(c.Dir, key[:2], key)
+}
+
+// Restore copies the stored outputs of a job into its output directory and
+// returns its result, or false if the cache has no entry for the key
+func (c *CallCache) Restore(key string, execCtx *ExecutionContext) (*ExecuteResult, bool) {
+	dir := c.entryDir(key)
+	data, err := os.ReadFile(filepath.Join(dir, cacheEntryFile))
+	if err != nil {
+		return nil, false
+	}
+	var entry cacheEntry
+	if err := json.Unmarshal(data, &entry); err != nil || entry.Key != key {
+		return nil, false
+	}
+
+	restore := func(rel string) (string, error) {
+		if rel == "" {
+			return "", nil
+		}
+		target := filepath.Join(execCtx.OutputDir, rel)
+		if err := CopyPath(filepath.Join(dir, "files", rel), target); err != nil {
+			return "", err
+		}
+		return target, nil
+	}
+
+	result := &ExecuteResult{
+		ExitCode:        entry.ExitCode,
+		Stdout:          entry.Stdout,
+		Stderr:          entry.Stderr,
+		StdoutTruncated: entry.StdoutTruncated,
+		StderrTruncated: entry.StderrTruncated,
+		OutputFiles:     make(map[string]string, len(entry.OutputFiles)),
+		Cached:          true,
+	}
+	if result.StdoutPath, err = restore(entry.StdoutPath); err != nil {
+		return nil, false
+	}
+	if result.StderrPath, err = restore(entry.StderrPath); err != nil {
+		return nil, false
+	}
+	for outputID, rel := range entry.OutputFiles {
+		if result.OutputFiles[outputID], err = restore(rel); err != nil {
+			return nil, false
+		}
+	}
+
+	// Entries are pruned by when they were last used
+	now := time.Now()
+	os.Chtimes(filepath.Join(dir, cacheEntryFile), now, now)
+
+	return result, true
+}
+
+// Store copies the outputs of a finished job into the cache. Only outputs
+// inside the job's output directory can be stored.
+func (c *CallCache) Store(key string, result *ExecuteResult, execCtx *ExecutionContext) error {
+	entry := cacheEntry{
+		Key:             key,
+		ExitCode:        result.ExitCode,
+		Stdout:          result.Stdout,
+		Stderr:          result.Stderr,
+		StdoutTruncated: result.StdoutTruncated,
+		StderrTruncated: result.StderrTruncated,
+		OutputFiles:     make(map[string]string, len(result.OutputFiles)),
+	}
+
+	// Build the entry in a temporary directory so that it appears whole
+	tmpDir, err := os.MkdirTemp(c.Dir, "tmp-")
+	if err != nil {
+		return &CWLError{Err: err, Message: "failed to create cache entry"}
+	}
+	defer os.RemoveAll(tmpDir)
+
+	store := func(path string) (string, error) {
+		if path == "" {
+			return "", nil
+		}
+		rel, err := filepath.Rel(execCtx.OutputDir, path)
+		if err != nil || rel == ".." || strings.HasPrefix(rel, ".."+string(filepath.Separator)) {
+			return "", &CWLError{Err: ErrExecution, Message: fmt.Sprintf("output %s is outside the output directory", path)}
+		}
+		if err := CopyPath(path, filepath.Join(tmpDir, "files", rel)); err != nil {
+			return "", &CWLError{Err: err, Message: fmt.Sprintf("failed to store output %s", path)}
+		}
+		return rel, nil
+	}
+
+	if entry.StdoutPath, err = store(result.StdoutPath); err != nil {
+		return err
+	}
+	if entry.StderrPath, err = store(result.StderrPath); err != nil {
+		return err
+	}
+	for outputID, path := range result.OutputFiles {
+		if entry.OutputFiles[outputID], err = store(path); err != nil {
+			return err
+		}
+	}
+
+	data, err := json.MarshalIndent(entry, "", "  ")
+	if err != nil {
+		return &CWLError{Err: err, Message: "failed to encode cache entry"}
+	}
+	if err := os.WriteFile(filepath.Join(tmpDir, cacheEntryFile), data, 0644); err != nil {
+		return &CWLError{Err: err, Message: "failed to write cache entry"}
+	}
+
+	dir := c.entryDir(key)
+	if err := os.MkdirAll(filepath.Dir(dir), 0755); err != nil {
+		return &CWLError{Err: err, Message: "failed to create cache entry"}
+	}
+	if err := os.Rename(tmpDir, dir); err != nil {
+		if _, statErr := os.Stat(dir); statErr == nil {
+			// An identical job stored its outputs first
+			return nil
+		}
+		return &CWLError{Err: err, Message: "failed to store cache entry"}
+	}
+	return nil
+}
+
+// Prune removes cache entries not used for longer than maxAge, then the
+// least recently used entries until the cache is at most maxSize bytes. A
+// zero maxAge or maxSize disables that limit.
+func (c *CallCache) Prune(maxAge time.Duration, maxSize int64) error {
+	type cachedEntry struct {
+		dir      string
+		lastUsed time.Time
+		size     int64
+	}
+
+	dirs, err := filepath.Glob(filepath.Join(c.Dir, "*", "*", cacheEntryFile))
+	if err != nil {
+		return &CWLError{Err: err, Message: "failed to list cache entries"}
+	}
+
+	var entries []cachedEntry
+	var total int64
+	for _, path := range dirs {
+		info, err := os.Stat(path)
+		if err != nil {
+			continue
+		}
+		entry := cachedEntry{dir: filepath.Dir(path), lastUsed: info.ModTime()}
+		filepath.WalkDir(entry.dir, func(_ string, d fs.DirEntry, err error) error {
+			if err == nil && d.Type().IsRegular() {
+				if info, err := d.Info(); err == nil {
+					entry.size += info.Size()
+				}
+			}
+			return nil
+		})
+		entries = append(entries, entry)
+		total += entry.size
+	}
+
+	// Oldest first
+	sort.Slice(entries, func(i, j int) bool {
+		return entries[i].lastUsed.Before(entries[j].lastUsed)
+	})
+
+	for _, entry := range entries {
+		expired := maxAge > 0 && time.Since(entry.lastUsed) > maxAge
+		oversized := maxSize > 0 && total > maxSize
+		if !expired && !oversized {
+			break
+		}
+		if err := os.RemoveAll(entry.dir); err != nil {
+			return &CWLError{Err: err, Message: fmt.Sprintf("failed to remove cache entry %s", entry.dir)}
+		}
+		total -= entry.size
+	}
+	return nil
+}
+
+// CopyPath copies a file or directory tree, creating the target's parent
+// directories
+func CopyPath(src, dst string) error {
+	return filepath.WalkDir(src, func(path string, d fs.DirEntry, err error) error {
+		if err != nil {
+			return err
+		}
+		rel, err := filepath.Rel(src, path)
+		if err != nil {
+			return err
+		}
+		target := filepath.Join(dst, rel)
+
+		if d.IsDir() {
+			return os.MkdirAll(target, 0755)
+		}
+		if err := os.MkdirAll(filepath.Dir(target), 0755); err != nil {
+			return err
+		}
+
+		info, err := d.Info()
+		if err != nil {
+			return err
+		}
+		in, err := os.Open(path)
+		if err != nil {
+			return err
+		}
+		defer in.Close()
+
+		out, err := os.OpenFile(target, os.O_WRONLY|os.O_CREATE|os.O_TRUNC, info.Mode().Perm())
+		if err != nil {
+			return err
+		}
+		if _, err := io.Copy(out, in); err != nil {
+			out.Close()
+			return err
+		}
+		return out.Close()
+	})
+}
+
+// workReuseEnabled returns whether a tool's outputs may be reused, which
+// a WorkReuse requirement, or hint when there is no requirement, with
+// enableReuse false (or an expression yielding false) turns off
+func workReuseEnabled(tool *CommandLineTool, execCtx *ExecutionContext) (bool, error) {
+	var workReuse map[string]interface{}
+	for _, reqMap := range tool.Requirements {
+		if reqMap["class"] == "WorkReuse" {
+			workReuse = reqMap
+		}
+	}
+	if workReuse == nil {
+		for _, hint := range tool.Hints {
+			if hint["class"] == "WorkReuse" {
+				workReuse = hint
+			}
+		}
+	}
+	if workReuse == nil {
+		return true, nil
+	}
+
+	value, ok := workReuse["enableReuse"]
+	if !ok {
+		return true, nil
+	}
+	if expr, ok := value.(string); ok {
+		evaluated, err := newExpressionContext(tool, execCtx).EvaluateString(expr)
+		if err != nil {
+			return false, &CWLError{
+				Err:     err,
+				Message: "failed to evaluate enableReuse",
+			}
+		}
+		value = evaluated
+	}
+
+	enabled, ok := value.(bool)
+	if !ok {
+		return false, &CWLError{
+			Err:     ErrExecution,
+			Message: fmt.Sprintf("enableReuse must be a boolean, got %v", value),
+		}
+	}
+	return enabled, nil
+}
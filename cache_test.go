@@ -0,0 +1,217 @@
+package cwlgo
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+// countingTool records each run in the counter file and prints its input
+func countingTool() *CommandLineTool {
+	return &CommandLineTool{
+		CWLVersion:  "v1.2",
+		Class:       "CommandLineTool",
+		BaseCommand: []interface{}{"sh", "-c", `echo run >> "$0"; cat "$1"`},
+		Inputs: map[string]CommandInputParameter{
+			"counter": {Type: "string", Binding: &CommandLineBinding{Position: 1}},
+			"file":    {Type: "File", Binding: &CommandLineBinding{Position: 2}},
+		},
+		Outputs: map[string]CommandOutputParameter{
+			"output": {Type: "stdout"},
+		},
+		Stdout: "output.txt",
+	}
+}
+
+func TestCallCache(t *testing.T) {
+	dir := t.TempDir()
+	counter := filepath.Join(dir, "runs")
+	input := filepath.Join(dir, "input.txt")
+	if err := os.WriteFile(input, []byte("hello"), 0644); err != nil {
+		t.Fatalf("Failed to write input: %v", err)
+	}
+
+	cache, err := NewCallCache(filepath.Join(dir, "cache"))
+	if err != nil {
+		t.Fatalf("Failed to create cache: %v", err)
+	}
+	executor := NewExecutor()
+	executor.Cache = cache
+
+	// Each run uses a fresh job directory, as workflow steps do
+	run := func(tool *CommandLineTool) *ExecuteResult {
+		execCtx, err := NewExecutionContext(t.TempDir())
+		if err != nil {
+			t.Fatalf("Failed to create execution context: %v", err)
+		}
+		defer execCtx.Cleanup()
+
+		result, err := executor.execute(context.Background(), tool, map[string]interface{}{
+			"counter": counter,
+			"file":    map[string]interface{}{"class": "File", "path": input},
+		}, execCtx)
+		if err != nil {
+			t.Fatalf("Failed to execute tool: %v", err)
+		}
+		return result
+	}
+	runs := func() int {
+		data, _ := os.ReadFile(counter)
+		return strings.Count(string(data), "run")
+	}
+
+	first := run(countingTool())
+	second := run(countingTool())
+	if first.Cached || !second.Cached {
+		t.Errorf("Expected only the second run to be cached, got %v and %v", first.Cached, second.Cached)
+	}
+	if runs() != 1 {
+		t.Errorf("Expected the tool to run once, got %d", runs())
+	}
+
+	output, ok := second.Outputs["output"].(map[string]interface{})
+	if !ok {
+		t.Fatalf("Expected output File, got %v", second.Outputs["output"])
+	}
+	data, err := os.ReadFile(output["path"].(string))
+	if err != nil {
+		t.Fatalf("Failed to read cached output: %v", err)
+	}
+	if string(data) != "hello" {
+		t.Errorf("Expected cached output %q, got %q", "hello", data)
+	}
+
+	// Changed input contents are a different job
+	if err := os.WriteFile(input, []byte("changed"), 0644); err != nil {
+		t.Fatalf("Failed to write input: %v", err)
+	}
+	if run(countingTool()).Cached || runs() != 2 {
+		t.Errorf("Expected changed input to run the tool, got %d runs", runs())
+	}
+
+	// WorkReuse turns reuse off
+	tool := countingTool()
+	tool.Requirements = []map[string]interface{}{{"class": "WorkReuse", "enableReuse": false}}
+	run(tool)
+	if run(tool).Cached || runs() != 4 {
+		t.Errorf("Expected WorkReuse to disable the cache, got %d runs", runs())
+	}
+
+	// So does a WorkReuse hint
+	tool = countingTool()
+	tool.Hints = []map[string]interface{}{{"class": "WorkReuse", "enableReuse": false}}
+	run(tool)
+	if run(tool).Cached || runs() != 6 {
+		t.Errorf("Expected a WorkReuse hint to disable the cache, got %d runs", runs())
+	}
+}
+
+func TestCallCachePrune(t *testing.T) {
+	cache, err := NewCallCache(t.TempDir())
+	if err != nil {
+		t.Fatalf("Failed to create cache: %v", err)
+	}
+
+	// Store three entries last used 3, 2 and 1 hours ago
+	outDir := t.TempDir()
+	keys := []string{"aa01", "bb02", "cc03"}
+	for i, key := range keys {
+		path := filepath.Join(outDir, key)
+		if err := os.WriteFile(path, []byte("0123456789"), 0644); err != nil {
+			t.Fatalf("Failed to write output: %v", err)
+		}
+		result := &ExecuteResult{OutputFiles: map[string]string{"out": path}}
+		if err := cache.Store(key, result, &ExecutionContext{OutputDir: outDir}); err != nil {
+			t.Fatalf("Failed to store entry: %v", err)
+		}
+		lastUsed := time.Now().Add(-time.Duration(len(keys)-i) * time.Hour)
+		os.Chtimes(filepath.Join(cache.entryDir(key), cacheEntryFile), lastUsed, lastUsed)
+	}
+
+	exists := func(key string) bool {
+		_, err := os.Stat(cache.entryDir(key))
+		return err == nil
+	}
+
+	if err := cache.Prune(150*time.Minute, 0); err != nil {
+		t.Fatalf("Failed to prune cache: %v", err)
+	}
+	if exists("aa01") || !exists("bb02") || !exists("cc03") {
+		t.Errorf("Expected only the oldest entry to be pruned by age")
+	}
+
+	// Room for one entry only
+	var entrySize int64
+	filepath.Walk(cache.entryDir("cc03"), func(_ string, info os.FileInfo, err error) error {
+		if err == nil && !info.IsDir() {
+			entrySize += info.Size()
+		}
+		return nil
+	})
+	if err := cache.Prune(0, entrySize+entrySize/2); err != nil {
+		t.Fatalf("Failed to prune cache: %v", err)
+	}
+	if exists("bb02") || !exists("cc03") {
+		t.Errorf("Expected the least recently used entry to be pruned by size")
+	}
+}
+
+func TestCallCacheDefaults(t *testing.T) {
+	dir := t.TempDir()
+	counter := filepath.Join(dir, "runs")
+	input := filepath.Join(dir, "input.txt")
+	if err := os.WriteFile(input, []byte("hello"), 0644); err != nil {
+		t.Fatalf("Failed to write input: %v", err)
+	}
+
+	cache, err := NewCallCache(filepath.Join(dir, "cache"))
+	if err != nil {
+		t.Fatalf("Failed to create cache: %v", err)
+	}
+	executor := NewExecutor()
+	executor.Cache = cache
+
+	tool := countingTool()
+	file := tool.Inputs["file"]
+	file.Default = map[string]interface{}{"class": "File", "location": "file://" + input}
+	tool.Inputs["file"] = file
+
+	run := func() *ExecuteResult {
+		execCtx, err := NewExecutionContext(t.TempDir())
+		if err != nil {
+			t.Fatalf("Failed to create execution context: %v", err)
+		}
+		defer execCtx.Cleanup()
+
+		result, err := executor.execute(context.Background(), tool, map[string]interface{}{"counter": counter}, execCtx)
+		if err != nil {
+			t.Fatalf("Failed to execute tool: %v", err)
+		}
+		return result
+	}
+
+	if run().Cached || !run().Cached {
+		t.Error("Expected the second run with the default to be cached")
+	}
+
+	// Changed contents of a default File are a different job
+	if err := os.WriteFile(input, []byte("changed"), 0644); err != nil {
+		t.Fatalf("Failed to write input: %v", err)
+	}
+	if run().Cached {
+		t.Error("Expected changed default File to run the tool")
+	}
+
+	// A failure to store the outputs is reported with the result
+	blocker := filepath.Join(dir, "blocker")
+	if err := os.WriteFile(blocker, nil, 0644); err != nil {
+		t.Fatalf("Failed to write file: %v", err)
+	}
+	executor.Cache = &CallCache{Dir: filepath.Join(blocker, "cache")}
+	if result := run(); result.CacheErr == nil {
+		t.Error("Expected a cache error, got nil")
+	}
+}
@@ -0,0 +1,212 @@
+package cwlgo
+
+import (
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"regexp"
+	"sort"
+	"sync"
+	"time"
+)
+
+// runStateFile is the file in a workflow run directory holding its state
+const runStateFile = "state.json"
+
+// runLockFile is the file in a workflow run directory that exists while an
+// attempt of the run is executing, holding the pid of its process
+const runLockFile = "state.lock"
+
+// validRunID matches run IDs that are safe to use as directory names
+var validRunID = regexp.MustCompile(`^[A-Za-z0-9][A-Za-z0-9._-]*$`)
+
+// runState is the persisted progress of a workflow run: the outputs of the
+// jobs that completed and the jobs that were started but not finished. Jobs
+// are identified by their directory relative to the run directory.
+type runState struct {
+	RunID     string                            `json:"runId"`
+	Digest    string                            `json:"digest"` // Hash of the workflow and its inputs
+	Completed map[string]map[string]interface{} `json:"completed"`
+	Pending   []string                          `json:"pending"`
+	Updated   time.Time                         `json:"updated"`
+
+	path   string
+	unlock func()
+	mu     sync.Mutex
+}
+
+// NewRunID returns a random run ID. A run started with ResumeWorkflow under
+// an ID from NewRunID can be resumed even if the first attempt never
+// returned.
+func NewRunID() string {
+	return newRunID()
+}
+
+// newRunID returns a random run ID
+func newRunID() string {
+	var b [8]byte
+	rand.Read(b[:])
+	return hex.EncodeToString(b[:])
+}
+
+// openRunState locks the directory of a run and loads its state, or starts
+// a new one. Resuming is refused while another attempt of the run is
+// executing or if the workflow or inputs have changed. The state must be
+// closed to release the lock.
+func openRunState(runDir, runID, digest string) (*runState, error) {
+	unlock, err := lockRun(runDir, runID)
+	if err != nil {
+		return nil, err
+	}
+	state, err := loadRunState(runDir, runID, digest)
+	if err != nil {
+		unlock()
+		return nil, err
+	}
+	state.unlock = unlock
+	return state, nil
+}
+
+// loadRunState loads the state of a run from its directory, or starts a new
+// one
+func loadRunState(runDir, runID, digest string) (*runState, error) {
+	path := filepath.Join(runDir, runStateFile)
+	state := &runState{
+		RunID:     runID,
+		Digest:    digest,
+		Completed: make(map[string]map[string]interface{}),
+		path:      path,
+	}
+
+	data, err := os.ReadFile(path)
+	if os.IsNotExist(err) {
+		return state, state.save()
+	}
+	if err != nil {
+		return nil, &CWLError{Err: err, Message: fmt.Sprintf("failed to read state of run %s", runID)}
+	}
+	if err := json.Unmarshal(data, state); err != nil {
+		return nil, &CWLError{Err: err, Message: fmt.Sprintf("failed to parse state of run %s", runID)}
+	}
+	if state.Digest != digest {
+		return nil, &CWLError{
+			Err:     ErrExecution,
+			Message: fmt.Sprintf("run %s was started with a different workflow or inputs", runID),
+		}
+	}
+	if state.Completed == nil {
+		state.Completed = make(map[string]map[string]interface{})
+	}
+	return state, nil
+}
+
+// close releases the lock of the run
+func (s *runState) close() {
+	if s.unlock != nil {
+		s.unlock()
+	}
+}
+
+// completed returns the outputs of a job finished by this or an earlier
+// attempt of the run, if its output Files are still there
+func (s *runState) completed(job string) (map[string]interface{}, bool) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	outputs, ok := s.Completed[job]
+	if !ok {
+		return nil, false
+	}
+	for _, value := range outputs {
+		for _, path := range collectPaths(value) {
+			if _, err := os.Stat(path); err != nil {
+				return nil, false
+			}
+		}
+	}
+	return outputs, true
+}
+
+// start records that a job is running. It returns whether an earlier
+// attempt of the run had started the job without finishing it.
+func (s *runState) start(job string) (bool, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	for _, pending := range s.Pending {
+		if pending == job {
+			return true, nil
+		}
+	}
+	s.Pending = append(s.Pending, job)
+	sort.Strings(s.Pending)
+	return false, s.save()
+}
+
+// finish records the outputs of a finished job
+func (s *runState) finish(job string, outputs map[string]interface{}) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	for i, pending := range s.Pending {
+		if pending == job {
+			s.Pending = append(s.Pending[:i], s.Pending[i+1:]...)
+			break
+		}
+	}
+	s.Completed[job] = outputs
+	return s.save()
+}
+
+// save writes the state to a temporary file and renames it into place, so
+// that the state file always holds a consistent state. The caller must hold
+// s.mu, except while the state is not yet shared.
+func (s *runState) save() error {
+	s.Updated = time.Now()
+	data, err := json.MarshalIndent(s, "", "  ")
+	if err != nil {
+		return &CWLError{Err: err, Message: "failed to encode run state"}
+	}
+
+	tmp := s.path + ".tmp"
+	if err := os.WriteFile(tmp, data, 0644); err != nil {
+		return &CWLError{Err: err, Message: "failed to write run state"}
+	}
+	if err := os.Rename(tmp, s.path); err != nil {
+		return &CWLError{Err: err, Message: "failed to write run state"}
+	}
+	return nil
+}
+
+// runDigest returns a hash of a workflow, the processes its steps run and
+// its inputs, which a resumed run must match
+func runDigest(wf *Workflow, inputs map[string]interface{}) (string, error) {
+	data, err := json.Marshal(map[string]interface{}{
+		"workflow": processDigestData(wf),
+		"inputs":   inputs,
+	})
+	if err != nil {
+		return "", &CWLError{Err: err, Message: "failed to encode workflow run"}
+	}
+	sum := sha256.Sum256(data)
+	return hex.EncodeToString(sum[:]), nil
+}
+
+// processDigestData returns what a process's digest is computed from,
+// including the processes of a workflow's steps, which are not encoded with
+// the workflow itself
+func processDigestData(process Process) interface{} {
+	wf, ok := process.(*Workflow)
+	if !ok {
+		return process
+	}
+	steps := make(map[string]interface{}, len(wf.Steps))
+	for stepID, step := range wf.Steps {
+		steps[stepID] = processDigestData(step.Process)
+	}
+	return map[string]interface{}{"workflow": wf, "steps": steps}
+}
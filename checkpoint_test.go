@@ -0,0 +1,185 @@
+package cwlgo
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+const countToolCWL = `cwlVersion: v1.2
+class: CommandLineTool
+baseCommand: [sh, -c, 'echo run >> "$0"; echo "$1"']
+inputs:
+  counter:
+    type: string
+    inputBinding:
+      position: 1
+  message:
+    type: string
+    inputBinding:
+      position: 2
+outputs:
+  output:
+    type: stdout
+stdout: output.txt
+`
+
+const checkToolCWL = `cwlVersion: v1.2
+class: CommandLineTool
+baseCommand: [sh, -c, 'test -e "$0" && cat "$1"']
+inputs:
+  marker:
+    type: string
+    inputBinding:
+      position: 1
+  file:
+    type: File
+    inputBinding:
+      position: 2
+outputs:
+  output:
+    type: stdout
+stdout: checked.txt
+`
+
+const resumeWorkflowCWL = `cwlVersion: v1.2
+class: Workflow
+inputs:
+  counter:
+    type: string
+  marker:
+    type: string
+  message:
+    type: string
+outputs:
+  checked:
+    type: File
+    outputSource: check/output
+steps:
+  count:
+    run: count.cwl
+    in:
+      counter: counter
+      message: message
+    out: [output]
+  check:
+    run: check.cwl
+    in:
+      marker: marker
+      file: count/output
+    out: [output]
+`
+
+func TestResumeWorkflow(t *testing.T) {
+	enterTempDir(t)
+
+	dir := writeWorkflowFiles(t, map[string]string{
+		"count.cwl":    countToolCWL,
+		"check.cwl":    checkToolCWL,
+		"workflow.cwl": resumeWorkflowCWL,
+	})
+
+	wf, err := NewParser().ParseWorkflowFile(filepath.Join(dir, "workflow.cwl"))
+	if err != nil {
+		t.Fatalf("Failed to parse workflow: %v", err)
+	}
+
+	counter := filepath.Join(dir, "runs")
+	marker := filepath.Join(dir, "marker")
+	inputs := map[string]interface{}{
+		"counter": counter,
+		"marker":  marker,
+		"message": "hello",
+	}
+
+	runID := "resume-" + NewRunID()
+
+	// The check step fails until the marker exists
+	executor := NewExecutor()
+	_, err = executor.ResumeWorkflow(context.Background(), wf, inputs, runID)
+	if err == nil {
+		t.Fatal("Expected the first attempt to fail, got nil")
+	}
+	if !strings.Contains(err.Error(), runID) {
+		t.Errorf("Expected the error to name run %s, got %v", runID, err)
+	}
+	if err := os.WriteFile(marker, nil, 0644); err != nil {
+		t.Fatalf("Failed to write marker: %v", err)
+	}
+
+	result, err := executor.ResumeWorkflow(context.Background(), wf, inputs, runID)
+	if err != nil {
+		t.Fatalf("Failed to resume workflow: %v", err)
+	}
+	if result.RunID != runID {
+		t.Errorf("Expected run ID %s, got %s", runID, result.RunID)
+	}
+
+	// Only the incomplete job ran again
+	data, err := os.ReadFile(counter)
+	if err != nil {
+		t.Fatalf("Failed to read counter: %v", err)
+	}
+	if runs := strings.Count(string(data), "run"); runs != 1 {
+		t.Errorf("Expected the completed step to run once, got %d", runs)
+	}
+
+	checked, ok := result.Outputs["checked"].(map[string]interface{})
+	if !ok {
+		t.Fatalf("Expected checked File, got %v", result.Outputs["checked"])
+	}
+	data, err = os.ReadFile(checked["path"].(string))
+	if err != nil {
+		t.Fatalf("Failed to read output: %v", err)
+	}
+	if strings.TrimSpace(string(data)) != "hello" {
+		t.Errorf("Expected %q, got %q", "hello", data)
+	}
+
+	// A run cannot be resumed with other inputs
+	inputs["message"] = "other"
+	if _, err := executor.ResumeWorkflow(context.Background(), wf, inputs, runID); err == nil {
+		t.Error("Expected error for changed inputs, got nil")
+	}
+
+	if _, err := executor.ResumeWorkflow(context.Background(), wf, inputs, "../escape"); err == nil {
+		t.Error("Expected error for invalid run ID, got nil")
+	}
+}
+
+func TestRunStateLock(t *testing.T) {
+	dir := t.TempDir()
+
+	state, err := openRunState(dir, "locked", "digest")
+	if err != nil {
+		t.Fatalf("Failed to open run state: %v", err)
+	}
+
+	// A second attempt of the same run is refused while the first executes
+	_, err = openRunState(dir, "locked", "digest")
+	if err == nil || !strings.Contains(err.Error(), "already being executed") {
+		t.Errorf("Expected error for a run already being executed, got %v", err)
+	}
+
+	state.close()
+	state, err = openRunState(dir, "locked", "digest")
+	if err != nil {
+		t.Fatalf("Failed to open run state after it was closed: %v", err)
+	}
+	state.close()
+
+	// Locks of processes that are gone are broken
+	if err := os.WriteFile(filepath.Join(dir, runLockFile), []byte("1073741824\n"), 0644); err != nil {
+		t.Fatalf("Failed to write lock file: %v", err)
+	}
+	state, err = openRunState(dir, "locked", "digest")
+	if err != nil {
+		t.Fatalf("Failed to open run state with a stale lock: %v", err)
+	}
+	state.close()
+	if _, err := os.Stat(filepath.Join(dir, runLockFile)); !os.IsNotExist(err) {
+		t.Errorf("Expected the lock to be released, got %v", err)
+	}
+}
@@ -0,0 +1,111 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"os"
+	"os/signal"
+	"path/filepath"
+	"strings"
+	"syscall"
+
+	"github.com/user/cwlgo/conformance"
+)
+
+// runConformance runs a conformance suite:
+//
+//	cwlgo conformance [-tags a,b] [-junit report.xml] conformance_tests.yaml
+//
+// It prints the failures and the counts by tag, and exits non-zero if any
+// test failed.
+func runConformance(args []string, stdout, stderr io.Writer) int {
+	flags := flag.NewFlagSet("cwlgo conformance", flag.ContinueOnError)
+	flags.SetOutput(stderr)
+	tags := flags.String("tags", "", "comma-separated tags; only tests with one of them are run")
+	junit := flags.String("junit", "", "file to write a JUnit XML report to")
+	timeout := flags.Duration("timeout", 0, "time limit for each test")
+	noContainer := flags.Bool("no-container", false, "run tools on the host, ignoring DockerRequirement and SingularityRequirement")
+	flags.Usage = func() {
+		fmt.Fprintln(stderr, "Usage: cwlgo conformance [options] <conformance_tests.yaml>")
+		flags.PrintDefaults()
+	}
+
+	if err := flags.Parse(args); err != nil {
+		if errors.Is(err, flag.ErrHelp) {
+			return exitSuccess
+		}
+		return exitUsage
+	}
+	if flags.NArg() != 1 {
+		flags.Usage()
+		return exitUsage
+	}
+
+	// Paths are resolved before changing to the working directory
+	suitePath, err := filepath.Abs(flags.Arg(0))
+	if err != nil {
+		fmt.Fprintf(stderr, "cwlgo: %v\n", err)
+		return exitFailure
+	}
+	junitPath := *junit
+	if junitPath != "" {
+		if junitPath, err = filepath.Abs(junitPath); err != nil {
+			fmt.Fprintf(stderr, "cwlgo: %v\n", err)
+			return exitFailure
+		}
+	}
+
+	tests, err := conformance.Load(suitePath)
+	if err != nil {
+		fmt.Fprintf(stderr, "cwlgo: %v\n", err)
+		return exitFailure
+	}
+	if *tags != "" {
+		tests = conformance.Filter(tests, strings.Split(*tags, ","))
+	}
+
+	// Tests write their outputs under a temporary working directory
+	workDir, err := makeTempDir(os.TempDir() + string(filepath.Separator))
+	if err != nil {
+		fmt.Fprintf(stderr, "cwlgo: %v\n", err)
+		return exitFailure
+	}
+	defer os.RemoveAll(workDir)
+	restore, err := enterWorkDir(workDir)
+	if err != nil {
+		fmt.Fprintf(stderr, "cwlgo: %v\n", err)
+		return exitFailure
+	}
+	defer restore()
+
+	runner := conformance.NewRunner()
+	runner.Timeout = *timeout
+	runner.Executor.NoContainer = *noContainer
+
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	defer stop()
+
+	report := runner.Run(ctx, tests)
+	fmt.Fprint(stdout, report.Summary())
+
+	if junitPath != "" {
+		file, err := os.Create(junitPath)
+		if err != nil {
+			fmt.Fprintf(stderr, "cwlgo: %v\n", err)
+			return exitFailure
+		}
+		defer file.Close()
+		if err := report.WriteJUnit(file); err != nil {
+			fmt.Fprintf(stderr, "cwlgo: failed to write JUnit report: %v\n", err)
+			return exitFailure
+		}
+	}
+
+	if report.Counts().Failed > 0 {
+		return exitFailure
+	}
+	return exitSuccess
+}
@@ -0,0 +1,126 @@
+package main
+
+import (
+	"fmt"
+	"path/filepath"
+	"strconv"
+	"strings"
+
+	"github.com/user/cwlgo"
+)
+
+// inputSchema returns the declared type of each input of a process
+func inputSchema(process cwlgo.Process) map[string]interface{} {
+	schema := make(map[string]interface{})
+	switch p := process.(type) {
+	case *cwlgo.CommandLineTool:
+		for inputID, param := range p.Inputs {
+			schema[inputID] = param.Type
+		}
+	case *cwlgo.Workflow:
+		for inputID, param := range p.Inputs {
+			schema[inputID] = param.Type
+		}
+	case *cwlgo.ExpressionTool:
+		for inputID, param := range p.Inputs {
+			schema[inputID] = param.Type
+		}
+	}
+	return schema
+}
+
+// parseInputFlags reads "--<input> <value>" and "--<input>=<value>" flags
+// for the inputs in schema. Boolean inputs may be given without a value,
+// array inputs by repeating the flag, and File and Directory paths are
+// resolved relative to baseDir.
+func parseInputFlags(schema map[string]interface{}, args []string, baseDir string) (map[string]interface{}, error) {
+	inputs := make(map[string]interface{})
+
+	for i := 0; i < len(args); i++ {
+		arg := args[i]
+		if !strings.HasPrefix(arg, "--") {
+			return nil, fmt.Errorf("unexpected argument %q", arg)
+		}
+		name, value, hasValue := strings.Cut(strings.TrimPrefix(arg, "--"), "=")
+
+		typ, ok := schema[name]
+		if !ok {
+			return nil, fmt.Errorf("unknown input --%s", name)
+		}
+		itemType, isArray := flagType(typ)
+
+		if !hasValue {
+			if itemType == "boolean" && !isArray {
+				inputs[name] = true
+				continue
+			}
+			if i+1 >= len(args) {
+				return nil, fmt.Errorf("input --%s needs a value", name)
+			}
+			i++
+			value = args[i]
+		}
+
+		converted, err := convertFlagValue(itemType, value, baseDir)
+		if err != nil {
+			return nil, fmt.Errorf("input --%s: %v", name, err)
+		}
+		if isArray {
+			items, _ := inputs[name].([]interface{})
+			inputs[name] = append(items, converted)
+		} else {
+			inputs[name] = converted
+		}
+	}
+
+	return inputs, nil
+}
+
+// flagType returns the type name a flag's values are converted to and
+// whether the input is an array. Optional types and unions with null are
+// reduced to their non-null type.
+func flagType(typ interface{}) (string, bool) {
+	switch t := typ.(type) {
+	case string:
+		t = strings.TrimSuffix(t, "?")
+		if strings.HasSuffix(t, "[]") {
+			item, _ := flagType(strings.TrimSuffix(t, "[]"))
+			return item, true
+		}
+		return t, false
+	case []interface{}:
+		for _, alternative := range t {
+			if alternative != "null" {
+				return flagType(alternative)
+			}
+		}
+	case map[string]interface{}:
+		if t["type"] == "array" {
+			item, _ := flagType(t["items"])
+			return item, true
+		}
+		if name, ok := t["type"].(string); ok {
+			return name, false
+		}
+	}
+	return "string", false
+}
+
+// convertFlagValue converts a flag value to the given type
+func convertFlagValue(typ, value, baseDir string) (interface{}, error) {
+	switch typ {
+	case "int", "long":
+		return strconv.Atoi(value)
+	case "float", "double":
+		return strconv.ParseFloat(value, 64)
+	case "boolean":
+		return strconv.ParseBool(value)
+	case "File", "Directory":
+		path := value
+		if !filepath.IsAbs(path) {
+			path = filepath.Join(baseDir, path)
+		}
+		return map[string]interface{}{"class": typ, "path": path}, nil
+	}
+	return value, nil
+}
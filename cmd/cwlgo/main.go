@@ -0,0 +1,226 @@
+// Command cwlgo runs CWL documents following the cwl-runner interface:
+//
+//	cwlgo [options] <document.cwl> [job.yml] [--<input> <value> ...]
+//
+// The document is a local path or an http(s) URL.
+// Inputs are read from the job file and from flags generated from the
+// document's inputs, which take precedence. The output object is printed as
+// JSON on stdout, with output Files moved to --outdir.
+//
+// "cwlgo validate" reports every problem in a document, and "cwlgo
+// conformance" runs a CWL conformance test suite.
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"os"
+	"os/signal"
+	"path/filepath"
+	"strings"
+	"syscall"
+
+	"github.com/user/cwlgo"
+)
+
+// Exit codes of the cwl-runner interface
+const (
+	exitSuccess     = 0
+	exitFailure     = 1
+	exitUsage       = 2
+	exitUnsupported = 33
+)
+
+func main() {
+	os.Exit(run(os.Args[1:], os.Stdout, os.Stderr))
+}
+
+// run runs the command with the given arguments and returns its exit code
+func run(args []string, stdout, stderr io.Writer) int {
+	if len(args) > 0 {
+		switch args[0] {
+		case "conformance":
+			return runConformance(args[1:], stdout, stderr)
+		case "validate":
+			return runValidate(args[1:], stdout, stderr)
+		}
+	}
+
+	flags := flag.NewFlagSet("cwlgo", flag.ContinueOnError)
+	flags.SetOutput(stderr)
+	outdir := flags.String("outdir", ".", "directory the final outputs are written to")
+	tmpdirPrefix := flags.String("tmpdir-prefix", os.TempDir()+string(filepath.Separator), "path prefix of the temporary directories jobs run in")
+	noContainer := flags.Bool("no-container", false, "run tools on the host, ignoring DockerRequirement and SingularityRequirement")
+	debug := flags.Bool("debug", false, "print debugging information and keep the working directory")
+	root := flags.String("root", "", "directory the local files documents refer to must be under")
+	flags.Usage = func() {
+		fmt.Fprintln(stderr, "Usage: cwlgo [options] <document.cwl> [job.yml] [--<input> <value> ...]")
+		flags.PrintDefaults()
+	}
+
+	if err := flags.Parse(args); err != nil {
+		if errors.Is(err, flag.ErrHelp) {
+			return exitSuccess
+		}
+		return exitUsage
+	}
+
+	rest := flags.Args()
+	if len(rest) == 0 {
+		flags.Usage()
+		return exitUsage
+	}
+	docPath, rest := rest[0], rest[1:]
+	var jobPath string
+	if len(rest) > 0 && !strings.HasPrefix(rest[0], "-") {
+		jobPath, rest = rest[0], rest[1:]
+	}
+	if len(rest) > 0 && rest[0] == "--" {
+		rest = rest[1:]
+	}
+
+	debugf := func(format string, args ...interface{}) {
+		if *debug {
+			fmt.Fprintf(stderr, "cwlgo: "+format+"\n", args...)
+		}
+	}
+	fail := func(err error) int {
+		fmt.Fprintf(stderr, "cwlgo: %v\n", err)
+		if errors.Is(err, cwlgo.ErrUnsupported) {
+			return exitUnsupported
+		}
+		return exitFailure
+	}
+
+	parser := cwlgo.NewParser()
+	parser.Root = *root
+	process, err := parser.LoadURI(docPath)
+	if err != nil {
+		return fail(err)
+	}
+	debugf("loaded %T from %s", process, docPath)
+
+	cwd, err := os.Getwd()
+	if err != nil {
+		return fail(err)
+	}
+
+	inputs := make(map[string]interface{})
+	if jobPath != "" {
+		if inputs, err = cwlgo.LoadJobFile(jobPath); err != nil {
+			return fail(err)
+		}
+	}
+	flagInputs, err := parseInputFlags(inputSchema(process), rest, cwd)
+	if err != nil {
+		fmt.Fprintf(stderr, "cwlgo: %v\n", err)
+		return exitUsage
+	}
+	for inputID, value := range flagInputs {
+		inputs[inputID] = value
+	}
+	if data, err := json.Marshal(inputs); err == nil {
+		debugf("inputs: %s", data)
+	}
+
+	outDir, err := filepath.Abs(*outdir)
+	if err != nil {
+		return fail(err)
+	}
+	if err := os.MkdirAll(outDir, 0755); err != nil {
+		return fail(err)
+	}
+
+	// Jobs write their outputs under the working directory, from which the
+	// final outputs are moved to outdir
+	workDir, err := makeTempDir(*tmpdirPrefix)
+	if err != nil {
+		return fail(err)
+	}
+	if *debug {
+		debugf("keeping working directory %s", workDir)
+	} else {
+		defer os.RemoveAll(workDir)
+	}
+	restore, err := enterWorkDir(workDir)
+	if err != nil {
+		return fail(err)
+	}
+	defer restore()
+
+	executor := cwlgo.NewExecutor()
+	executor.NoContainer = *noContainer
+	executor.StderrWriter = stderr
+	if *debug {
+		// stdout is reserved for the output object
+		executor.StdoutWriter = stderr
+	}
+
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	defer stop()
+
+	outputs, err := executor.ExecuteProcess(ctx, process, inputs)
+	if err != nil {
+		return fail(err)
+	}
+
+	outputs, err = relocateOutputs(outputs, outDir)
+	if err != nil {
+		return fail(err)
+	}
+
+	encoder := json.NewEncoder(stdout)
+	encoder.SetIndent("", "    ")
+	if err := encoder.Encode(outputs); err != nil {
+		return fail(err)
+	}
+	return exitSuccess
+}
+
+// makeTempDir creates a directory whose path starts with prefix. A prefix
+// ending in a separator names the parent directory.
+func makeTempDir(prefix string) (string, error) {
+	dir, pattern := filepath.Split(prefix)
+	if dir == "" {
+		dir = "."
+	}
+	if err := os.MkdirAll(dir, 0755); err != nil {
+		return "", err
+	}
+	if pattern == "" {
+		pattern = "cwlgo-"
+	}
+	path, err := os.MkdirTemp(dir, pattern+"*")
+	if err != nil {
+		return "", err
+	}
+	return filepath.Abs(path)
+}
+
+// enterWorkDir makes workDir the current directory and the location of
+// temporary directories, and returns a function that undoes it
+func enterWorkDir(workDir string) (func(), error) {
+	cwd, err := os.Getwd()
+	if err != nil {
+		return nil, err
+	}
+	tmpdir, hadTmpdir := os.LookupEnv("TMPDIR")
+
+	if err := os.Chdir(workDir); err != nil {
+		return nil, err
+	}
+	os.Setenv("TMPDIR", workDir)
+
+	return func() {
+		os.Chdir(cwd)
+		if hadTmpdir {
+			os.Setenv("TMPDIR", tmpdir)
+		} else {
+			os.Unsetenv("TMPDIR")
+		}
+	}, nil
+}
@@ -0,0 +1,157 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+const echoToolCWL = `cwlVersion: v1.2
+class: CommandLineTool
+baseCommand: echo
+inputs:
+  message:
+    type: string
+    inputBinding:
+      position: 1
+outputs:
+  output:
+    type: stdout
+stdout: output.txt
+`
+
+func TestRun(t *testing.T) {
+	dir := t.TempDir()
+	toolPath := filepath.Join(dir, "echo.cwl")
+	if err := os.WriteFile(toolPath, []byte(echoToolCWL), 0644); err != nil {
+		t.Fatalf("Failed to write tool: %v", err)
+	}
+	jobPath := filepath.Join(dir, "job.yml")
+	if err := os.WriteFile(jobPath, []byte("message: from job\n"), 0644); err != nil {
+		t.Fatalf("Failed to write job: %v", err)
+	}
+	outDir := filepath.Join(dir, "out")
+
+	// Flags take precedence over the job file
+	var stdout, stderr bytes.Buffer
+	code := run([]string{
+		"--outdir", outDir, "--tmpdir-prefix", filepath.Join(dir, "tmp") + "/", "--no-container",
+		toolPath, jobPath, "--message", "from flag",
+	}, &stdout, &stderr)
+	if code != exitSuccess {
+		t.Fatalf("Expected exit code %d, got %d: %s", exitSuccess, code, stderr.String())
+	}
+
+	var outputs map[string]map[string]interface{}
+	if err := json.Unmarshal(stdout.Bytes(), &outputs); err != nil {
+		t.Fatalf("Expected JSON output object, got %q: %v", stdout.String(), err)
+	}
+	output := outputs["output"]
+	if output["path"] != filepath.Join(outDir, "output.txt") || output["checksum"] == nil {
+		t.Errorf("Expected output File with checksum in outdir, got %v", output)
+	}
+	data, err := os.ReadFile(filepath.Join(outDir, "output.txt"))
+	if err != nil {
+		t.Fatalf("Failed to read output: %v", err)
+	}
+	if strings.TrimSpace(string(data)) != "from flag" {
+		t.Errorf("Expected %q, got %q", "from flag", data)
+	}
+
+	// The working directory is removed without --debug
+	if entries, _ := os.ReadDir(filepath.Join(dir, "tmp")); len(entries) != 0 {
+		t.Errorf("Expected working directory to be removed, got %v", entries)
+	}
+
+	if code := run([]string{toolPath, "--unknown", "x"}, &stdout, &stderr); code != exitUsage {
+		t.Errorf("Expected exit code %d for unknown input, got %d", exitUsage, code)
+	}
+	if code := run([]string{filepath.Join(dir, "missing.cwl")}, &stdout, &stderr); code != exitFailure {
+		t.Errorf("Expected exit code %d for missing document, got %d", exitFailure, code)
+	}
+}
+
+func TestParseInputFlags(t *testing.T) {
+	schema := map[string]interface{}{
+		"name":    "string",
+		"count":   "int?",
+		"verbose": "boolean",
+		"ratio":   []interface{}{"null", "double"},
+		"files":   map[string]interface{}{"type": "array", "items": "File"},
+		"tags":    "string[]",
+	}
+
+	tests := []struct {
+		name        string
+		args        []string
+		expected    map[string]interface{}
+		expectError bool
+	}{
+		{
+			name:     "Typed values",
+			args:     []string{"--name", "x", "--count=3", "--verbose", "--ratio", "0.5"},
+			expected: map[string]interface{}{"name": "x", "count": 3, "verbose": true, "ratio": 0.5},
+		},
+		{
+			name: "Repeated array flags",
+			args: []string{"--tags", "a", "--tags", "b", "--files", "in.txt"},
+			expected: map[string]interface{}{
+				"tags":  []interface{}{"a", "b"},
+				"files": []interface{}{map[string]interface{}{"class": "File", "path": "/base/in.txt"}},
+			},
+		},
+		{name: "Unknown input", args: []string{"--other", "x"}, expectError: true},
+		{name: "Missing value", args: []string{"--name"}, expectError: true},
+		{name: "Bad int", args: []string{"--count", "many"}, expectError: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			inputs, err := parseInputFlags(schema, tt.args, "/base")
+			if tt.expectError {
+				if err == nil {
+					t.Errorf("Expected error, got %v", inputs)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("Failed to parse flags: %v", err)
+			}
+			if !reflect.DeepEqual(inputs, tt.expected) {
+				t.Errorf("Expected %v, got %v", tt.expected, inputs)
+			}
+		})
+	}
+}
+
+func TestRunValidate(t *testing.T) {
+	dir := t.TempDir()
+	validPath := filepath.Join(dir, "echo.cwl")
+	invalidPath := filepath.Join(dir, "invalid.cwl")
+	if err := os.WriteFile(validPath, []byte(echoToolCWL), 0644); err != nil {
+		t.Fatalf("Failed to write tool: %v", err)
+	}
+	invalid := strings.Replace(echoToolCWL, "type: string", "type: strin", 1) + "colour: blue\n"
+	if err := os.WriteFile(invalidPath, []byte(invalid), 0644); err != nil {
+		t.Fatalf("Failed to write tool: %v", err)
+	}
+
+	var stdout, stderr bytes.Buffer
+	if code := run([]string{"validate", validPath}, &stdout, &stderr); code != exitSuccess {
+		t.Errorf("Expected exit code %d for a valid tool, got %d: %s", exitSuccess, code, stdout.String())
+	}
+
+	stdout.Reset()
+	if code := run([]string{"validate", invalidPath}, &stdout, &stderr); code != exitFailure {
+		t.Errorf("Expected exit code %d for an invalid tool, got %d", exitFailure, code)
+	}
+	for _, want := range []string{invalidPath + ":6:11: error: unknown type strin", "unknown field colour", "2 errors"} {
+		if !strings.Contains(stdout.String(), want) {
+			t.Errorf("Expected output to contain %q, got %q", want, stdout.String())
+		}
+	}
+}
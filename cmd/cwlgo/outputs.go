@@ -0,0 +1,223 @@
+package main
+
+import (
+	"crypto/sha1"
+	"encoding/hex"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/user/cwlgo"
+)
+
+// relocateOutputs copies the Files and Directories of an output object into
+// outDir and rewrites their paths, locations, sizes and checksums
+func relocateOutputs(outputs map[string]interface{}, outDir string) (map[string]interface{}, error) {
+	relocated := make(map[string]interface{}, len(outputs))
+	for outputID, value := range outputs {
+		v, err := relocate(value, outDir)
+		if err != nil {
+			return nil, fmt.Errorf("failed to move output %s: %v", outputID, err)
+		}
+		relocated[outputID] = v
+	}
+	return relocated, nil
+}
+
+// relocate copies the Files and Directories in a value into outDir
+func relocate(value interface{}, outDir string) (interface{}, error) {
+	switch v := value.(type) {
+	case map[string]interface{}:
+		class, _ := v["class"].(string)
+		if class != "File" && class != "Directory" {
+			record := make(map[string]interface{}, len(v))
+			for key, item := range v {
+				relocatedItem, err := relocate(item, outDir)
+				if err != nil {
+					return nil, err
+				}
+				record[key] = relocatedItem
+			}
+			return record, nil
+		}
+
+		src := objectPath(v)
+		if src == "" {
+			return v, nil
+		}
+		return relocateObject(v, src, uniquePath(filepath.Join(outDir, filepath.Base(src))), outDir, nil)
+
+	case []interface{}:
+		items := make([]interface{}, len(v))
+		for i, item := range v {
+			relocatedItem, err := relocate(item, outDir)
+			if err != nil {
+				return nil, err
+			}
+			items[i] = relocatedItem
+		}
+		return items, nil
+	}
+	return value, nil
+}
+
+// copiedTree is a directory that has been copied, along with everything
+// below it
+type copiedTree struct {
+	src, dst string
+}
+
+// target returns where a path below the tree was copied to
+func (t *copiedTree) target(path string) (string, bool) {
+	if t == nil {
+		return "", false
+	}
+	rel, err := filepath.Rel(t.src, path)
+	if err != nil || rel == ".." || strings.HasPrefix(rel, ".."+string(filepath.Separator)) {
+		return "", false
+	}
+	return filepath.Join(t.dst, rel), true
+}
+
+// relocateObject copies a File or Directory from src to dst, unless it is
+// part of a tree copied already, and rewrites its paths, locations, sizes
+// and checksums. Secondary files are copied beside it and the listing of a
+// Directory is rewritten to point into its copy.
+func relocateObject(v map[string]interface{}, src, dst, outDir string, tree *copiedTree) (map[string]interface{}, error) {
+	if _, copied := tree.target(src); !copied {
+		if err := cwlgo.CopyPath(src, dst); err != nil {
+			return nil, err
+		}
+	}
+
+	obj := make(map[string]interface{}, len(v)+2)
+	for key, item := range v {
+		obj[key] = item
+	}
+	obj["path"] = dst
+	obj["location"] = "file://" + dst
+	obj["basename"] = filepath.Base(dst)
+	delete(obj, "dirname")
+
+	if v["class"] == "Directory" {
+		tree = &copiedTree{src: src, dst: dst}
+	} else {
+		size, checksum, err := fileChecksum(dst)
+		if err != nil {
+			return nil, err
+		}
+		obj["size"] = size
+		obj["checksum"] = checksum
+	}
+
+	if secondaryFiles, ok := v["secondaryFiles"].([]interface{}); ok {
+		items := make([]interface{}, len(secondaryFiles))
+		for i, item := range secondaryFiles {
+			secondary, _ := item.(map[string]interface{})
+			secondarySrc := objectPath(secondary)
+			if secondarySrc == "" {
+				items[i] = item
+				continue
+			}
+			secondaryDst, copied := tree.target(secondarySrc)
+			if !copied {
+				secondaryDst = uniquePath(filepath.Join(filepath.Dir(dst), secondaryName(src, dst, secondarySrc)))
+			}
+			relocated, err := relocateObject(secondary, secondarySrc, secondaryDst, outDir, tree)
+			if err != nil {
+				return nil, err
+			}
+			items[i] = relocated
+		}
+		obj["secondaryFiles"] = items
+	}
+
+	if listing, ok := v["listing"].([]interface{}); ok && v["class"] == "Directory" {
+		items := make([]interface{}, len(listing))
+		for i, item := range listing {
+			entry, _ := item.(map[string]interface{})
+			entrySrc := objectPath(entry)
+			entryDst, copied := tree.target(entrySrc)
+			if entrySrc == "" || !copied {
+				// Entries outside the Directory are copied on their own
+				relocated, err := relocate(item, outDir)
+				if err != nil {
+					return nil, err
+				}
+				items[i] = relocated
+				continue
+			}
+			relocated, err := relocateObject(entry, entrySrc, entryDst, outDir, tree)
+			if err != nil {
+				return nil, err
+			}
+			items[i] = relocated
+		}
+		obj["listing"] = items
+	}
+
+	return obj, nil
+}
+
+// objectPath returns the local path of a File or Directory, or ""
+func objectPath(v map[string]interface{}) string {
+	path, _ := v["path"].(string)
+	if path == "" {
+		location, _ := v["location"].(string)
+		if strings.HasPrefix(location, "file://") {
+			path = strings.TrimPrefix(location, "file://")
+		}
+	}
+	return path
+}
+
+// secondaryName returns the name of a secondary file of a primary copied
+// from src to dst, renamed like the primary if it was renamed, e.g.
+// "reads_2.bam.bai" for "reads.bam.bai" of "reads.bam" copied to
+// "reads_2.bam"
+func secondaryName(src, dst, secondary string) string {
+	name := filepath.Base(secondary)
+	srcName, dstName := filepath.Base(src), filepath.Base(dst)
+	if strings.HasPrefix(name, srcName) {
+		return dstName + strings.TrimPrefix(name, srcName)
+	}
+	srcStem := strings.TrimSuffix(srcName, filepath.Ext(srcName))
+	dstStem := strings.TrimSuffix(dstName, filepath.Ext(dstName))
+	if strings.HasPrefix(name, srcStem) {
+		return dstStem + strings.TrimPrefix(name, srcStem)
+	}
+	return name
+}
+
+// uniquePath returns path, or path with a numeric suffix if it exists
+func uniquePath(path string) string {
+	if _, err := os.Lstat(path); os.IsNotExist(err) {
+		return path
+	}
+	ext := filepath.Ext(path)
+	base := strings.TrimSuffix(path, ext)
+	for i := 2; ; i++ {
+		candidate := fmt.Sprintf("%s_%d%s", base, i, ext)
+		if _, err := os.Lstat(candidate); os.IsNotExist(err) {
+			return candidate
+		}
+	}
+}
+
+// fileChecksum returns the size and SHA-1 checksum of a file
+func fileChecksum(path string) (int64, string, error) {
+	file, err := os.Open(path)
+	if err != nil {
+		return 0, "", err
+	}
+	defer file.Close()
+
+	hash := sha1.New()
+	size, err := io.Copy(hash, file)
+	if err != nil {
+		return 0, "", err
+	}
+	return size, "sha1$" + hex.EncodeToString(hash.Sum(nil)), nil
+}
@@ -0,0 +1,80 @@
+package main
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestRelocateOutputs(t *testing.T) {
+	workDir := t.TempDir()
+	outDir := t.TempDir()
+
+	files := map[string]string{
+		"reads.bam":           "bam",
+		"reads.bam.bai":       "index",
+		"results/summary.txt": "summary",
+	}
+	for name, content := range files {
+		path := filepath.Join(workDir, name)
+		if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+			t.Fatalf("Failed to create directory: %v", err)
+		}
+		if err := os.WriteFile(path, []byte(content), 0644); err != nil {
+			t.Fatalf("Failed to write file: %v", err)
+		}
+	}
+
+	// An earlier output of the same name is kept
+	if err := os.WriteFile(filepath.Join(outDir, "reads.bam"), []byte("earlier"), 0644); err != nil {
+		t.Fatalf("Failed to write file: %v", err)
+	}
+
+	outputs := map[string]interface{}{
+		"aligned": map[string]interface{}{
+			"class": "File",
+			"path":  filepath.Join(workDir, "reads.bam"),
+			"secondaryFiles": []interface{}{
+				map[string]interface{}{"class": "File", "path": filepath.Join(workDir, "reads.bam.bai")},
+			},
+		},
+		"results": map[string]interface{}{
+			"class": "Directory",
+			"path":  filepath.Join(workDir, "results"),
+			"listing": []interface{}{
+				map[string]interface{}{"class": "File", "path": filepath.Join(workDir, "results", "summary.txt")},
+			},
+		},
+	}
+
+	relocated, err := relocateOutputs(outputs, outDir)
+	if err != nil {
+		t.Fatalf("Failed to relocate outputs: %v", err)
+	}
+	os.RemoveAll(workDir)
+
+	// The secondary file is copied beside its renamed primary
+	aligned := relocated["aligned"].(map[string]interface{})
+	if expected := filepath.Join(outDir, "reads_2.bam"); aligned["path"] != expected {
+		t.Errorf("Expected primary at %s, got %v", expected, aligned["path"])
+	}
+	secondary := aligned["secondaryFiles"].([]interface{})[0].(map[string]interface{})
+	expected := filepath.Join(outDir, "reads_2.bam.bai")
+	if secondary["path"] != expected || secondary["location"] != "file://"+expected {
+		t.Errorf("Expected secondary file at %s, got %v", expected, secondary["path"])
+	}
+	if data, err := os.ReadFile(expected); err != nil || string(data) != "index" {
+		t.Errorf("Expected secondary file contents %q, got %q (%v)", "index", data, err)
+	}
+
+	// Listings point into the copied Directory
+	results := relocated["results"].(map[string]interface{})
+	entry := results["listing"].([]interface{})[0].(map[string]interface{})
+	expected = filepath.Join(outDir, "results", "summary.txt")
+	if entry["path"] != expected || entry["checksum"] == nil {
+		t.Errorf("Expected listing entry at %s with a checksum, got %v", expected, entry)
+	}
+	if data, err := os.ReadFile(expected); err != nil || string(data) != "summary" {
+		t.Errorf("Expected listing entry contents %q, got %q (%v)", "summary", data, err)
+	}
+}
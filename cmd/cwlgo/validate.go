@@ -0,0 +1,59 @@
+package main
+
+import (
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+
+	"github.com/user/cwlgo"
+)
+
+// runValidate validates a document and the documents its steps run:
+//
+//	cwlgo validate [-strict=false] document.cwl
+//
+// It prints every error and warning with its position, and exits non-zero
+// if there are errors.
+func runValidate(args []string, stdout, stderr io.Writer) int {
+	flags := flag.NewFlagSet("cwlgo validate", flag.ContinueOnError)
+	flags.SetOutput(stderr)
+	strict := flags.Bool("strict", true, "report unknown fields as errors rather than warnings")
+	root := flags.String("root", "", "directory the local files documents refer to must be under")
+	flags.Usage = func() {
+		fmt.Fprintln(stderr, "Usage: cwlgo validate [options] <document.cwl>")
+		flags.PrintDefaults()
+	}
+
+	if err := flags.Parse(args); err != nil {
+		if errors.Is(err, flag.ErrHelp) {
+			return exitSuccess
+		}
+		return exitUsage
+	}
+	if flags.NArg() != 1 {
+		flags.Usage()
+		return exitUsage
+	}
+
+	parser := cwlgo.NewParser()
+	parser.StrictValidation = *strict
+	parser.Root = *root
+
+	report, err := parser.ValidateFile(flags.Arg(0))
+	if err != nil {
+		fmt.Fprintf(stderr, "cwlgo: %v\n", err)
+		return exitFailure
+	}
+
+	for _, finding := range report.Findings {
+		fmt.Fprintln(stdout, finding)
+	}
+	errs, warnings := len(report.Errors()), len(report.Warnings())
+	if errs > 0 {
+		fmt.Fprintf(stdout, "%s is invalid: %d errors, %d warnings\n", flags.Arg(0), errs, warnings)
+		return exitFailure
+	}
+	fmt.Fprintf(stdout, "%s is valid: %d warnings\n", flags.Arg(0), warnings)
+	return exitSuccess
+}
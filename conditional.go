@@ -0,0 +1,86 @@
+package cwlgo
+
+import (
+	"fmt"
+)
+
+// pickValue methods
+const (
+	PickFirstNonNull   = "first_non_null"
+	PickTheOnlyNonNull = "the_only_non_null"
+	PickAllNonNull     = "all_non_null"
+)
+
+// evaluateWhen evaluates a step's when expression against the step's
+// inputs. The expression must yield a boolean.
+func evaluateWhen(wf *Workflow, step WorkflowStep, inputs map[string]interface{}) (bool, error) {
+	exprCtx := &ExpressionContext{
+		Inputs:     inputs,
+		JavaScript: hasRequirement(wf, step, "InlineJavascriptRequirement"),
+	}
+
+	value, err := exprCtx.EvaluateString(step.When)
+	if err != nil {
+		return false, &CWLError{
+			Err:     err,
+			Message: fmt.Sprintf("failed to evaluate when of step %s", step.ID),
+		}
+	}
+
+	run, ok := value.(bool)
+	if !ok {
+		return false, &CWLError{
+			Err:     ErrExecution,
+			Message: fmt.Sprintf("when of step %s must evaluate to a boolean, got %v", step.ID, value),
+		}
+	}
+	return run, nil
+}
+
+// applyPickValue selects the non-null values of a list of sources. A value
+// that is not a list is treated as a list of one item.
+func applyPickValue(value interface{}, method string) (interface{}, error) {
+	items, ok := value.([]interface{})
+	if !ok {
+		items = []interface{}{value}
+	}
+
+	var nonNull []interface{}
+	for _, item := range items {
+		if item != nil {
+			nonNull = append(nonNull, item)
+		}
+	}
+
+	switch method {
+	case PickFirstNonNull:
+		if len(nonNull) == 0 {
+			return nil, &CWLError{
+				Err:     ErrExecution,
+				Message: "first_non_null found no non-null value",
+			}
+		}
+		return nonNull[0], nil
+
+	case PickTheOnlyNonNull:
+		if len(nonNull) != 1 {
+			return nil, &CWLError{
+				Err:     ErrExecution,
+				Message: fmt.Sprintf("the_only_non_null expects exactly one non-null value, found %d", len(nonNull)),
+			}
+		}
+		return nonNull[0], nil
+
+	case PickAllNonNull:
+		if nonNull == nil {
+			nonNull = []interface{}{}
+		}
+		return nonNull, nil
+
+	default:
+		return nil, &CWLError{
+			Err:     ErrInvalidCWL,
+			Message: fmt.Sprintf("unsupported pickValue: %s", method),
+		}
+	}
+}
@@ -0,0 +1,134 @@
+package cwlgo
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+const conditionalWorkflowCWL = `cwlVersion: v1.2
+class: Workflow
+requirements:
+  - class: InlineJavascriptRequirement
+  - class: ScatterFeatureRequirement
+  - class: MultipleInputFeatureRequirement
+inputs:
+  kind:
+    type: string
+  messages:
+    type: string[]
+outputs:
+  chosen:
+    type: File
+    outputSource: [first/output, second/output]
+    pickValue: first_non_null
+  kept:
+    type: File[]
+    outputSource: filtered/output
+    pickValue: all_non_null
+steps:
+  first:
+    run: echo.cwl
+    when: $(inputs.kind == 'first')
+    in:
+      kind: kind
+      message: kind
+    out: [output]
+  second:
+    run: echo.cwl
+    when: $(inputs.kind == 'second')
+    in:
+      kind: kind
+      message: kind
+    out: [output]
+  filtered:
+    run: echo.cwl
+    scatter: message
+    when: $(inputs.message != 'skip')
+    in:
+      message: messages
+    out: [output]
+`
+
+func TestExecuteWorkflowWhen(t *testing.T) {
+	enterTempDir(t)
+
+	dir := writeWorkflowFiles(t, map[string]string{
+		"echo.cwl":     echoToolCWL,
+		"workflow.cwl": conditionalWorkflowCWL,
+	})
+
+	wf, err := NewParser().ParseWorkflowFile(filepath.Join(dir, "workflow.cwl"))
+	if err != nil {
+		t.Fatalf("Failed to parse workflow: %v", err)
+	}
+
+	result, err := NewExecutor().ExecuteWorkflow(context.Background(), wf, map[string]interface{}{
+		"kind":     "second",
+		"messages": []interface{}{"a", "skip", "b"},
+	})
+	if err != nil {
+		t.Fatalf("Failed to execute workflow: %v", err)
+	}
+
+	// Only the second branch ran
+	chosen, ok := result.Outputs["chosen"].(map[string]interface{})
+	if !ok {
+		t.Fatalf("Expected chosen File, got %v", result.Outputs["chosen"])
+	}
+	data, err := os.ReadFile(chosen["path"].(string))
+	if err != nil {
+		t.Fatalf("Failed to read output: %v", err)
+	}
+	if strings.TrimSpace(string(data)) != "second" {
+		t.Errorf("Expected output of second step, got %q", data)
+	}
+	if _, err := os.Stat(filepath.Join(result.OutputDir, "first")); !os.IsNotExist(err) {
+		t.Errorf("Expected skipped step not to run, got %v", err)
+	}
+
+	// The skipped scatter job left a null that all_non_null removed
+	kept, ok := result.Outputs["kept"].([]interface{})
+	if !ok || len(kept) != 2 {
+		t.Errorf("Expected 2 kept outputs, got %v", result.Outputs["kept"])
+	}
+}
+
+func TestApplyPickValue(t *testing.T) {
+	tests := []struct {
+		name        string
+		value       interface{}
+		method      string
+		expected    interface{}
+		expectError bool
+	}{
+		{name: "First non-null", value: []interface{}{nil, "a", "b"}, method: PickFirstNonNull, expected: "a"},
+		{name: "First non-null of nulls", value: []interface{}{nil, nil}, method: PickFirstNonNull, expectError: true},
+		{name: "The only non-null", value: []interface{}{nil, "a"}, method: PickTheOnlyNonNull, expected: "a"},
+		{name: "The only non-null of two", value: []interface{}{"a", "b"}, method: PickTheOnlyNonNull, expectError: true},
+		{name: "All non-null", value: []interface{}{"a", nil, "b"}, method: PickAllNonNull, expected: []interface{}{"a", "b"}},
+		{name: "All non-null of nulls", value: []interface{}{nil}, method: PickAllNonNull, expected: []interface{}{}},
+		{name: "Single value", value: "a", method: PickFirstNonNull, expected: "a"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := applyPickValue(tt.value, tt.method)
+			if tt.expectError {
+				if err == nil {
+					t.Errorf("Expected error, got %v", got)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("Failed to pick value: %v", err)
+			}
+			if !reflect.DeepEqual(got, tt.expected) {
+				t.Errorf("Expected %v, got %v", tt.expected, got)
+			}
+		})
+	}
+}
@@ -0,0 +1,234 @@
+package conformance
+
+import (
+	"crypto/sha1"
+	"encoding/hex"
+	"fmt"
+	"io"
+	"os"
+	"sort"
+	"strings"
+)
+
+// Compare checks actual outputs against the expected ones using the rules
+// of the conformance tests:
+//
+//   - the string "Any" matches any value
+//   - Files and Directories match if the actual location ends with the
+//     expected location (or path), and checksum, size and any other
+//     expected fields match; Directory listings match in any order
+//   - other objects must have the same keys with matching values
+//   - arrays must match item by item, and numbers by value
+func Compare(expected, actual interface{}) error {
+	return compare("", expected, actual)
+}
+
+// compare checks a value at the given path of the output object
+func compare(path string, expected, actual interface{}) error {
+	if expected == "Any" {
+		return nil
+	}
+
+	switch e := expected.(type) {
+	case map[string]interface{}:
+		a, ok := actual.(map[string]interface{})
+		if !ok {
+			return mismatch(path, expected, actual)
+		}
+		if class, _ := e["class"].(string); class == "File" || class == "Directory" {
+			return compareFile(path, e, a)
+		}
+		return compareObject(path, e, a)
+
+	case []interface{}:
+		a, ok := actual.([]interface{})
+		if !ok || len(a) != len(e) {
+			return mismatch(path, expected, actual)
+		}
+		for i := range e {
+			if err := compare(fmt.Sprintf("%s[%d]", path, i), e[i], a[i]); err != nil {
+				return err
+			}
+		}
+		return nil
+	}
+
+	if en, ok := toFloat(expected); ok {
+		if an, ok := toFloat(actual); ok && en == an {
+			return nil
+		}
+		return mismatch(path, expected, actual)
+	}
+	if expected != actual {
+		return mismatch(path, expected, actual)
+	}
+	return nil
+}
+
+// compareObject checks that two objects have the same keys with matching
+// values. Null fields count as absent.
+func compareObject(path string, expected, actual map[string]interface{}) error {
+	for _, key := range sortedKeys(expected) {
+		if err := compare(path+"."+key, expected[key], actual[key]); err != nil {
+			return err
+		}
+	}
+	for _, key := range sortedKeys(actual) {
+		if _, ok := expected[key]; !ok && actual[key] != nil {
+			return fmt.Errorf("%s: unexpected field %s", displayPath(path), key)
+		}
+	}
+	return nil
+}
+
+// compareFile checks a File or Directory. The checksum and size of an
+// actual File are computed from its path when the executor did not set them.
+func compareFile(path string, expected, actual map[string]interface{}) error {
+	if expected["class"] != actual["class"] {
+		return mismatch(path+".class", expected["class"], actual["class"])
+	}
+
+	actualLocation, _ := actual["location"].(string)
+	if actualLocation == "" {
+		actualLocation, _ = actual["path"].(string)
+	}
+	for _, key := range []string{"location", "path"} {
+		want, ok := expected[key].(string)
+		if !ok || want == "Any" {
+			continue
+		}
+		if actualLocation != want && !strings.HasSuffix(actualLocation, "/"+want) {
+			return mismatch(path+"."+key, want, actualLocation)
+		}
+	}
+
+	localPath, _ := actual["path"].(string)
+	if localPath == "" {
+		localPath = strings.TrimPrefix(actualLocation, "file://")
+	}
+
+	for _, key := range sortedKeys(expected) {
+		want := expected[key]
+		switch key {
+		case "class", "location", "path":
+			continue
+
+		case "checksum":
+			got, ok := actual["checksum"]
+			if !ok {
+				checksum, err := fileChecksum(localPath)
+				if err != nil {
+					return fmt.Errorf("%s: %v", displayPath(path), err)
+				}
+				got = checksum
+			}
+			if err := compare(path+".checksum", want, got); err != nil {
+				return err
+			}
+
+		case "size":
+			got, ok := actual["size"]
+			if !ok {
+				info, err := os.Stat(localPath)
+				if err != nil {
+					return fmt.Errorf("%s: %v", displayPath(path), err)
+				}
+				got = info.Size()
+			}
+			if err := compare(path+".size", want, got); err != nil {
+				return err
+			}
+
+		case "listing":
+			if err := compareListing(path+".listing", want, actual["listing"]); err != nil {
+				return err
+			}
+
+		default:
+			if err := compare(path+"."+key, want, actual[key]); err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
+
+// compareListing checks that every expected Directory entry matches a
+// distinct actual entry
+func compareListing(path string, expected, actual interface{}) error {
+	want, ok := expected.([]interface{})
+	if !ok {
+		return compare(path, expected, actual)
+	}
+	got, _ := actual.([]interface{})
+	if len(got) != len(want) {
+		return mismatch(path, expected, actual)
+	}
+
+	used := make([]bool, len(got))
+	for i, item := range want {
+		found := false
+		for j, candidate := range got {
+			if !used[j] && compare(path, item, candidate) == nil {
+				used[j] = true
+				found = true
+				break
+			}
+		}
+		if !found {
+			return fmt.Errorf("%s[%d]: no match for %v", displayPath(path), i, item)
+		}
+	}
+	return nil
+}
+
+// fileChecksum returns the SHA-1 checksum of a file in "sha1$..." form
+func fileChecksum(path string) (string, error) {
+	file, err := os.Open(path)
+	if err != nil {
+		return "", err
+	}
+	defer file.Close()
+
+	hash := sha1.New()
+	if _, err := io.Copy(hash, file); err != nil {
+		return "", err
+	}
+	return "sha1$" + hex.EncodeToString(hash.Sum(nil)), nil
+}
+
+// toFloat returns a numeric value as a float64
+func toFloat(value interface{}) (float64, bool) {
+	switch v := value.(type) {
+	case int:
+		return float64(v), true
+	case int64:
+		return float64(v), true
+	case float64:
+		return v, true
+	}
+	return 0, false
+}
+
+// sortedKeys returns the keys of an object in order
+func sortedKeys(obj map[string]interface{}) []string {
+	keys := make([]string, 0, len(obj))
+	for key := range obj {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+	return keys
+}
+
+// mismatch describes a value that differs from the expected one
+func mismatch(path string, expected, actual interface{}) error {
+	return fmt.Errorf("%s: expected %v, got %v", displayPath(path), expected, actual)
+}
+
+// displayPath returns the path of a value in the output object for messages
+func displayPath(path string) string {
+	if path == "" {
+		return "output"
+	}
+	return "output" + path
+}
@@ -0,0 +1,274 @@
+// Package conformance runs CWL conformance tests, in the format of the
+// specification's conformance_tests.yaml, against cwlgo's Parser and
+// Executor.
+package conformance
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/user/cwlgo"
+	"gopkg.in/yaml.v3"
+)
+
+// Test is one conformance test case
+type Test struct {
+	ID         string      `yaml:"id"`
+	Label      string      `yaml:"label"` // Used as the ID by older suites
+	Doc        string      `yaml:"doc"`
+	Tool       string      `yaml:"tool"` // Relative to the suite file
+	Job        string      `yaml:"job"`  // Relative to the suite file; may be empty
+	Output     interface{} `yaml:"output"`
+	ShouldFail bool        `yaml:"should_fail"`
+	Tags       []string    `yaml:"tags"`
+
+	// Import, when set, names another suite file whose tests are included
+	// in place of this entry
+	Import string `yaml:"$import"`
+}
+
+// Name returns the test's ID, or its label
+func (t Test) Name() string {
+	if t.ID != "" {
+		return t.ID
+	}
+	return t.Label
+}
+
+// Status is the outcome of a test
+type Status string
+
+// Test outcomes. A test is skipped when it needs a feature cwlgo does not
+// support, unless it is tagged "required".
+const (
+	StatusPassed  Status = "passed"
+	StatusFailed  Status = "failed"
+	StatusSkipped Status = "skipped"
+)
+
+// Result is the outcome of running one test
+type Result struct {
+	Test     Test
+	Status   Status
+	Message  string // Why the test failed or was skipped
+	Duration time.Duration
+}
+
+// TagCounts counts the outcomes of the tests with a tag
+type TagCounts struct {
+	Passed  int
+	Failed  int
+	Skipped int
+}
+
+// Report is the outcome of running a suite
+type Report struct {
+	Results []Result
+}
+
+// Load reads a conformance suite file, following $import entries, and
+// resolves the tool and job paths of its tests
+func Load(path string) ([]Test, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, &cwlgo.CWLError{Err: err, Message: fmt.Sprintf("failed to open conformance suite: %s", path)}
+	}
+
+	var entries []Test
+	if err := yaml.Unmarshal(data, &entries); err != nil {
+		return nil, &cwlgo.CWLError{Err: err, Message: fmt.Sprintf("failed to parse conformance suite: %s", path)}
+	}
+
+	baseDir := filepath.Dir(path)
+	resolve := func(p string) string {
+		if p == "" || filepath.IsAbs(p) {
+			return p
+		}
+		return filepath.Join(baseDir, p)
+	}
+
+	var tests []Test
+	for i, entry := range entries {
+		if entry.Import != "" {
+			imported, err := Load(resolve(entry.Import))
+			if err != nil {
+				return nil, err
+			}
+			tests = append(tests, imported...)
+			continue
+		}
+
+		entry.Tool = resolve(entry.Tool)
+		entry.Job = resolve(entry.Job)
+		if entry.Name() == "" {
+			entry.ID = fmt.Sprintf("%s#%d", filepath.Base(path), i+1)
+		}
+		tests = append(tests, entry)
+	}
+	return tests, nil
+}
+
+// Filter returns the tests that have at least one of the given tags, or
+// all tests if no tags are given
+func Filter(tests []Test, tags []string) []Test {
+	if len(tags) == 0 {
+		return tests
+	}
+	var filtered []Test
+	for _, test := range tests {
+		for _, tag := range tags {
+			if test.hasTag(tag) {
+				filtered = append(filtered, test)
+				break
+			}
+		}
+	}
+	return filtered
+}
+
+// hasTag reports whether a test has a tag
+func (t Test) hasTag(tag string) bool {
+	for _, testTag := range t.Tags {
+		if testTag == tag {
+			return true
+		}
+	}
+	return false
+}
+
+// Runner runs conformance tests through a Parser and an Executor
+type Runner struct {
+	Parser   *cwlgo.Parser
+	Executor *cwlgo.Executor
+	Timeout  time.Duration // Per test; zero means no timeout
+}
+
+// NewRunner creates a runner with a default parser and executor
+func NewRunner() *Runner {
+	return &Runner{
+		Parser:   cwlgo.NewParser(),
+		Executor: cwlgo.NewExecutor(),
+	}
+}
+
+// Run runs the tests in order and reports their outcomes
+func (r *Runner) Run(ctx context.Context, tests []Test) *Report {
+	report := &Report{}
+	for _, test := range tests {
+		start := time.Now()
+		result := r.runTest(ctx, test)
+		result.Duration = time.Since(start)
+		report.Results = append(report.Results, result)
+	}
+	return report
+}
+
+// runTest runs one test and compares its outputs with the expected ones
+func (r *Runner) runTest(ctx context.Context, test Test) Result {
+	if r.Timeout > 0 {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(ctx, r.Timeout)
+		defer cancel()
+	}
+
+	outputs, err := r.execute(ctx, test)
+	switch {
+	case errors.Is(err, cwlgo.ErrUnsupported) && !test.hasTag("required"):
+		return Result{Test: test, Status: StatusSkipped, Message: err.Error()}
+	case test.ShouldFail:
+		if err == nil {
+			return Result{Test: test, Status: StatusFailed, Message: "expected the run to fail, but it succeeded"}
+		}
+		return Result{Test: test, Status: StatusPassed}
+	case err != nil:
+		return Result{Test: test, Status: StatusFailed, Message: err.Error()}
+	}
+
+	if err := Compare(test.Output, outputs); err != nil {
+		return Result{Test: test, Status: StatusFailed, Message: err.Error()}
+	}
+	return Result{Test: test, Status: StatusPassed}
+}
+
+// execute parses and runs the tool of a test with its job
+func (r *Runner) execute(ctx context.Context, test Test) (map[string]interface{}, error) {
+	process, err := r.Parser.ParseProcessFile(test.Tool)
+	if err != nil {
+		return nil, err
+	}
+
+	inputs := make(map[string]interface{})
+	if test.Job != "" {
+		if inputs, err = cwlgo.LoadJobFile(test.Job); err != nil {
+			return nil, err
+		}
+	}
+
+	return r.Executor.ExecuteProcess(ctx, process, inputs)
+}
+
+// Counts returns the total number of passed, failed and skipped tests
+func (r *Report) Counts() TagCounts {
+	var counts TagCounts
+	for _, result := range r.Results {
+		counts.add(result.Status)
+	}
+	return counts
+}
+
+// TagCounts returns the outcomes of the tests by tag
+func (r *Report) TagCounts() map[string]TagCounts {
+	byTag := make(map[string]TagCounts)
+	for _, result := range r.Results {
+		for _, tag := range result.Test.Tags {
+			counts := byTag[tag]
+			counts.add(result.Status)
+			byTag[tag] = counts
+		}
+	}
+	return byTag
+}
+
+// add counts one outcome
+func (c *TagCounts) add(status Status) {
+	switch status {
+	case StatusPassed:
+		c.Passed++
+	case StatusFailed:
+		c.Failed++
+	case StatusSkipped:
+		c.Skipped++
+	}
+}
+
+// Summary returns a human-readable summary of the report: the failures,
+// then the counts by tag and in total
+func (r *Report) Summary() string {
+	var b strings.Builder
+	for _, result := range r.Results {
+		if result.Status == StatusFailed {
+			fmt.Fprintf(&b, "FAIL %s: %s\n", result.Test.Name(), result.Message)
+		}
+	}
+
+	byTag := r.TagCounts()
+	tags := make([]string, 0, len(byTag))
+	for tag := range byTag {
+		tags = append(tags, tag)
+	}
+	sort.Strings(tags)
+	for _, tag := range tags {
+		counts := byTag[tag]
+		fmt.Fprintf(&b, "%s: %d passed, %d failed, %d skipped\n", tag, counts.Passed, counts.Failed, counts.Skipped)
+	}
+
+	total := r.Counts()
+	fmt.Fprintf(&b, "total: %d passed, %d failed, %d skipped\n", total.Passed, total.Failed, total.Skipped)
+	return b.String()
+}
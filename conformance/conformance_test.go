@@ -0,0 +1,142 @@
+package conformance
+
+import (
+	"bytes"
+	"context"
+	"encoding/xml"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestRunSuite(t *testing.T) {
+	suite, err := filepath.Abs(filepath.Join("testdata", "conformance_tests.yaml"))
+	if err != nil {
+		t.Fatalf("Failed to resolve suite path: %v", err)
+	}
+
+	// Tools write their outputs under the current directory
+	cwd, err := os.Getwd()
+	if err != nil {
+		t.Fatalf("Failed to get working directory: %v", err)
+	}
+	if err := os.Chdir(t.TempDir()); err != nil {
+		t.Fatalf("Failed to change directory: %v", err)
+	}
+	defer os.Chdir(cwd)
+
+	tests, err := Load(suite)
+	if err != nil {
+		t.Fatalf("Failed to load suite: %v", err)
+	}
+	if len(tests) != 4 {
+		t.Fatalf("Expected 4 tests including imported ones, got %d", len(tests))
+	}
+
+	report := NewRunner().Run(context.Background(), tests)
+
+	expected := map[string]Status{
+		"echo_output":             StatusPassed,
+		"exit_code_failure":       StatusPassed,
+		"unsupported_requirement": StatusSkipped,
+		"wrong_output":            StatusFailed,
+	}
+	for _, result := range report.Results {
+		if result.Status != expected[result.Test.Name()] {
+			t.Errorf("Expected %s to be %s, got %s: %s", result.Test.Name(), expected[result.Test.Name()], result.Status, result.Message)
+		}
+	}
+
+	byTag := report.TagCounts()
+	if counts := byTag["required"]; counts.Passed != 2 || counts.Failed != 0 {
+		t.Errorf("Expected 2 required tests passed, got %+v", counts)
+	}
+	if counts := byTag["command_line_tool"]; counts.Passed != 2 || counts.Failed != 1 || counts.Skipped != 1 {
+		t.Errorf("Expected 2 passed, 1 failed and 1 skipped command_line_tool tests, got %+v", counts)
+	}
+
+	if filtered := Filter(tests, []string{"required"}); len(filtered) != 2 {
+		t.Errorf("Expected 2 required tests, got %d", len(filtered))
+	}
+
+	var buf bytes.Buffer
+	if err := report.WriteJUnit(&buf); err != nil {
+		t.Fatalf("Failed to write JUnit XML: %v", err)
+	}
+	var parsed junitTestSuites
+	if err := xml.Unmarshal(buf.Bytes(), &parsed); err != nil {
+		t.Fatalf("Failed to parse JUnit XML: %v", err)
+	}
+	if parsed.Tests != 4 || parsed.Failures != 1 || parsed.Skipped != 1 {
+		t.Errorf("Expected 4 tests, 1 failure and 1 skipped in JUnit XML, got %+v", parsed)
+	}
+	if !strings.Contains(report.Summary(), "FAIL wrong_output") {
+		t.Errorf("Expected summary to list the failure, got %q", report.Summary())
+	}
+}
+
+func TestCompare(t *testing.T) {
+	dir := t.TempDir()
+	path := filepath.Join(dir, "out.txt")
+	if err := os.WriteFile(path, []byte("hello world\n"), 0644); err != nil {
+		t.Fatalf("Failed to write file: %v", err)
+	}
+	file := map[string]interface{}{"class": "File", "location": "file://" + path, "path": path}
+
+	tests := []struct {
+		name        string
+		expected    interface{}
+		actual      interface{}
+		expectError bool
+	}{
+		{name: "Any", expected: "Any", actual: []interface{}{1, 2}},
+		{name: "Numbers by value", expected: 3, actual: float64(3)},
+		{name: "Different strings", expected: "a", actual: "b", expectError: true},
+		{name: "Unexpected field", expected: map[string]interface{}{"a": 1}, actual: map[string]interface{}{"a": 1, "b": 2}, expectError: true},
+		{name: "Array length", expected: []interface{}{1}, actual: []interface{}{1, 2}, expectError: true},
+		{
+			name: "File by location suffix, checksum and size",
+			expected: map[string]interface{}{
+				"class": "File", "location": "out.txt",
+				"checksum": "sha1$22596363b3de40b06f981fb85d82312e8c0ed511", "size": 12,
+			},
+			actual: file,
+		},
+		{
+			name:        "File with wrong checksum",
+			expected:    map[string]interface{}{"class": "File", "location": "Any", "checksum": "sha1$0"},
+			actual:      file,
+			expectError: true,
+		},
+		{
+			name:        "File with other basename",
+			expected:    map[string]interface{}{"class": "File", "location": "other.txt"},
+			actual:      file,
+			expectError: true,
+		},
+		{
+			name: "Directory listing in any order",
+			expected: map[string]interface{}{"class": "Directory", "location": "Any", "listing": []interface{}{
+				map[string]interface{}{"class": "File", "location": "b"},
+				map[string]interface{}{"class": "File", "location": "a"},
+			}},
+			actual: map[string]interface{}{"class": "Directory", "location": "file:///d", "listing": []interface{}{
+				map[string]interface{}{"class": "File", "location": "file:///d/a"},
+				map[string]interface{}{"class": "File", "location": "file:///d/b"},
+			}},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := Compare(tt.expected, tt.actual)
+			if tt.expectError && err == nil {
+				t.Error("Expected error, got nil")
+			}
+			if !tt.expectError && err != nil {
+				t.Errorf("Expected match, got %v", err)
+			}
+		})
+	}
+}
@@ -1,26 +1,30 @@
 // Package cwlgo provides functionality for parsing and executing
-// Common Workflow Language (CWL) CommandLineTool descriptions.
+// Common Workflow Language (CWL) CommandLineTool and Workflow descriptions.
 package cwlgo
 
 import (
+	"encoding/json"
 	"fmt"
 	"os"
 	"path/filepath"
+	"strings"
+
+	"gopkg.in/yaml.v3"
 )
 
 // CommandLineTool represents a CWL CommandLineTool document
 type CommandLineTool struct {
 	// Required fields
 	CWLVersion  string      `yaml:"cwlVersion" json:"cwlVersion"`
-	Class       string      `yaml:"class" json:"class"`                                 // Must be "CommandLineTool"
-	BaseCommand interface{} `yaml:"baseCommand,omitempty" json:"baseCommand,omitempty"` // String or []string
+	Class       string      `yaml:"class" json:"class"`                                                    // Must be "CommandLineTool"
+	BaseCommand interface{} `yaml:"baseCommand,omitempty" json:"baseCommand,omitempty" cwl:"stringOrList"` // String or []string; optional if arguments or inputs build the command
 
 	// Optional fields
 	Inputs             map[string]CommandInputParameter  `yaml:"inputs" json:"inputs"`
 	Outputs            map[string]CommandOutputParameter `yaml:"outputs" json:"outputs"`
 	ID                 string                            `yaml:"id,omitempty" json:"id,omitempty"`
 	Requirements       []map[string]interface{}          `yaml:"requirements,omitempty" json:"requirements,omitempty"`
-	Hints              []Hint                            `yaml:"hints,omitempty" json:"hints,omitempty"`
+	Hints              []map[string]interface{}          `yaml:"hints,omitempty" json:"hints,omitempty"`
 	Label              string                            `yaml:"label,omitempty" json:"label,omitempty"`
 	Doc                string                            `yaml:"doc,omitempty" json:"doc,omitempty"`
 	Arguments          []CommandLineBinding              `yaml:"arguments,omitempty" json:"arguments,omitempty"`
@@ -30,6 +34,10 @@ type CommandLineTool struct {
 	SuccessCodes       []int                             `yaml:"successCodes,omitempty" json:"successCodes,omitempty"`
 	TemporaryFailCodes []int                             `yaml:"temporaryFailCodes,omitempty" json:"temporaryFailCodes,omitempty"`
 	PermanentFailCodes []int                             `yaml:"permanentFailCodes,omitempty" json:"permanentFailCodes,omitempty"`
+
+	// Schema-salad metadata
+	Namespaces map[string]string `yaml:"$namespaces,omitempty" json:"$namespaces,omitempty"` // Prefix -> namespace IRI
+	Schemas    []string          `yaml:"$schemas,omitempty" json:"$schemas,omitempty"`       // Ontology files used for format checking
 }
 
 // CommandInputParameter represents an input parameter for a CommandLineTool
@@ -65,6 +73,35 @@ type CommandLineBinding struct {
 	ShellQuote    *bool       `yaml:"shellQuote,omitempty" json:"shellQuote,omitempty"`
 }
 
+// UnmarshalYAML accepts a plain string argument, such as
+// "$(inputs.script.path)", as the binding's valueFrom
+func (b *CommandLineBinding) UnmarshalYAML(value *yaml.Node) error {
+	if value.Kind == yaml.ScalarNode {
+		var valueFrom string
+		if err := value.Decode(&valueFrom); err != nil {
+			return err
+		}
+		b.ValueFrom = valueFrom
+		return nil
+	}
+	type plain CommandLineBinding
+	return value.Decode((*plain)(b))
+}
+
+// UnmarshalJSON accepts a plain string argument as the binding's valueFrom
+func (b *CommandLineBinding) UnmarshalJSON(data []byte) error {
+	if trimmed := strings.TrimSpace(string(data)); strings.HasPrefix(trimmed, `"`) {
+		var valueFrom string
+		if err := json.Unmarshal(data, &valueFrom); err != nil {
+			return err
+		}
+		b.ValueFrom = valueFrom
+		return nil
+	}
+	type plain CommandLineBinding
+	return json.Unmarshal(data, (*plain)(b))
+}
+
 // CommandOutputBinding represents how to capture output from a command
 type CommandOutputBinding struct {
 	Glob         interface{} `yaml:"glob,omitempty" json:"glob,omitempty"` // String, Expression, or []string
@@ -151,8 +188,9 @@ func (s SingularityRequirement) IsRequirement() bool {
 
 // Error types
 var (
-	ErrInvalidCWL = fmt.Errorf("invalid CWL document")
-	ErrExecution  = fmt.Errorf("command execution error")
+	ErrInvalidCWL  = fmt.Errorf("invalid CWL document")
+	ErrExecution   = fmt.Errorf("command execution error")
+	ErrUnsupported = fmt.Errorf("unsupported feature")
 )
 
 // CWLError represents an error that occurred during CWL processing
@@ -176,7 +214,7 @@ func (e *CWLError) Unwrap() error {
 
 // ContainerConfig holds configuration for container execution
 type ContainerConfig struct {
-	Type      string   // "docker" or "singularity"
+	Type      string   // Container runtime name, e.g. "docker", "podman" or "singularity"
 	Image     string   // Image name or path
 	Pull      bool     // Whether to pull the image
 	Load      string   // Path to image file to load
@@ -187,6 +225,12 @@ type ContainerConfig struct {
 	Volumes   []string // Additional volumes to mount
 	WorkDir   string   // Working directory inside container
 	EnvVars   []string // Environment variables to set in container
+	User      string   // uid:gid to run as, empty for the image default
+	Name      string   // Container name, generated when the container is started
+
+	// NetworkIsolation makes Singularity run the container without network
+	// access unless NetworkAccess allows it; Docker and Podman always do
+	NetworkIsolation bool
 }
 
 // ExecutionContext holds the context for executing a CommandLineTool
@@ -197,6 +241,20 @@ type ExecutionContext struct {
 	OutputDir       string
 	EnvironmentVars map[string]string
 	Container       *ContainerConfig // Container configuration if using containers
+	JavaScript      bool             // Whether InlineJavascriptRequirement is in effect
+	Cores           int              // Cores allocated to the job (runtime.cores)
+	RAM             int64            // RAM in MiB allocated to the job (runtime.ram)
+	NetworkAccess   bool             // Whether containers may use the network
+
+	// Input Files and Directories mounted into the container. When staged,
+	// Inputs holds the container-side values and HostInputs the originals.
+	PathMappings []PathMapping
+	HostInputs   map[string]interface{}
+
+	// Resolved stdin path and stdout/stderr names relative to OutputDir
+	Stdin  string
+	Stdout string
+	Stderr string
 }
 
 // NewExecutionContext creates a new execution context
@@ -226,6 +284,8 @@ func NewExecutionContext(workingDir string) (*ExecutionContext, error) {
 		OutputDir:       outputDir,
 		EnvironmentVars: make(map[string]string),
 		Container:       nil, // Will be set if container execution is required
+		Cores:           1,
+		RAM:             1024,
 	}, nil
 }
 
@@ -0,0 +1,234 @@
+package cwlgo
+
+import (
+	"bytes"
+	"fmt"
+	"net/url"
+	"strings"
+
+	"gopkg.in/yaml.v3"
+)
+
+// Schema-salad directives, each an object field naming a document
+// relative to the one containing it
+const (
+	directiveImport  = "$import"  // Replaces the object with the parsed document
+	directiveInclude = "$include" // Replaces the object with the document's text
+	directiveMixin   = "$mixin"   // Merges the document's fields under the object's own
+)
+
+// resolveDirectives expands the $import, $include and $mixin directives of
+// a YAML or JSON document whose URI is base, and returns the expanded
+// document as YAML. Documents without directives are returned unchanged.
+// Directives may only name local files under root, when set.
+func resolveDirectives(data []byte, base *url.URL, root string) ([]byte, error) {
+	if !hasDirectives(data) {
+		return data, nil
+	}
+
+	var doc yaml.Node
+	if err := yaml.Unmarshal(data, &doc); err != nil {
+		return nil, &CWLError{
+			Err:     err,
+			Message: fmt.Sprintf("failed to parse %s", referenceString(base)),
+		}
+	}
+
+	r := &directiveResolver{root: root}
+	if err := r.resolve(&doc, base, []string{referenceString(base)}); err != nil {
+		return nil, err
+	}
+
+	out, err := yaml.Marshal(&doc)
+	if err != nil {
+		return nil, &CWLError{Err: err, Message: "failed to write expanded document"}
+	}
+	return out, nil
+}
+
+// hasDirectives reports whether a document may contain directives
+func hasDirectives(data []byte) bool {
+	for _, directive := range []string{directiveImport, directiveInclude, directiveMixin} {
+		if bytes.Contains(data, []byte(directive)) {
+			return true
+		}
+	}
+	return false
+}
+
+// directiveResolver expands directives in a node tree, following them
+// into the documents they name
+type directiveResolver struct {
+	// origins records the file of each node taken from another document,
+	// so that positions can be reported against the right file
+	origins map[*yaml.Node]string
+
+	// root, when set, is the directory local documents must be under
+	root string
+}
+
+// resolve expands the directives under node, found in the document at
+// base. stack lists the documents being imported, to detect cycles.
+func (r *directiveResolver) resolve(node *yaml.Node, base *url.URL, stack []string) error {
+	switch node.Kind {
+	case yaml.DocumentNode, yaml.SequenceNode:
+		for _, child := range node.Content {
+			if err := r.resolve(child, base, stack); err != nil {
+				return err
+			}
+		}
+
+	case yaml.MappingNode:
+		if ref := mappingValue(node, directiveImport); ref != nil {
+			imported, uri, err := r.load(ref, directiveImport, base, stack)
+			if err != nil {
+				return err
+			}
+			*node = *imported
+			r.mark(node, referenceString(uri))
+			return nil
+		}
+
+		if ref := mappingValue(node, directiveInclude); ref != nil {
+			uri, data, err := r.fetchReference(ref, directiveInclude, base)
+			if err != nil {
+				return err
+			}
+			*node = yaml.Node{Kind: yaml.ScalarNode, Tag: "!!str", Value: string(data)}
+			r.mark(node, referenceString(uri))
+			return nil
+		}
+
+		if ref := mappingValue(node, directiveMixin); ref != nil {
+			mixin, uri, err := r.load(ref, directiveMixin, base, stack)
+			if err != nil {
+				return err
+			}
+			if mixin.Kind != yaml.MappingNode {
+				return &CWLError{
+					Err:     ErrInvalidCWL,
+					Message: fmt.Sprintf("%s: %s document %s must be an object", position(base, ref), directiveMixin, referenceString(uri)),
+				}
+			}
+			r.mark(mixin, referenceString(uri))
+			mergeMixin(node, mixin)
+		}
+
+		for _, pair := range mappingPairs(node) {
+			if err := r.resolve(pair[1], base, stack); err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
+
+// load fetches and parses the document a directive names, with its own
+// directives expanded, and returns its root node and URI
+func (r *directiveResolver) load(ref *yaml.Node, directive string, base *url.URL, stack []string) (*yaml.Node, *url.URL, error) {
+	uri, data, err := r.fetchReference(ref, directive, base)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	name := referenceString(uri)
+	for i, seen := range stack {
+		if seen == name {
+			cycle := append(append([]string{}, stack[i:]...), name)
+			return nil, nil, &CWLError{
+				Err:     ErrInvalidCWL,
+				Message: fmt.Sprintf("%s: %s cycle: %s", position(base, ref), directive, strings.Join(cycle, " -> ")),
+			}
+		}
+	}
+
+	var doc yaml.Node
+	if err := yaml.Unmarshal(data, &doc); err != nil {
+		return nil, nil, &CWLError{
+			Err:     err,
+			Message: fmt.Sprintf("%s: failed to parse %s", position(base, ref), name),
+		}
+	}
+	if len(doc.Content) == 0 {
+		return nil, nil, &CWLError{
+			Err:     ErrInvalidCWL,
+			Message: fmt.Sprintf("%s: %s document %s is empty", position(base, ref), directive, name),
+		}
+	}
+
+	if err := r.resolve(&doc, uri, append(stack, name)); err != nil {
+		return nil, nil, err
+	}
+	return doc.Content[0], uri, nil
+}
+
+// mark records the file of a node and its children, keeping the files
+// already recorded for nodes from more deeply nested documents
+func (r *directiveResolver) mark(node *yaml.Node, file string) {
+	if r.origins == nil {
+		r.origins = make(map[*yaml.Node]string)
+	}
+	if _, ok := r.origins[node]; !ok {
+		r.origins[node] = file
+	}
+	for _, child := range node.Content {
+		r.mark(child, file)
+	}
+}
+
+// fetchReference resolves and fetches the document a directive names,
+// refusing local files that the document at base may not refer to
+func (r *directiveResolver) fetchReference(ref *yaml.Node, directive string, base *url.URL) (*url.URL, []byte, error) {
+	if ref.Kind != yaml.ScalarNode || ref.Value == "" {
+		return nil, nil, &CWLError{
+			Err:     ErrInvalidCWL,
+			Message: fmt.Sprintf("%s: %s must be a URI", position(base, ref), directive),
+		}
+	}
+
+	uri, err := resolveReference(base, ref.Value)
+	if err != nil {
+		return nil, nil, err
+	}
+	if err := checkReference(base, uri, r.root); err != nil {
+		return nil, nil, &CWLError{
+			Err:     err,
+			Message: fmt.Sprintf("%s: %s %s", position(base, ref), directive, ref.Value),
+		}
+	}
+	data, err := fetchDocument(uri)
+	if err != nil {
+		return nil, nil, &CWLError{
+			Err:     err,
+			Message: fmt.Sprintf("%s: failed to resolve %s %s", position(base, ref), directive, ref.Value),
+		}
+	}
+	return uri, data, nil
+}
+
+// mergeMixin replaces an object's $mixin field with the fields of the
+// mixin document that the object does not set itself
+func mergeMixin(node, mixin *yaml.Node) {
+	own := make(map[string]bool)
+	for _, pair := range mappingPairs(node) {
+		own[pair[0].Value] = true
+	}
+
+	var content []*yaml.Node
+	for _, pair := range mappingPairs(mixin) {
+		if !own[pair[0].Value] {
+			content = append(content, pair[0], pair[1])
+		}
+	}
+	for _, pair := range mappingPairs(node) {
+		if pair[0].Value != directiveMixin {
+			content = append(content, pair[0], pair[1])
+		}
+	}
+	node.Content = content
+}
+
+// position formats the location of a node in the document at base
+func position(base *url.URL, node *yaml.Node) string {
+	return fmt.Sprintf("%s:%d:%d", referenceString(base), node.Line, node.Column)
+}
@@ -0,0 +1,140 @@
+package cwlgo
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+// writeFiles writes files relative to dir, creating directories as needed
+func writeFiles(t *testing.T, dir string, files map[string]string) {
+	t.Helper()
+	for name, content := range files {
+		path := filepath.Join(dir, name)
+		if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+			t.Fatalf("Failed to create directory for %s: %v", name, err)
+		}
+		if err := os.WriteFile(path, []byte(content), 0644); err != nil {
+			t.Fatalf("Failed to write %s: %v", name, err)
+		}
+	}
+}
+
+func TestResolveDirectives(t *testing.T) {
+	dir := t.TempDir()
+	writeFiles(t, dir, map[string]string{
+		"tool.cwl": `cwlVersion: v1.2
+class: CommandLineTool
+baseCommand: sh
+arguments: [-c, {$include: scripts/run.sh}]
+inputs:
+  $import: lib/inputs.yml
+outputs: {}
+`,
+		"scripts/run.sh": "echo \"$1\"\n",
+		"lib/inputs.yml": `message:
+  $mixin: message.yml
+  doc: {$include: message.txt}
+`,
+		"lib/message.yml": `type: string
+doc: replaced by the including object
+inputBinding: {position: 1}
+`,
+		"lib/message.txt": "The message to print",
+	})
+
+	tool, err := NewParser().ParseFile(filepath.Join(dir, "tool.cwl"))
+	if err != nil {
+		t.Fatalf("Failed to parse tool with directives: %v", err)
+	}
+
+	if len(tool.Arguments) != 2 || tool.Arguments[1].ValueFrom != "echo \"$1\"\n" {
+		t.Errorf("Expected the script to be included as an argument, got %+v", tool.Arguments)
+	}
+	message, ok := tool.Inputs["message"]
+	if !ok {
+		t.Fatalf("Expected imported input message, got %v", tool.Inputs)
+	}
+	if message.Type != "string" || message.Binding == nil || message.Binding.Position != 1 {
+		t.Errorf("Expected the mixin fields in the input, got %+v", message)
+	}
+	if message.Doc != "The message to print" {
+		t.Errorf("Expected the object's own doc to override the mixin, got %q", message.Doc)
+	}
+}
+
+func TestResolveDirectivesErrors(t *testing.T) {
+	tests := []struct {
+		name     string
+		files    map[string]string
+		expected []string
+	}{
+		{
+			name: "Import cycle",
+			files: map[string]string{
+				"doc.cwl": "inputs: {$import: a.yml}\n",
+				"a.yml":   "x: {$import: b.yml}\n",
+				"b.yml":   "y: {$import: a.yml}\n",
+			},
+			expected: []string{"b.yml:1:14: $import cycle:", "a.yml -> ", "b.yml -> ", "a.yml"},
+		},
+		{
+			name: "Missing include",
+			files: map[string]string{
+				"doc.cwl": "cwlVersion: v1.2\ndoc:\n  $include: missing.txt\n",
+			},
+			expected: []string{"doc.cwl:3:13: failed to resolve $include missing.txt"},
+		},
+		{
+			name: "Malformed import",
+			files: map[string]string{
+				"doc.cwl":     "inputs:\n  $import: lib/bad.yml\n",
+				"lib/bad.yml": "x: [unclosed\n",
+			},
+			expected: []string{"doc.cwl:2:12: failed to parse", "lib/bad.yml", "line 1"},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			dir := t.TempDir()
+			writeFiles(t, dir, tt.files)
+
+			_, err := NewParser().ParseProcessFile(filepath.Join(dir, "doc.cwl"))
+			if err == nil {
+				t.Fatal("Expected error, got nil")
+			}
+			for _, want := range tt.expected {
+				if !strings.Contains(err.Error(), want) {
+					t.Errorf("Expected error to contain %q, got %v", want, err)
+				}
+			}
+		})
+	}
+}
+
+func TestValidateFileDirectives(t *testing.T) {
+	dir := t.TempDir()
+	writeFiles(t, dir, map[string]string{
+		"tool.cwl": `cwlVersion: v1.2
+class: CommandLineTool
+baseCommand: echo
+inputs: {$import: inputs.yml}
+outputs: {}
+`,
+		"inputs.yml": "message:\n  type: strin\n",
+	})
+
+	report, err := NewParser().ValidateFile(filepath.Join(dir, "tool.cwl"))
+	if err != nil {
+		t.Fatalf("Failed to validate: %v", err)
+	}
+	if len(report.Findings) != 1 {
+		t.Fatalf("Expected 1 finding, got %v", report.Findings)
+	}
+	finding := report.Findings[0]
+	if finding.File != filepath.Join(dir, "inputs.yml") || finding.Line != 2 || !strings.Contains(finding.Message, "unknown type strin") {
+		t.Errorf("Expected unknown type at inputs.yml:2, got %s", finding)
+	}
+}
@@ -0,0 +1,212 @@
+package cwlgo
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"os"
+	"strconv"
+	"strings"
+	"syscall"
+	"time"
+)
+
+// DockerRuntime runs tools with the Docker CLI
+type DockerRuntime struct{}
+
+// Name implements the ContainerRuntime interface
+func (d *DockerRuntime) Name() string {
+	return "docker"
+}
+
+// Available checks if Docker is available on the system
+func (d *DockerRuntime) Available() error {
+	return checkCommandAvailable("docker")
+}
+
+// Prepare makes the tool's image available, loading, building, importing
+// or pulling it only when it is not already present
+func (d *DockerRuntime) Prepare(ctx context.Context, execCtx *ExecutionContext) error {
+	return prepareDockerStyleImage(ctx, "docker", execCtx)
+}
+
+// WrapCommand builds a Docker command for executing a tool
+func (d *DockerRuntime) WrapCommand(cmdArgs []string, execCtx *ExecutionContext) ([]string, error) {
+	return buildDockerStyleCommand("docker", nil, cmdArgs, execCtx), nil
+}
+
+// buildDockerStyleCommand builds a "run" command line for Docker-compatible
+// CLIs such as docker and podman. extraArgs are inserted before the image.
+func buildDockerStyleCommand(engine string, extraArgs []string, cmdArgs []string, execCtx *ExecutionContext) []string {
+	// Name the container so that it can be killed and removed if the
+	// executor is cancelled or crashes
+	if execCtx.Container.Name == "" {
+		execCtx.Container.Name = newContainerName()
+	}
+	containerCmd := []string{engine, "run", "--rm", "--name", execCtx.Container.Name}
+	if hostname, err := os.Hostname(); err == nil {
+		containerCmd = append(containerCmd, "--label", containerHostLabel+"="+hostname)
+	}
+
+	// Keep stdin open so a redirected stdin reaches the tool
+	if execCtx.Stdin != "" {
+		containerCmd = append(containerCmd, "-i")
+	}
+
+	// Run as the configured user
+	if execCtx.Container.User != "" {
+		containerCmd = append(containerCmd, "--user", execCtx.Container.User)
+	}
+
+	// Isolate the container from the network unless NetworkAccess allows it
+	if !execCtx.NetworkAccess {
+		containerCmd = append(containerCmd, "--network", "none")
+	}
+
+	// Add volume mounts
+	outputDir := containerOutputDir(execCtx.Container)
+	containerCmd = append(containerCmd, "-v", fmt.Sprintf("%s:%s", execCtx.WorkingDir, execCtx.WorkingDir))
+	containerCmd = append(containerCmd, "-v", fmt.Sprintf("%s:%s", execCtx.OutputDir, outputDir))
+	if execCtx.TempDir != "" {
+		containerCmd = append(containerCmd, "-v", fmt.Sprintf("%s:%s", execCtx.TempDir, ContainerTmpDir))
+	}
+
+	// Add additional volumes if specified
+	for _, volume := range execCtx.Container.Volumes {
+		containerCmd = append(containerCmd, "-v", volume)
+	}
+
+	// Run in the output directory
+	containerCmd = append(containerCmd, "-w", outputDir)
+
+	// Add environment variables
+	for name, value := range execCtx.EnvironmentVars {
+		containerCmd = append(containerCmd, "-e", fmt.Sprintf("%s=%s", name, value))
+	}
+
+	// Add container environment variables if specified
+	for _, env := range execCtx.Container.EnvVars {
+		containerCmd = append(containerCmd, "-e", env)
+	}
+
+	// Add engine-specific options
+	containerCmd = append(containerCmd, extraArgs...)
+
+	// Add image
+	containerCmd = append(containerCmd, containerImage(execCtx.Container))
+
+	// Add command and arguments
+	containerCmd = append(containerCmd, cmdArgs...)
+
+	return containerCmd
+}
+
+// Cleanup kills and removes the container if it is still around, e.g.
+// because the context was cancelled and only the docker client was killed
+func (d *DockerRuntime) Cleanup(execCtx *ExecutionContext) {
+	cleanupDockerStyleContainer("docker", execCtx)
+}
+
+// RemoveOrphans removes containers left behind by crashed cwlgo processes
+func (d *DockerRuntime) RemoveOrphans(ctx context.Context) error {
+	return removeOrphanedContainers(ctx, "docker")
+}
+
+const (
+	// containerNamePrefix starts the names of all containers run by cwlgo
+	containerNamePrefix = "cwlgo-"
+
+	// containerHostLabel records the host whose cwlgo process started a
+	// container, so that the janitor only judges its own host's processes
+	containerHostLabel = "cwlgo.host"
+
+	// containerCleanupTimeout bounds the kill and rm calls after a run
+	containerCleanupTimeout = 30 * time.Second
+)
+
+// newContainerName returns a unique container name that embeds the pid of
+// this process, e.g. cwlgo-1234-9f86d081884c7d65
+func newContainerName() string {
+	var b [8]byte
+	rand.Read(b[:])
+	return fmt.Sprintf("%s%d-%s", containerNamePrefix, os.Getpid(), hex.EncodeToString(b[:]))
+}
+
+// cleanupDockerStyleContainer kills and removes a named container. The
+// container is normally gone already because of --rm, so errors are
+// ignored.
+func cleanupDockerStyleContainer(engine string, execCtx *ExecutionContext) {
+	if execCtx.Container == nil || execCtx.Container.Name == "" {
+		return
+	}
+
+	// The job's context may be cancelled already
+	ctx, cancel := context.WithTimeout(context.Background(), containerCleanupTimeout)
+	defer cancel()
+
+	runImageCommand(ctx, nil, engine, "kill", execCtx.Container.Name)
+	runImageCommand(ctx, nil, engine, "rm", "-f", execCtx.Container.Name)
+}
+
+// removeOrphanedContainers removes cwlgo containers started on this host by
+// processes that are no longer running
+func removeOrphanedContainers(ctx context.Context, engine string) error {
+	hostname, err := os.Hostname()
+	if err != nil {
+		return &CWLError{Err: err, Message: "failed to determine host name"}
+	}
+
+	out, err := runImageCommand(ctx, nil, engine, "ps", "-a",
+		"--filter", "name="+containerNamePrefix,
+		"--filter", "label="+containerHostLabel+"="+hostname,
+		"--format", "{{.Names}}")
+	if err != nil {
+		return err
+	}
+
+	var orphans []string
+	for _, name := range strings.Fields(out) {
+		pid, ok := containerOwner(name)
+		if ok && !processAlive(pid) {
+			orphans = append(orphans, name)
+		}
+	}
+	if len(orphans) == 0 {
+		return nil
+	}
+
+	_, err = runImageCommand(ctx, nil, engine, append([]string{"rm", "-f"}, orphans...)...)
+	return err
+}
+
+// containerOwner returns the pid embedded in a name from newContainerName
+func containerOwner(name string) (int, bool) {
+	rest, ok := strings.CutPrefix(name, containerNamePrefix)
+	if !ok {
+		return 0, false
+	}
+	pidStr, _, ok := strings.Cut(rest, "-")
+	if !ok {
+		return 0, false
+	}
+	pid, err := strconv.Atoi(pidStr)
+	if err != nil || pid <= 0 {
+		return 0, false
+	}
+	return pid, true
+}
+
+// processAlive reports whether a process with the given pid is running
+func processAlive(pid int) bool {
+	if pid == os.Getpid() {
+		return true
+	}
+	process, err := os.FindProcess(pid)
+	if err != nil {
+		return false
+	}
+	err = process.Signal(syscall.Signal(0))
+	return err == nil || errors.Is(err, syscall.EPERM)
+}
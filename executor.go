@@ -1,7 +1,6 @@
 package cwlgo
 
 import (
-	"bytes"
 	"context"
 	"fmt"
 	"io"
@@ -10,6 +9,7 @@ import (
 	"path/filepath"
 	"sort"
 	"strings"
+	"sync"
 )
 
 // Executor handles execution of CommandLineTools
@@ -17,9 +17,62 @@ type Executor struct {
 	// Configuration options for the executor
 	DockerEnabled      bool
 	SingularityEnabled bool
-	MaxCores           int
-	MaxRAM             int64 // in MiB
+
+	// MaxCores and MaxRAM (in MiB) are the largest ResourceRequirement a
+	// tool may make. They are also the only limit on how many workflow jobs
+	// run at once: jobs start while their cores and RAM fit in what the
+	// running jobs leave free. Change them before the first workflow runs.
+	MaxCores int
+	MaxRAM   int64
+
+	// DockerRuntimes lists the container runtimes that may run tools with a
+	// DockerRequirement, in order of preference. The first available one
+	// is used; under "singularity" the image is converted to a SIF.
+	DockerRuntimes []string
+
+	// NoContainer runs tools on the host, ignoring DockerRequirement and
+	// SingularityRequirement
+	NoContainer bool
+
+	// ContainerRunAsRoot runs containers as the image's default user
+	// instead of the invoking user's uid:gid
+	ContainerRunAsRoot bool
+
+	// SingularityNetworkIsolation runs Singularity/Apptainer containers
+	// without network access unless NetworkAccess allows it, as Docker and
+	// Podman containers always are. It is off by default because
+	// unprivileged installations reject the --net flag.
+	SingularityNetworkIsolation bool
+
+	// CaptureLimit is the maximum number of bytes of stdout and stderr kept
+	// in memory for ExecuteResult.Stdout/Stderr. Zero disables capture.
+	// Streams are written to files only when the tool redirects them.
+	CaptureLimit int64
+
+	// StdoutWriter and StderrWriter, when set, receive a live copy of the
+	// tool's stdout and stderr (e.g. for log tailing)
+	StdoutWriter io.Writer
+	StderrWriter io.Writer
+
+	// Cache, when set, reuses the outputs of identical earlier jobs instead
+	// of running them again
+	Cache *CallCache
+
 	// Add more configuration options as needed
+
+	// ontologies caches ontologies loaded from $schemas, keyed by path
+	ontologies map[string]*Ontology
+	ontologyMu sync.Mutex
+
+	// janitorRan records the runtimes whose orphaned containers have
+	// already been removed by this executor
+	janitorRan map[string]bool
+	janitorMu  sync.Mutex
+
+	// scheduler bounds the workflow jobs running at once by MaxCores and
+	// MaxRAM
+	scheduler   *Scheduler
+	schedulerMu sync.Mutex
 }
 
 // NewExecutor creates a new executor with default settings
@@ -29,15 +82,24 @@ func NewExecutor() *Executor {
 		SingularityEnabled: true,
 		MaxCores:           4,
 		MaxRAM:             8192, // 8 GiB
+		DockerRuntimes:     []string{"docker", "podman", "singularity"},
+		CaptureLimit:       DefaultCaptureLimit,
 	}
 }
 
 // ExecuteResult contains the results of executing a CommandLineTool
 type ExecuteResult struct {
-	ExitCode    int
-	Stdout      string
-	Stderr      string
-	OutputFiles map[string]string // Output ID -> File path
+	ExitCode        int
+	Stdout          string                 // Captured stdout, at most Executor.CaptureLimit bytes
+	Stderr          string                 // Captured stderr, at most Executor.CaptureLimit bytes
+	StdoutPath      string                 // File stdout was redirected to, if any
+	StderrPath      string                 // File stderr was redirected to, if any
+	StdoutTruncated bool                   // Whether Stdout was cut off at CaptureLimit
+	StderrTruncated bool                   // Whether Stderr was cut off at CaptureLimit
+	OutputFiles     map[string]string      // Output ID -> File path
+	Outputs         map[string]interface{} // Output ID -> CWL File object
+	Cached          bool                   // Whether the outputs were reused from Executor.Cache
+	CacheErr        error                  // Why the outputs could not be stored in Executor.Cache, if so
 }
 
 // Execute executes a CommandLineTool with the given inputs
@@ -49,6 +111,11 @@ func (e *Executor) Execute(ctx context.Context, tool *CommandLineTool, inputs ma
 	}
 	defer execCtx.Cleanup()
 
+	return e.execute(ctx, tool, inputs, execCtx)
+}
+
+// execute runs a CommandLineTool in the given execution context
+func (e *Executor) execute(ctx context.Context, tool *CommandLineTool, inputs map[string]interface{}, execCtx *ExecutionContext) (*ExecuteResult, error) {
 	// Set inputs
 	execCtx.Inputs = inputs
 
@@ -57,12 +124,45 @@ func (e *Executor) Execute(ctx context.Context, tool *CommandLineTool, inputs ma
 		return nil, err
 	}
 
+	// Check File inputs against their declared formats, which may be
+	// expressions needing InlineJavascriptRequirement
+	if err := e.checkInputFormats(tool, execCtx); err != nil {
+		return nil, err
+	}
+
+	// Mount the output and temporary directories and input Files and
+	// Directories into the container
+	e.mountJobDirs(execCtx)
+	if err := e.stageInputs(tool, execCtx); err != nil {
+		return nil, err
+	}
+
+	// Evaluate and validate stdin, stdout and stderr
+	if err := e.resolveRedirects(tool, execCtx); err != nil {
+		return nil, err
+	}
+
 	// Build command line
 	cmdArgs, err := e.BuildCommandLine(tool, execCtx)
 	if err != nil {
 		return nil, err
 	}
 
+	// Reuse the outputs of an identical earlier job
+	cacheKey, err := e.cacheKey(tool, cmdArgs, execCtx)
+	if err != nil {
+		return nil, err
+	}
+	if cacheKey != "" {
+		if result, ok := e.Cache.Restore(cacheKey, execCtx); ok {
+			result.Outputs, err = e.buildOutputObjects(tool, execCtx, result.OutputFiles)
+			if err != nil {
+				return nil, err
+			}
+			return result, nil
+		}
+	}
+
 	// Execute command
 	result, err := e.runCommand(ctx, tool, cmdArgs, execCtx)
 	if err != nil {
@@ -75,12 +175,42 @@ func (e *Executor) Execute(ctx context.Context, tool *CommandLineTool, inputs ma
 		return nil, err
 	}
 
+	// Map container paths back to host paths
+	for outputID, path := range outputFiles {
+		outputFiles[outputID] = execCtx.HostPath(path)
+	}
+
 	result.OutputFiles = outputFiles
+	result.Outputs, err = e.buildOutputObjects(tool, execCtx, outputFiles)
+	if err != nil {
+		return nil, err
+	}
+
+	// A job that cannot be cached still succeeded, so the error is only
+	// reported with the result
+	if cacheKey != "" {
+		result.CacheErr = e.Cache.Store(cacheKey, result, execCtx)
+	}
 	return result, nil
 }
 
+// cacheKey returns the call cache key of a job, or "" when there is no
+// cache or the tool disables reuse
+func (e *Executor) cacheKey(tool *CommandLineTool, cmdArgs []string, execCtx *ExecutionContext) (string, error) {
+	if e.Cache == nil {
+		return "", nil
+	}
+	enabled, err := workReuseEnabled(tool, execCtx)
+	if err != nil || !enabled {
+		return "", err
+	}
+	return e.Cache.Key(tool, cmdArgs, execCtx)
+}
+
 // processRequirements processes the requirements of a CommandLineTool
 func (e *Executor) processRequirements(tool *CommandLineTool, ctx *ExecutionContext) error {
+	var networkAccess interface{}
+
 	for _, reqMap := range tool.Requirements {
 		// Get the class of the requirement
 		class, ok := reqMap["class"].(string)
@@ -93,6 +223,9 @@ func (e *Executor) processRequirements(tool *CommandLineTool, ctx *ExecutionCont
 
 		switch class {
 		case "DockerRequirement":
+			if e.NoContainer {
+				continue
+			}
 			if !e.DockerEnabled {
 				return &CWLError{
 					Err:     ErrExecution,
@@ -100,7 +233,7 @@ func (e *Executor) processRequirements(tool *CommandLineTool, ctx *ExecutionCont
 				}
 			}
 
-			// Create container config for Docker
+			// Create container config for Docker; the runtime is chosen below
 			containerConfig := &ContainerConfig{
 				Type:    "docker",
 				Volumes: []string{},
@@ -145,18 +278,33 @@ func (e *Executor) processRequirements(tool *CommandLineTool, ctx *ExecutionCont
 				}
 			}
 
-			// Check if Docker is available
-			if err := checkDockerAvailable(); err != nil {
+			// Pick the first available Docker-compatible runtime
+			var preference []string
+			for _, name := range e.DockerRuntimes {
+				if name == "singularity" && !e.SingularityEnabled {
+					continue
+				}
+				preference = append(preference, name)
+			}
+			if len(e.DockerRuntimes) == 0 {
+				preference = []string{"docker", "podman"}
+			}
+			runtimeName, err := selectRuntime(preference)
+			if err != nil {
 				return &CWLError{
 					Err:     err,
 					Message: "Docker is required but not available",
 				}
 			}
+			containerConfig.Type = runtimeName
 
 			// Store container config in execution context
 			ctx.Container = containerConfig
 
 		case "SingularityRequirement":
+			if e.NoContainer {
+				continue
+			}
 			if !e.SingularityEnabled {
 				return &CWLError{
 					Err:     ErrExecution,
@@ -210,7 +358,7 @@ func (e *Executor) processRequirements(tool *CommandLineTool, ctx *ExecutionCont
 			}
 
 			// Check if Singularity is available
-			if err := checkSingularityAvailable(); err != nil {
+			if err := checkRuntimeAvailable("singularity"); err != nil {
 				return &CWLError{
 					Err:     err,
 					Message: "Singularity is required but not available",
@@ -277,6 +425,7 @@ func (e *Executor) processRequirements(tool *CommandLineTool, ctx *ExecutionCont
 						Message: fmt.Sprintf("required cores (%f) exceeds maximum (%d)", coresMin, e.MaxCores),
 					}
 				}
+				ctx.Cores = int(coresMin)
 			}
 
 			if ramMin, ok := reqMap["ramMin"].(float64); ok {
@@ -286,17 +435,231 @@ func (e *Executor) processRequirements(tool *CommandLineTool, ctx *ExecutionCont
 						Message: fmt.Sprintf("required RAM (%f MiB) exceeds maximum (%d MiB)", ramMin, e.MaxRAM),
 					}
 				}
+				ctx.RAM = int64(ramMin)
+			}
+
+		case "InlineJavascriptRequirement":
+			// Enable JavaScript in expressions
+			ctx.JavaScript = true
+
+		case "NetworkAccess":
+			// Evaluated below, once InlineJavascriptRequirement has been seen
+			value, ok := reqMap["networkAccess"]
+			if !ok {
+				return &CWLError{
+					Err:     ErrExecution,
+					Message: "NetworkAccess must have a 'networkAccess' field",
+				}
+			}
+			networkAccess = value
+
+		case "WorkReuse":
+			// Checked by workReuseEnabled when a call cache is in use
+			if _, ok := reqMap["enableReuse"]; !ok {
+				return &CWLError{
+					Err:     ErrExecution,
+					Message: "WorkReuse must have an 'enableReuse' field",
+				}
 			}
 
 		default:
 			// Unknown requirement type
 			return &CWLError{
-				Err:     ErrExecution,
+				Err:     ErrUnsupported,
 				Message: fmt.Sprintf("unsupported requirement class: %s", class),
 			}
 		}
 	}
 
+	// A NetworkAccess hint applies when there is no requirement
+	if networkAccess == nil {
+		for _, hint := range tool.Hints {
+			if hint["class"] == "NetworkAccess" {
+				networkAccess = hint["networkAccess"]
+			}
+		}
+	}
+
+	if networkAccess != nil {
+		enabled, err := evaluateNetworkAccess(networkAccess, tool, ctx)
+		if err != nil {
+			return err
+		}
+		ctx.NetworkAccess = enabled
+	}
+
+	if ctx.Container != nil {
+		ctx.Container.NetworkIsolation = e.SingularityNetworkIsolation
+	}
+
+	return nil
+}
+
+// evaluateNetworkAccess returns the value of a NetworkAccess requirement's
+// networkAccess field, which is a boolean or an expression yielding one
+func evaluateNetworkAccess(value interface{}, tool *CommandLineTool, ctx *ExecutionContext) (bool, error) {
+	if expr, ok := value.(string); ok {
+		evaluated, err := newExpressionContext(tool, ctx).EvaluateString(expr)
+		if err != nil {
+			return false, &CWLError{
+				Err:     err,
+				Message: "failed to evaluate networkAccess",
+			}
+		}
+		value = evaluated
+	}
+
+	enabled, ok := value.(bool)
+	if !ok {
+		return false, &CWLError{
+			Err:     ErrExecution,
+			Message: fmt.Sprintf("networkAccess must be a boolean, got %v", value),
+		}
+	}
+	return enabled, nil
+}
+
+// newExpressionContext returns the context for evaluating a tool's
+// expressions: inputs with defaults applied and File properties filled in,
+// and the runtime object
+func newExpressionContext(tool *CommandLineTool, execCtx *ExecutionContext) *ExpressionContext {
+	inputs := make(map[string]interface{}, len(tool.Inputs))
+	for inputID, inputParam := range tool.Inputs {
+		if inputParam.Default != nil {
+			inputs[inputID] = fillFileProperties(inputParam.Default)
+		}
+	}
+	for inputID, value := range execCtx.Inputs {
+		inputs[inputID] = fillFileProperties(value)
+	}
+
+	return &ExpressionContext{
+		Inputs: inputs,
+		Runtime: map[string]interface{}{
+			"outdir": execCtx.ContainerPath(execCtx.OutputDir),
+			"tmpdir": execCtx.ContainerPath(execCtx.TempDir),
+			"cores":  float64(execCtx.Cores),
+			"ram":    float64(execCtx.RAM),
+		},
+		JavaScript: execCtx.JavaScript,
+	}
+}
+
+// fillFileProperties returns a copy of a value with the basename, nameroot,
+// nameext and dirname of each File or Directory filled in from its path
+func fillFileProperties(value interface{}) interface{} {
+	switch v := value.(type) {
+	case map[string]interface{}:
+		class, _ := v["class"].(string)
+		if class != "File" && class != "Directory" {
+			return v
+		}
+
+		file := make(map[string]interface{}, len(v)+4)
+		for k, item := range v {
+			file[k] = item
+		}
+
+		path, _ := file["path"].(string)
+		if path == "" {
+			if location, ok := file["location"].(string); ok {
+				path = strings.TrimPrefix(location, "file://")
+				file["path"] = path
+			}
+		}
+		if path == "" {
+			return file
+		}
+
+		basename := filepath.Base(path)
+		if _, ok := file["basename"]; !ok {
+			file["basename"] = basename
+		}
+		if _, ok := file["dirname"]; !ok {
+			file["dirname"] = filepath.Dir(path)
+		}
+		if class == "File" {
+			ext := filepath.Ext(basename)
+			if _, ok := file["nameext"]; !ok {
+				file["nameext"] = ext
+			}
+			if _, ok := file["nameroot"]; !ok {
+				file["nameroot"] = strings.TrimSuffix(basename, ext)
+			}
+		}
+		return file
+
+	case []interface{}:
+		items := make([]interface{}, len(v))
+		for i, item := range v {
+			items[i] = fillFileProperties(item)
+		}
+		return items
+	}
+
+	return value
+}
+
+// resolveRedirects evaluates the tool's stdin, stdout and stderr fields and
+// stores the results on the execution context. stdout and stderr must stay
+// inside the output directory.
+func (e *Executor) resolveRedirects(tool *CommandLineTool, execCtx *ExecutionContext) error {
+	exprCtx := newExpressionContext(tool, execCtx)
+
+	if tool.Stdin != "" {
+		stdin, err := exprCtx.EvaluateToString(tool.Stdin)
+		if err != nil {
+			return err
+		}
+		if stdin != "" && !filepath.IsAbs(stdin) {
+			stdin = filepath.Join(execCtx.WorkingDir, stdin)
+		}
+		// Inputs may refer to container paths; stdin is opened on the host
+		execCtx.Stdin = execCtx.HostPath(stdin)
+	}
+
+	for _, redirect := range []struct {
+		field  string
+		value  string
+		target *string
+	}{
+		{"stdout", tool.Stdout, &execCtx.Stdout},
+		{"stderr", tool.Stderr, &execCtx.Stderr},
+	} {
+		if redirect.value == "" {
+			continue
+		}
+
+		name, err := exprCtx.EvaluateToString(redirect.value)
+		if err != nil {
+			return err
+		}
+		if name == "" {
+			continue
+		}
+
+		clean := filepath.Clean(name)
+		if filepath.IsAbs(clean) || clean == "." || clean == ".." || strings.HasPrefix(clean, ".."+string(filepath.Separator)) {
+			return &CWLError{
+				Err:     ErrExecution,
+				Message: fmt.Sprintf("%s must be a relative path inside the output directory: %s", redirect.field, name),
+			}
+		}
+		*redirect.target = clean
+	}
+
+	// stdout and stderr outputs refer to the file the stream was written
+	// to; as in the CWL spec, the file gets a random name in the output
+	// directory when the tool does not name one
+	for _, outputParam := range tool.Outputs {
+		switch {
+		case outputParam.Type == "stdout" && execCtx.Stdout == "":
+			execCtx.Stdout = "stdout-" + newRunID()
+		case outputParam.Type == "stderr" && execCtx.Stderr == "":
+			execCtx.Stderr = "stderr-" + newRunID()
+		}
+	}
+
 	return nil
 }
 
@@ -311,8 +674,10 @@ func (e *Executor) BuildCommandLine(tool *CommandLineTool, ctx *ExecutionContext
 	var cmdArgs []string
 	var posArgs []CommandArg
 
-	// Add base command
+	// Add base command; without one, the arguments and input bindings make
+	// up the whole command line
 	switch cmd := tool.BaseCommand.(type) {
+	case nil:
 	case string:
 		cmdArgs = append(cmdArgs, cmd)
 	case []interface{}:
@@ -334,11 +699,19 @@ func (e *Executor) BuildCommandLine(tool *CommandLineTool, ctx *ExecutionContext
 	}
 
 	// Collect arguments with positions
+	exprCtx := newExpressionContext(tool, ctx)
 	for _, arg := range tool.Arguments {
 		var argStrings []string
 
 		// Handle arguments with valueFrom
 		if valueFrom, ok := arg.ValueFrom.(string); ok {
+			// Evaluate expressions such as $(inputs.reads.path)
+			evaluated, err := exprCtx.EvaluateToString(valueFrom)
+			if err != nil {
+				return nil, err
+			}
+			valueFrom = evaluated
+
 			if arg.Prefix != "" {
 				// Handle separate flag
 				separate := true
@@ -381,7 +754,7 @@ func (e *Executor) BuildCommandLine(tool *CommandLineTool, ctx *ExecutionContext
 		if !ok {
 			// Check if there's a default value
 			if inputParam.Default != nil {
-				inputValue = inputParam.Default
+				inputValue = fillFileProperties(inputParam.Default)
 			} else {
 				return nil, &CWLError{
 					Err:     ErrExecution,
@@ -490,6 +863,13 @@ func (e *Executor) BuildCommandLine(tool *CommandLineTool, ctx *ExecutionContext
 		cmdArgs = append(cmdArgs, arg.Args...)
 	}
 
+	if len(cmdArgs) == 0 {
+		return nil, &CWLError{
+			Err:     ErrExecution,
+			Message: "command line is empty: the tool has no baseCommand and its arguments and input bindings produced none",
+		}
+	}
+
 	return cmdArgs, nil
 }
 
@@ -504,10 +884,60 @@ func (e *Executor) runCommand(ctx context.Context, tool *CommandLineTool, cmdArg
 
 	// Check if we need to run in a container
 	if execCtx.Container != nil {
-		return e.runContainerCommand(ctx, tool, cmdArgs, execCtx)
+		runtime, err := LookupContainerRuntime(execCtx.Container.Type)
+		if err != nil {
+			return nil, err
+		}
+
+		// Remove containers left behind by earlier crashed runs
+		e.removeOrphans(ctx, runtime)
+
+		// Make the image available if the runtime needs to
+		if preparer, ok := runtime.(ContainerPreparer); ok {
+			if err := preparer.Prepare(ctx, execCtx); err != nil {
+				return nil, err
+			}
+		}
+
+		cmdArgs, err = runtime.WrapCommand(cmdArgs, execCtx)
+		if err != nil {
+			return nil, err
+		}
+
+		// Clean up container resources if the runtime creates any
+		if cleaner, ok := runtime.(ContainerCleaner); ok {
+			defer cleaner.Cleanup(execCtx)
+		}
+	}
+
+	return e.launch(ctx, tool, cmdArgs, execCtx)
+}
+
+// removeOrphans runs a runtime's janitor the first time the runtime is used
+// by this executor. It is best effort: a failure must not fail the job.
+func (e *Executor) removeOrphans(ctx context.Context, runtime ContainerRuntime) {
+	janitor, ok := runtime.(ContainerJanitor)
+	if !ok {
+		return
+	}
+
+	e.janitorMu.Lock()
+	defer e.janitorMu.Unlock()
+
+	if e.janitorRan[runtime.Name()] {
+		return
+	}
+	if e.janitorRan == nil {
+		e.janitorRan = make(map[string]bool)
 	}
+	e.janitorRan[runtime.Name()] = true
 
-	// Create command for direct execution
+	janitor.RemoveOrphans(ctx)
+}
+
+// launch runs a host command line, streaming its I/O and mapping its exit
+// status. It is shared by local and container execution.
+func (e *Executor) launch(ctx context.Context, tool *CommandLineTool, cmdArgs []string, execCtx *ExecutionContext) (*ExecuteResult, error) {
 	cmd := exec.CommandContext(ctx, cmdArgs[0], cmdArgs[1:]...)
 	cmd.Dir = execCtx.WorkingDir
 
@@ -517,59 +947,19 @@ func (e *Executor) runCommand(ctx context.Context, tool *CommandLineTool, cmdArg
 		cmd.Env = append(cmd.Env, fmt.Sprintf("%s=%s", name, value))
 	}
 
-	// Set up stdout and stderr
-	var stdout, stderr bytes.Buffer
-	cmd.Stdout = &stdout
-	cmd.Stderr = &stderr
-
-	// Handle stdin if specified
-	if tool.Stdin != "" {
-		// In a real implementation, we would evaluate expressions here
-		stdinFile, err := os.Open(tool.Stdin)
-		if err != nil {
-			return nil, &CWLError{
-				Err:     err,
-				Message: fmt.Sprintf("failed to open stdin file: %s", tool.Stdin),
-			}
-		}
-		defer stdinFile.Close()
-		cmd.Stdin = stdinFile
-	}
-
-	// Handle stdout if specified
-	if tool.Stdout != "" {
-		stdoutPath := filepath.Join(execCtx.OutputDir, tool.Stdout)
-		stdoutFile, err := os.Create(stdoutPath)
-		if err != nil {
-			return nil, &CWLError{
-				Err:     err,
-				Message: fmt.Sprintf("failed to create stdout file: %s", stdoutPath),
-			}
-		}
-		defer stdoutFile.Close()
-
-		// Use MultiWriter to capture stdout both in memory and in file
-		cmd.Stdout = io.MultiWriter(&stdout, stdoutFile)
+	// Stream stdin, stdout and stderr
+	streams, err := e.openStreams(execCtx)
+	if err != nil {
+		return nil, err
 	}
+	defer streams.Close()
 
-	// Handle stderr if specified
-	if tool.Stderr != "" {
-		stderrPath := filepath.Join(execCtx.OutputDir, tool.Stderr)
-		stderrFile, err := os.Create(stderrPath)
-		if err != nil {
-			return nil, &CWLError{
-				Err:     err,
-				Message: fmt.Sprintf("failed to create stderr file: %s", stderrPath),
-			}
-		}
-		defer stderrFile.Close()
-
-		// Use MultiWriter to capture stderr both in memory and in file
-		cmd.Stderr = io.MultiWriter(&stderr, stderrFile)
-	}
+	cmd.Stdin = streams.Stdin
+	cmd.Stdout = streams.Stdout
+	cmd.Stderr = streams.Stderr
 
 	// Run the command
-	err := cmd.Run()
+	err = cmd.Run()
 	exitCode := 0
 	if err != nil {
 		// Check if it's an exit error
@@ -597,11 +987,7 @@ func (e *Executor) runCommand(ctx context.Context, tool *CommandLineTool, cmdArg
 		}
 	}
 
-	return &ExecuteResult{
-		ExitCode: exitCode,
-		Stdout:   stdout.String(),
-		Stderr:   stderr.String(),
-	}, err
+	return streams.result(exitCode), err
 }
 
 // processOutputs processes the outputs of a CommandLineTool
@@ -609,6 +995,20 @@ func (e *Executor) processOutputs(tool *CommandLineTool, ctx *ExecutionContext,
 	outputFiles := make(map[string]string)
 
 	for outputID, outputParam := range tool.Outputs {
+		// stdout and stderr outputs refer to the files the streams were written to
+		switch outputParam.Type {
+		case "stdout":
+			if result.StdoutPath != "" {
+				outputFiles[outputID] = result.StdoutPath
+			}
+			continue
+		case "stderr":
+			if result.StderrPath != "" {
+				outputFiles[outputID] = result.StderrPath
+			}
+			continue
+		}
+
 		if outputParam.Binding == nil {
 			continue
 		}
@@ -662,255 +1062,37 @@ func (e *Executor) processOutputs(tool *CommandLineTool, ctx *ExecutionContext,
 	return outputFiles, nil
 }
 
-// checkDockerAvailable checks if Docker is available on the system
-func checkDockerAvailable() error {
-	cmd := exec.Command("docker", "--version")
-	var stderr bytes.Buffer
-	cmd.Stderr = &stderr
-
-	if err := cmd.Run(); err != nil {
-		return fmt.Errorf("docker not available: %v - %s", err, stderr.String())
-	}
-
-	return nil
-}
-
-// checkSingularityAvailable checks if Singularity/Apptainer is available on the system
-func checkSingularityAvailable() error {
-	// Try singularity first
-	cmd := exec.Command("singularity", "--version")
-	var stderr bytes.Buffer
-	cmd.Stderr = &stderr
-
-	if err := cmd.Run(); err == nil {
-		return nil
-	}
-
-	// If singularity fails, try apptainer
-	cmd = exec.Command("apptainer", "--version")
-	stderr.Reset()
-	cmd.Stderr = &stderr
-
-	if err := cmd.Run(); err != nil {
-		return fmt.Errorf("neither singularity nor apptainer available: %v - %s", err, stderr.String())
-	}
-
-	return nil
-}
-
-// runContainerCommand executes a command inside a container
-func (e *Executor) runContainerCommand(ctx context.Context, tool *CommandLineTool, cmdArgs []string, execCtx *ExecutionContext) (*ExecuteResult, error) {
-	var containerCmd []string
-	var stdout, stderr bytes.Buffer
-
-	// Prepare container command based on container type
-	switch execCtx.Container.Type {
-	case "docker":
-		// Build Docker command
-		containerCmd = e.buildDockerCommand(cmdArgs, execCtx)
-	case "singularity":
-		// Build Singularity command
-		containerCmd = e.buildSingularityCommand(cmdArgs, execCtx)
-	default:
-		return nil, &CWLError{
-			Err:     ErrExecution,
-			Message: fmt.Sprintf("unsupported container type: %s", execCtx.Container.Type),
+// buildOutputObjects converts output paths into CWL File objects, tagging
+// each with the format declared on its output parameter
+func (e *Executor) buildOutputObjects(tool *CommandLineTool, execCtx *ExecutionContext, outputFiles map[string]string) (map[string]interface{}, error) {
+	outputs := make(map[string]interface{})
+	exprCtx := newExpressionContext(tool, execCtx)
+
+	for outputID, path := range outputFiles {
+		file := map[string]interface{}{
+			"class":    "File",
+			"location": "file://" + path,
+			"path":     path,
+			"basename": filepath.Base(path),
 		}
-	}
-
-	// Create command
-	cmd := exec.CommandContext(ctx, containerCmd[0], containerCmd[1:]...)
-	cmd.Dir = execCtx.WorkingDir
-
-	// Set environment variables
-	cmd.Env = os.Environ()
-	for name, value := range execCtx.EnvironmentVars {
-		cmd.Env = append(cmd.Env, fmt.Sprintf("%s=%s", name, value))
-	}
 
-	// Set up stdout and stderr
-	cmd.Stdout = &stdout
-	cmd.Stderr = &stderr
-
-	// Handle stdin if specified
-	if tool.Stdin != "" {
-		stdinFile, err := os.Open(tool.Stdin)
-		if err != nil {
-			return nil, &CWLError{
-				Err:     err,
-				Message: fmt.Sprintf("failed to open stdin file: %s", tool.Stdin),
-			}
+		if info, err := os.Stat(path); err == nil {
+			file["size"] = info.Size()
 		}
-		defer stdinFile.Close()
-		cmd.Stdin = stdinFile
-	}
 
-	// Handle stdout if specified
-	if tool.Stdout != "" {
-		stdoutPath := filepath.Join(execCtx.OutputDir, tool.Stdout)
-		stdoutFile, err := os.Create(stdoutPath)
+		format, err := outputFormat(tool.Outputs[outputID].Format, exprCtx, tool.Namespaces)
 		if err != nil {
 			return nil, &CWLError{
 				Err:     err,
-				Message: fmt.Sprintf("failed to create stdout file: %s", stdoutPath),
+				Message: fmt.Sprintf("output %s", outputID),
 			}
 		}
-		defer stdoutFile.Close()
-
-		// Use MultiWriter to capture stdout both in memory and in file
-		cmd.Stdout = io.MultiWriter(&stdout, stdoutFile)
-	}
-
-	// Handle stderr if specified
-	if tool.Stderr != "" {
-		stderrPath := filepath.Join(execCtx.OutputDir, tool.Stderr)
-		stderrFile, err := os.Create(stderrPath)
-		if err != nil {
-			return nil, &CWLError{
-				Err:     err,
-				Message: fmt.Sprintf("failed to create stderr file: %s", stderrPath),
-			}
+		if format != "" {
+			file["format"] = format
 		}
-		defer stderrFile.Close()
-
-		// Use MultiWriter to capture stderr both in memory and in file
-		cmd.Stderr = io.MultiWriter(&stderr, stderrFile)
-	}
-
-	// Run the command
-	err := cmd.Run()
-	exitCode := 0
-	if err != nil {
-		// Check if it's an exit error
-		if exitErr, ok := err.(*exec.ExitError); ok {
-			exitCode = exitErr.ExitCode()
-
-			// Check if the exit code is in the success codes
-			isSuccess := false
-			for _, code := range tool.SuccessCodes {
-				if exitCode == code {
-					isSuccess = true
-					break
-				}
-			}
-
-			if isSuccess {
-				// This is a successful exit code
-				err = nil
-			}
-		} else {
-			return nil, &CWLError{
-				Err:     err,
-				Message: "container command execution failed",
-			}
-		}
-	}
 
-	// Clean up container if needed (for Docker)
-	if execCtx.Container.Type == "docker" {
-		e.cleanupDockerContainer(execCtx)
+		outputs[outputID] = file
 	}
 
-	return &ExecuteResult{
-		ExitCode: exitCode,
-		Stdout:   stdout.String(),
-		Stderr:   stderr.String(),
-	}, err
-}
-
-// buildDockerCommand builds a Docker command for executing a tool
-func (e *Executor) buildDockerCommand(cmdArgs []string, execCtx *ExecutionContext) []string {
-	containerCmd := []string{"docker", "run", "--rm"}
-
-	// Add volume mounts
-	containerCmd = append(containerCmd, "-v", fmt.Sprintf("%s:%s", execCtx.WorkingDir, execCtx.WorkingDir))
-	containerCmd = append(containerCmd, "-v", fmt.Sprintf("%s:%s", execCtx.OutputDir, execCtx.OutputDir))
-
-	// Add additional volumes if specified
-	for _, volume := range execCtx.Container.Volumes {
-		containerCmd = append(containerCmd, "-v", volume)
-	}
-
-	// Set working directory
-	containerCmd = append(containerCmd, "-w", execCtx.WorkingDir)
-
-	// Add environment variables
-	for name, value := range execCtx.EnvironmentVars {
-		containerCmd = append(containerCmd, "-e", fmt.Sprintf("%s=%s", name, value))
-	}
-
-	// Add container environment variables if specified
-	for _, env := range execCtx.Container.EnvVars {
-		containerCmd = append(containerCmd, "-e", env)
-	}
-
-	// Add image
-	if execCtx.Container.ImageID != "" {
-		containerCmd = append(containerCmd, execCtx.Container.ImageID)
-	} else {
-		containerCmd = append(containerCmd, execCtx.Container.Image)
-	}
-
-	// Add command and arguments
-	containerCmd = append(containerCmd, cmdArgs...)
-
-	return containerCmd
-}
-
-// buildSingularityCommand builds a Singularity command for executing a tool
-func (e *Executor) buildSingularityCommand(cmdArgs []string, execCtx *ExecutionContext) []string {
-	// Determine if we're using singularity or apptainer
-	singularityCmd := "singularity"
-	if _, err := exec.LookPath("singularity"); err != nil {
-		singularityCmd = "apptainer"
-	}
-
-	containerCmd := []string{singularityCmd, "exec"}
-
-	// Add bind mounts
-	bindMounts := []string{
-		fmt.Sprintf("%s:%s", execCtx.WorkingDir, execCtx.WorkingDir),
-		fmt.Sprintf("%s:%s", execCtx.OutputDir, execCtx.OutputDir),
-	}
-
-	// Add additional volumes if specified
-	for _, volume := range execCtx.Container.Volumes {
-		bindMounts = append(bindMounts, volume)
-	}
-
-	// Join all bind mounts
-	if len(bindMounts) > 0 {
-		containerCmd = append(containerCmd, "--bind", strings.Join(bindMounts, ","))
-	}
-
-	// Set working directory
-	containerCmd = append(containerCmd, "--pwd", execCtx.WorkingDir)
-
-	// Add environment variables
-	for name, value := range execCtx.EnvironmentVars {
-		containerCmd = append(containerCmd, "--env", fmt.Sprintf("%s=%s", name, value))
-	}
-
-	// Add container environment variables if specified
-	for _, env := range execCtx.Container.EnvVars {
-		containerCmd = append(containerCmd, "--env", env)
-	}
-
-	// Add image
-	if execCtx.Container.ImageID != "" {
-		containerCmd = append(containerCmd, execCtx.Container.ImageID)
-	} else {
-		containerCmd = append(containerCmd, execCtx.Container.Image)
-	}
-
-	// Add command and arguments
-	containerCmd = append(containerCmd, cmdArgs...)
-
-	return containerCmd
-}
-
-// cleanupDockerContainer cleans up any Docker containers created during execution
-func (e *Executor) cleanupDockerContainer(execCtx *ExecutionContext) {
-	// This is a placeholder for container cleanup logic
-	// In a real implementation, we would track container IDs and remove them
+	return outputs, nil
 }
@@ -4,6 +4,7 @@ import (
 	"context"
 	"os"
 	"path/filepath"
+	"reflect"
 	"strings"
 	"testing"
 )
@@ -129,6 +130,72 @@ func TestBuildCommandLine(t *testing.T) {
 			t.Errorf("Expected argument %d to be %s, got %s", i, arg, cmdArgs[i])
 		}
 	}
+
+	// Default Files given by location, as the parser resolves them
+	file := tool.Inputs["file"]
+	file.Default = map[string]interface{}{"class": "File", "location": "file:///data/default.txt"}
+	tool.Inputs["file"] = file
+	delete(execCtx.Inputs, "file")
+
+	cmdArgs, err = executor.BuildCommandLine(tool, execCtx)
+	if err != nil {
+		t.Fatalf("Failed to build command line with a default File: %v", err)
+	}
+	if cmdArgs[len(cmdArgs)-1] != "/data/default.txt" {
+		t.Errorf("Expected the default File's path last, got %v", cmdArgs)
+	}
+}
+
+func TestBuildCommandLineWithoutBaseCommand(t *testing.T) {
+	content := `
+cwlVersion: v1.2
+class: CommandLineTool
+arguments: [sh, $(inputs.script.path)]
+inputs:
+  script:
+    type: File
+  name:
+    type: string
+    inputBinding:
+      position: 1
+outputs: {}
+`
+	dir := t.TempDir()
+	toolPath := filepath.Join(dir, "script.cwl")
+	if err := os.WriteFile(toolPath, []byte(content), 0644); err != nil {
+		t.Fatalf("Failed to write tool: %v", err)
+	}
+
+	tool, err := NewParser().ParseFile(toolPath)
+	if err != nil {
+		t.Fatalf("Failed to parse tool without baseCommand: %v", err)
+	}
+
+	execCtx, err := NewExecutionContext(dir)
+	if err != nil {
+		t.Fatalf("Failed to create execution context: %v", err)
+	}
+	defer execCtx.Cleanup()
+	execCtx.Inputs = map[string]interface{}{
+		"script": map[string]interface{}{"class": "File", "path": "/scripts/run.sh"},
+		"name":   "world",
+	}
+
+	cmdArgs, err := NewExecutor().BuildCommandLine(tool, execCtx)
+	if err != nil {
+		t.Fatalf("Failed to build command line: %v", err)
+	}
+	expectedArgs := []string{"sh", "/scripts/run.sh", "world"}
+	if !reflect.DeepEqual(cmdArgs, expectedArgs) {
+		t.Errorf("Expected %v, got %v", expectedArgs, cmdArgs)
+	}
+
+	// Without arguments or bound inputs there is no command to run
+	tool.Arguments = nil
+	tool.Inputs = nil
+	if _, err := NewExecutor().BuildCommandLine(tool, execCtx); err == nil {
+		t.Error("Expected error for an empty command line, got nil")
+	}
 }
 
 func TestProcessRequirements(t *testing.T) {
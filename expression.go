@@ -0,0 +1,372 @@
+package cwlgo
+
+import (
+	"encoding/json"
+	"fmt"
+	"math"
+	"regexp"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/dop251/goja"
+)
+
+// ExpressionContext holds the values visible to CWL expressions.
+//
+// Without JavaScript only parameter references such as
+// $(inputs.reads.path) or $(inputs['sample']) are allowed. With JavaScript
+// (InlineJavascriptRequirement) $(...) expressions and ${...} function
+// bodies are evaluated by an ECMAScript 5.1 engine, against copies of the
+// values so that expressions cannot change them.
+type ExpressionContext struct {
+	Inputs     map[string]interface{}
+	Self       interface{}
+	Runtime    map[string]interface{}
+	JavaScript bool
+}
+
+// expressionTimeout bounds how long a JavaScript expression may run
+const expressionTimeout = 10 * time.Second
+
+// paramRefPattern matches a CWL parameter reference, and paramRefSegment
+// each of its property and index accesses
+var paramRefPattern = regexp.MustCompile(`^\s*(inputs|self|runtime)(\.[A-Za-z_][A-Za-z0-9_]*|\['(?:[^'\\]|\\.)*'\]|\["(?:[^"\\]|\\.)*"\]|\[[0-9]+\])*\s*$`)
+var paramRefSegment = regexp.MustCompile(`\.([A-Za-z_][A-Za-z0-9_]*)|\['((?:[^'\\]|\\.)*)'\]|\["((?:[^"\\]|\\.)*)"\]|\[([0-9]+)\]`)
+
+// IsExpression reports whether a string contains a CWL expression
+func IsExpression(s string) bool {
+	return strings.Contains(s, "$(") || strings.Contains(s, "${")
+}
+
+// Evaluate evaluates a field that may hold an expression. Non-string values
+// are returned unchanged.
+func (c *ExpressionContext) Evaluate(value interface{}) (interface{}, error) {
+	s, ok := value.(string)
+	if !ok {
+		return value, nil
+	}
+	return c.EvaluateString(s)
+}
+
+// EvaluateString evaluates the expressions in s. A string consisting of
+// exactly one expression evaluates to that expression's value; otherwise
+// each expression is interpolated into the string.
+func (c *ExpressionContext) EvaluateString(s string) (interface{}, error) {
+	if !IsExpression(s) {
+		return s, nil
+	}
+
+	var out strings.Builder
+	var single interface{}
+	parts := 0
+	literal := false
+
+	for i := 0; i < len(s); {
+		// \$( and \${ escape an expression
+		if s[i] == '\\' && i+2 < len(s) && s[i+1] == '$' && (s[i+2] == '(' || s[i+2] == '{') {
+			out.WriteString(s[i+1 : i+3])
+			literal = true
+			i += 3
+			continue
+		}
+
+		if s[i] == '$' && i+1 < len(s) && (s[i+1] == '(' || s[i+1] == '{') {
+			end, err := matchingBracket(s, i+1)
+			if err != nil {
+				return nil, err
+			}
+
+			var value interface{}
+			if s[i+1] == '(' {
+				value, err = c.evalExpression(s[i+2 : end])
+			} else {
+				value, err = c.evalFunctionBody(s[i+2 : end])
+			}
+			if err != nil {
+				return nil, &CWLError{
+					Err:     err,
+					Message: fmt.Sprintf("failed to evaluate expression %s", s[i:end+1]),
+				}
+			}
+
+			single = value
+			parts++
+			out.WriteString(jsString(value))
+			i = end + 1
+			continue
+		}
+
+		out.WriteByte(s[i])
+		literal = true
+		i++
+	}
+
+	if parts == 1 && !literal {
+		return single, nil
+	}
+	return out.String(), nil
+}
+
+// EvaluateToString evaluates s and converts the result to a string
+func (c *ExpressionContext) EvaluateToString(s string) (string, error) {
+	value, err := c.EvaluateString(s)
+	if err != nil {
+		return "", err
+	}
+	if value == nil {
+		return "", nil
+	}
+	return jsString(value), nil
+}
+
+// evalExpression evaluates the body of a $(...) expression
+func (c *ExpressionContext) evalExpression(src string) (interface{}, error) {
+	if !c.JavaScript {
+		if !paramRefPattern.MatchString(src) {
+			return nil, fmt.Errorf("only parameter references are allowed without InlineJavascriptRequirement: %s", src)
+		}
+		return c.evalParameterReference(src)
+	}
+	return c.evalJavaScript("(" + src + "\n)")
+}
+
+// evalFunctionBody evaluates the body of a ${...} expression
+func (c *ExpressionContext) evalFunctionBody(src string) (interface{}, error) {
+	if !c.JavaScript {
+		return nil, fmt.Errorf("${...} expressions require InlineJavascriptRequirement")
+	}
+	return c.evalJavaScript("(function() {" + src + "\n})()")
+}
+
+// evalParameterReference follows a parameter reference, such as
+// inputs.reads.path or inputs.lanes[0], through the context's values
+func (c *ExpressionContext) evalParameterReference(src string) (interface{}, error) {
+	src = strings.TrimSpace(src)
+	root := src
+	if i := strings.IndexAny(src, ".["); i >= 0 {
+		root = src[:i]
+	}
+
+	var value interface{}
+	switch root {
+	case "inputs":
+		value = c.Inputs
+	case "self":
+		value = c.Self
+	case "runtime":
+		value = c.Runtime
+	}
+
+	path := root
+	for _, match := range paramRefSegment.FindAllStringSubmatch(src[len(root):], -1) {
+		if value == nil {
+			return nil, fmt.Errorf("cannot read %s of null: %s", match[0], path)
+		}
+		path += match[0]
+
+		switch {
+		case match[4] != "":
+			index, _ := strconv.Atoi(match[4])
+			items, ok := value.([]interface{})
+			if !ok || index >= len(items) {
+				value = nil
+				continue
+			}
+			value = items[index]
+		default:
+			name := match[1] + unescapeQuoted(match[2]) + unescapeQuoted(match[3])
+			value = referenceProperty(value, name)
+		}
+	}
+	return value, nil
+}
+
+// referenceProperty returns a property of an object, or the length of an
+// array or string
+func referenceProperty(value interface{}, name string) interface{} {
+	switch x := value.(type) {
+	case map[string]interface{}:
+		return x[name]
+	case []interface{}:
+		if name == "length" {
+			return float64(len(x))
+		}
+	case string:
+		if name == "length" {
+			return float64(len([]rune(x)))
+		}
+	}
+	return nil
+}
+
+// unescapeQuoted removes the backslash escapes of a quoted property name
+func unescapeQuoted(s string) string {
+	if !strings.Contains(s, "\\") {
+		return s
+	}
+	var out strings.Builder
+	for i := 0; i < len(s); i++ {
+		if s[i] == '\\' && i+1 < len(s) {
+			i++
+		}
+		out.WriteByte(s[i])
+	}
+	return out.String()
+}
+
+// evalJavaScript runs a script in a new JavaScript runtime holding copies
+// of inputs, self and runtime, and returns its value as JSON-like Go values
+func (c *ExpressionContext) evalJavaScript(script string) (interface{}, error) {
+	vm := goja.New()
+	parse, ok := goja.AssertFunction(vm.Get("JSON").ToObject(vm).Get("parse"))
+	if !ok {
+		return nil, fmt.Errorf("JSON.parse is not available")
+	}
+	for name, value := range map[string]interface{}{
+		"inputs":  c.Inputs,
+		"self":    c.Self,
+		"runtime": c.Runtime,
+	} {
+		data, err := json.Marshal(value)
+		if err != nil {
+			return nil, fmt.Errorf("failed to pass %s to JavaScript: %v", name, err)
+		}
+		copied, err := parse(goja.Undefined(), vm.ToValue(string(data)))
+		if err != nil {
+			return nil, err
+		}
+		if err := vm.Set(name, copied); err != nil {
+			return nil, err
+		}
+	}
+
+	timer := time.AfterFunc(expressionTimeout, func() {
+		vm.Interrupt(fmt.Sprintf("expression did not finish within %s", expressionTimeout))
+	})
+	defer timer.Stop()
+
+	result, err := vm.RunString(script)
+	if err != nil {
+		return nil, err
+	}
+	return exportValue(result.Export()), nil
+}
+
+// exportValue converts the numbers of an exported JavaScript value to
+// float64, as they are decoded from JSON
+func exportValue(v interface{}) interface{} {
+	switch x := v.(type) {
+	case int64:
+		return float64(x)
+	case map[string]interface{}:
+		for key, value := range x {
+			x[key] = exportValue(value)
+		}
+	case []interface{}:
+		for i, value := range x {
+			x[i] = exportValue(value)
+		}
+	}
+	return v
+}
+
+// matchingBracket returns the index of the bracket closing the one at open,
+// skipping over string literals
+func matchingBracket(s string, open int) (int, error) {
+	closing := map[byte]byte{'(': ')', '{': '}', '[': ']'}
+	var stack []byte
+
+	for i := open; i < len(s); i++ {
+		switch ch := s[i]; ch {
+		case '\'', '"':
+			for i++; i < len(s) && s[i] != ch; i++ {
+				if s[i] == '\\' {
+					i++
+				}
+			}
+		case '(', '{', '[':
+			stack = append(stack, closing[ch])
+		case ')', '}', ']':
+			if len(stack) == 0 || stack[len(stack)-1] != ch {
+				return 0, fmt.Errorf("unbalanced %q in expression %s", ch, s[open-1:])
+			}
+			stack = stack[:len(stack)-1]
+			if len(stack) == 0 {
+				return i, nil
+			}
+		}
+	}
+
+	return 0, fmt.Errorf("unterminated expression %s", s[open-1:])
+}
+
+// isNumber reports whether v is a numeric value
+func isNumber(v interface{}) bool {
+	switch v.(type) {
+	case float64, float32, int, int64, int32:
+		return true
+	}
+	return false
+}
+
+// toNumber converts a numeric value, as reported by isNumber, to a float64
+func toNumber(v interface{}) float64 {
+	switch x := v.(type) {
+	case float64:
+		return x
+	case float32:
+		return float64(x)
+	case int:
+		return float64(x)
+	case int64:
+		return float64(x)
+	case int32:
+		return float64(x)
+	}
+	return math.NaN()
+}
+
+// jsString converts a value to a string as it appears in interpolation
+func jsString(v interface{}) string {
+	switch x := v.(type) {
+	case nil:
+		return "null"
+	case string:
+		return x
+	case bool:
+		return strconv.FormatBool(x)
+	case float64, float32, int, int64, int32:
+		return formatNumber(toNumber(x))
+	}
+
+	data, err := json.Marshal(v)
+	if err != nil {
+		return fmt.Sprintf("%v", v)
+	}
+	return string(data)
+}
+
+// formatNumber formats a number without exponent for integral values
+func formatNumber(f float64) string {
+	if f == math.Trunc(f) && math.Abs(f) < 1e21 {
+		return strconv.FormatFloat(f, 'f', -1, 64)
+	}
+	return strconv.FormatFloat(f, 'g', -1, 64)
+}
+
+// jsTypeOf returns the JavaScript typeof name of a value, e.g. "number"
+// or "object", for error messages about expression results
+func jsTypeOf(v interface{}) string {
+	switch v.(type) {
+	case nil:
+		return "undefined"
+	case bool:
+		return "boolean"
+	case string:
+		return "string"
+	case float64, float32, int, int64, int32:
+		return "number"
+	}
+	return "object"
+}
@@ -0,0 +1,163 @@
+package cwlgo
+
+import (
+	"testing"
+)
+
+func TestEvaluateParameterReferences(t *testing.T) {
+	exprCtx := &ExpressionContext{
+		Inputs: map[string]interface{}{
+			"sample": "NA12878",
+			"reads": map[string]interface{}{
+				"class":    "File",
+				"path":     "/data/reads.fastq",
+				"basename": "reads.fastq",
+			},
+			"lanes": []interface{}{"L001", "L002"},
+		},
+		Runtime: map[string]interface{}{"outdir": "/out"},
+	}
+
+	tests := []struct {
+		expr     string
+		expected interface{}
+	}{
+		{"$(inputs.reads.path)", "/data/reads.fastq"},
+		{"$(inputs['sample']).sam", "NA12878.sam"},
+		{"$(inputs.lanes[1])", "L002"},
+		{"$(inputs.lanes.length)", float64(2)},
+		{"$(runtime.outdir)/$(inputs.reads.basename)", "/out/reads.fastq"},
+		{"plain.txt", "plain.txt"},
+		{`\$(inputs.sample)`, "$(inputs.sample)"},
+	}
+
+	for _, tt := range tests {
+		value, err := exprCtx.EvaluateString(tt.expr)
+		if err != nil {
+			t.Errorf("Failed to evaluate %s: %v", tt.expr, err)
+			continue
+		}
+		if value != tt.expected {
+			t.Errorf("Expected %s to evaluate to %v, got %v", tt.expr, tt.expected, value)
+		}
+	}
+
+	// JavaScript is rejected without InlineJavascriptRequirement
+	if _, err := exprCtx.EvaluateString("$(inputs.sample + '.bam')"); err == nil {
+		t.Error("Expected error for JavaScript without InlineJavascriptRequirement, got nil")
+	}
+}
+
+func TestEvaluateJavaScript(t *testing.T) {
+	exprCtx := &ExpressionContext{
+		Inputs: map[string]interface{}{
+			"sample": "NA12878",
+			"type":   "rna",
+			"count":  3,
+			"files":  []interface{}{"a.txt", "b.txt"},
+		},
+		JavaScript: true,
+	}
+
+	tests := []struct {
+		expr     string
+		expected interface{}
+	}{
+		{"$(inputs.sample + '.bam')", "NA12878.bam"},
+		{"$(inputs.type == 'rna')", true},
+		{"$(inputs.count * 2 + 1)", float64(7)},
+		{"$(inputs.count > 5 ? 'many' : 'few')", "few"},
+		{"$(inputs.files.join(','))", "a.txt,b.txt"},
+		{"$(inputs.sample.toLowerCase().slice(0, 2))", "na"},
+		{"$(parseInt('42') + 1)", float64(43)},
+		{"${ var n = inputs.count; if (n > 2) { return 'big'; } return 'small'; }", "big"},
+	}
+
+	for _, tt := range tests {
+		value, err := exprCtx.EvaluateString(tt.expr)
+		if err != nil {
+			t.Errorf("Failed to evaluate %s: %v", tt.expr, err)
+			continue
+		}
+		if value != tt.expected {
+			t.Errorf("Expected %s to evaluate to %v, got %v", tt.expr, tt.expected, value)
+		}
+	}
+
+	// Functions and loops are evaluated by the JavaScript engine
+	value, err := exprCtx.EvaluateString("${ var n = 0; for (var i = 0; i < inputs.files.length; i++) { n += inputs.files[i].length; } return inputs.files.map(function(f) { return f.toUpperCase(); }).concat([n]); }")
+	if err != nil {
+		t.Fatalf("Failed to evaluate function body: %v", err)
+	}
+	if list, ok := value.([]interface{}); !ok || len(list) != 3 || list[0] != "A.TXT" || list[2] != float64(10) {
+		t.Errorf("Expected [A.TXT B.TXT 10], got %v", value)
+	}
+
+	// Expressions see copies of the inputs
+	if _, err := exprCtx.EvaluateString("${ inputs.files.push('c.txt'); inputs.sample = 'x'; return null; }"); err != nil {
+		t.Fatalf("Failed to evaluate mutating expression: %v", err)
+	}
+	if len(exprCtx.Inputs["files"].([]interface{})) != 2 || exprCtx.Inputs["sample"] != "NA12878" {
+		t.Errorf("Expected inputs to be unchanged, got %v", exprCtx.Inputs)
+	}
+
+	// Object literals returned from function bodies
+	value, err = exprCtx.EvaluateString("${ return {'name': inputs.sample, 'n': inputs.count}; }")
+	if err != nil {
+		t.Fatalf("Failed to evaluate object literal: %v", err)
+	}
+	object, ok := value.(map[string]interface{})
+	if !ok || object["name"] != "NA12878" {
+		t.Errorf("Expected object with name NA12878, got %v", value)
+	}
+}
+
+func TestResolveRedirects(t *testing.T) {
+	tool := &CommandLineTool{
+		CWLVersion:  "v1.2",
+		Class:       "CommandLineTool",
+		BaseCommand: "cat",
+		Inputs: map[string]CommandInputParameter{
+			"sample": {Type: "string"},
+			"reads":  {Type: "File"},
+		},
+		Stdin:  "$(inputs.reads.path)",
+		Stdout: "$(inputs.sample).sam",
+	}
+
+	executor := NewExecutor()
+
+	execCtx, err := NewExecutionContext("")
+	if err != nil {
+		t.Fatalf("Failed to create execution context: %v", err)
+	}
+	defer execCtx.Cleanup()
+
+	execCtx.Inputs = map[string]interface{}{
+		"sample": "NA12878",
+		"reads": map[string]interface{}{
+			"class": "File",
+			"path":  "/data/reads.fastq",
+		},
+	}
+
+	if err := executor.resolveRedirects(tool, execCtx); err != nil {
+		t.Fatalf("Failed to resolve redirects: %v", err)
+	}
+
+	if execCtx.Stdin != "/data/reads.fastq" {
+		t.Errorf("Expected stdin /data/reads.fastq, got %s", execCtx.Stdin)
+	}
+
+	if execCtx.Stdout != "NA12878.sam" {
+		t.Errorf("Expected stdout NA12878.sam, got %s", execCtx.Stdout)
+	}
+
+	// Redirects must not escape the output directory
+	for _, stdout := range []string{"../../etc/cron.d/x", "/etc/passwd", "$(inputs.sample)/../../x"} {
+		tool.Stdout = stdout
+		if err := executor.resolveRedirects(tool, execCtx); err == nil {
+			t.Errorf("Expected error for stdout %s, got nil", stdout)
+		}
+	}
+}
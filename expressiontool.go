@@ -0,0 +1,240 @@
+package cwlgo
+
+import (
+	"context"
+	"fmt"
+	"math"
+	"strings"
+)
+
+// ExpressionTool represents a CWL ExpressionTool document, whose outputs are
+// computed by an expression instead of a command
+type ExpressionTool struct {
+	// Required fields
+	CWLVersion string `yaml:"cwlVersion" json:"cwlVersion"`
+	Class      string `yaml:"class" json:"class"`           // Must be "ExpressionTool"
+	Expression string `yaml:"expression" json:"expression"` // Expression yielding the output object
+
+	// Optional fields
+	Inputs       map[string]CommandInputParameter         `yaml:"inputs" json:"inputs"`
+	Outputs      map[string]ExpressionToolOutputParameter `yaml:"outputs" json:"outputs"`
+	ID           string                                   `yaml:"id,omitempty" json:"id,omitempty"`
+	Requirements []map[string]interface{}                 `yaml:"requirements,omitempty" json:"requirements,omitempty"`
+	Hints        []map[string]interface{}                 `yaml:"hints,omitempty" json:"hints,omitempty"`
+	Label        string                                   `yaml:"label,omitempty" json:"label,omitempty"`
+	Doc          string                                   `yaml:"doc,omitempty" json:"doc,omitempty"`
+
+	// Schema-salad metadata
+	Namespaces map[string]string `yaml:"$namespaces,omitempty" json:"$namespaces,omitempty"` // Prefix -> namespace IRI
+	Schemas    []string          `yaml:"$schemas,omitempty" json:"$schemas,omitempty"`       // Ontology files used for format checking
+}
+
+// IsProcess implements the Process interface
+func (t *ExpressionTool) IsProcess() bool {
+	return true
+}
+
+// ExpressionToolOutputParameter represents an output parameter for an
+// ExpressionTool
+type ExpressionToolOutputParameter struct {
+	ID     string      `yaml:"id,omitempty" json:"id,omitempty"`
+	Label  string      `yaml:"label,omitempty" json:"label,omitempty"`
+	Doc    string      `yaml:"doc,omitempty" json:"doc,omitempty"`
+	Type   interface{} `yaml:"type" json:"type"`
+	Format interface{} `yaml:"format,omitempty" json:"format,omitempty"`
+}
+
+// ExecuteExpressionTool evaluates an ExpressionTool's expression against
+// the given inputs and returns its outputs, checked against the declared
+// output types
+func (e *Executor) ExecuteExpressionTool(ctx context.Context, tool *ExpressionTool, inputs map[string]interface{}) (map[string]interface{}, error) {
+	if err := ctx.Err(); err != nil {
+		return nil, &CWLError{Err: err, Message: "expression tool was cancelled"}
+	}
+
+	exprInputs := make(map[string]interface{}, len(tool.Inputs))
+	for inputID, inputParam := range tool.Inputs {
+		if inputParam.Default != nil {
+			exprInputs[inputID] = fillFileProperties(inputParam.Default)
+		}
+	}
+	for inputID, value := range inputs {
+		exprInputs[inputID] = fillFileProperties(value)
+	}
+
+	exprCtx := &ExpressionContext{
+		Inputs: exprInputs,
+		Runtime: map[string]interface{}{
+			"cores": float64(1),
+			"ram":   float64(1024),
+		},
+	}
+	for _, req := range tool.Requirements {
+		if req["class"] == "InlineJavascriptRequirement" {
+			exprCtx.JavaScript = true
+		}
+	}
+
+	// Block scalars leave a trailing newline, which would otherwise turn
+	// the expression into string interpolation
+	value, err := exprCtx.EvaluateString(strings.TrimSpace(tool.Expression))
+	if err != nil {
+		return nil, &CWLError{
+			Err:     err,
+			Message: "failed to evaluate expression",
+		}
+	}
+
+	result, ok := value.(map[string]interface{})
+	if !ok {
+		return nil, &CWLError{
+			Err:     ErrExecution,
+			Message: fmt.Sprintf("expression must return an object, got %s", jsTypeOf(value)),
+		}
+	}
+
+	// Keep only the declared outputs, each of which must match its type
+	outputs := make(map[string]interface{}, len(tool.Outputs))
+	for outputID, outputParam := range tool.Outputs {
+		output := result[outputID]
+		if !matchesType(output, outputParam.Type) {
+			return nil, &CWLError{
+				Err:     ErrExecution,
+				Message: fmt.Sprintf("output %s does not match its type %v: %v", outputID, outputParam.Type, output),
+			}
+		}
+		format, err := outputFormat(outputParam.Format, exprCtx, tool.Namespaces)
+		if err != nil {
+			return nil, &CWLError{
+				Err:     err,
+				Message: fmt.Sprintf("output %s", outputID),
+			}
+		}
+		if format != "" {
+			output = setFileFormat(output, format)
+		}
+		outputs[outputID] = output
+	}
+
+	return outputs, nil
+}
+
+// matchesType reports whether a value conforms to a CWL type: a type name
+// with optional "?" and "[]" suffixes, a list of alternatives, or an array,
+// enum or record schema
+func matchesType(value interface{}, typ interface{}) bool {
+	switch t := typ.(type) {
+	case nil:
+		return true
+
+	case string:
+		if strings.HasSuffix(t, "?") {
+			return value == nil || matchesType(value, strings.TrimSuffix(t, "?"))
+		}
+		if strings.HasSuffix(t, "[]") {
+			items, ok := value.([]interface{})
+			if !ok {
+				return false
+			}
+			for _, item := range items {
+				if !matchesType(item, strings.TrimSuffix(t, "[]")) {
+					return false
+				}
+			}
+			return true
+		}
+		return matchesTypeName(value, t)
+
+	case []interface{}:
+		for _, alternative := range t {
+			if matchesType(value, alternative) {
+				return true
+			}
+		}
+		return false
+
+	case map[string]interface{}:
+		switch t["type"] {
+		case "array":
+			items, ok := value.([]interface{})
+			if !ok {
+				return false
+			}
+			for _, item := range items {
+				if !matchesType(item, t["items"]) {
+					return false
+				}
+			}
+			return true
+		case "enum":
+			_, ok := value.(string)
+			return ok
+		case "record":
+			_, ok := value.(map[string]interface{})
+			return ok
+		}
+		return matchesType(value, t["type"])
+	}
+
+	return true
+}
+
+// matchesTypeName reports whether a value conforms to a named CWL type
+func matchesTypeName(value interface{}, name string) bool {
+	switch name {
+	case "null":
+		return value == nil
+	case "Any":
+		return value != nil
+	case "boolean":
+		_, ok := value.(bool)
+		return ok
+	case "string":
+		_, ok := value.(string)
+		return ok
+	case "int", "long":
+		switch v := value.(type) {
+		case int, int64:
+			return true
+		case float64:
+			return v == math.Trunc(v)
+		}
+		return false
+	case "float", "double":
+		switch value.(type) {
+		case int, int64, float64:
+			return true
+		}
+		return false
+	case "File", "Directory":
+		obj, ok := value.(map[string]interface{})
+		return ok && obj["class"] == name
+	}
+
+	// Named schemas and other types are not checked
+	return value != nil
+}
+
+// setFileFormat returns a copy of value with the format of each File set
+func setFileFormat(value interface{}, format string) interface{} {
+	switch v := value.(type) {
+	case map[string]interface{}:
+		if v["class"] != "File" {
+			return v
+		}
+		file := make(map[string]interface{}, len(v)+1)
+		for k, item := range v {
+			file[k] = item
+		}
+		file["format"] = format
+		return file
+
+	case []interface{}:
+		items := make([]interface{}, len(v))
+		for i, item := range v {
+			items[i] = setFileFormat(item, format)
+		}
+		return items
+	}
+	return value
+}
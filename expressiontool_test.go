@@ -0,0 +1,147 @@
+package cwlgo
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+const pickFirstCWL = `cwlVersion: v1.2
+class: ExpressionTool
+requirements:
+  - class: InlineJavascriptRequirement
+inputs:
+  files:
+    type: File[]
+outputs:
+  first:
+    type: File
+  count:
+    type: int
+expression: |
+  ${
+    return {"first": inputs.files[0], "count": inputs.files.length, "ignored": true};
+  }
+`
+
+func TestExecuteExpressionTool(t *testing.T) {
+	enterTempDir(t)
+
+	dir := writeWorkflowFiles(t, map[string]string{"pick.cwl": pickFirstCWL})
+
+	tool, err := NewParser().ParseExpressionToolFile(filepath.Join(dir, "pick.cwl"))
+	if err != nil {
+		t.Fatalf("Failed to parse expression tool: %v", err)
+	}
+
+	files := []interface{}{
+		map[string]interface{}{"class": "File", "path": "/data/a.txt"},
+		map[string]interface{}{"class": "File", "path": "/data/b.txt"},
+	}
+
+	outputs, err := NewExecutor().ExecuteExpressionTool(context.Background(), tool, map[string]interface{}{"files": files})
+	if err != nil {
+		t.Fatalf("Failed to execute expression tool: %v", err)
+	}
+
+	first, ok := outputs["first"].(map[string]interface{})
+	if !ok || first["path"] != "/data/a.txt" {
+		t.Errorf("Expected first file /data/a.txt, got %v", outputs["first"])
+	}
+	if outputs["count"] != float64(2) {
+		t.Errorf("Expected count 2, got %v", outputs["count"])
+	}
+	if _, ok := outputs["ignored"]; ok {
+		t.Error("Expected undeclared output to be dropped")
+	}
+
+	// A result that does not match the declared output type is rejected
+	if _, err := NewExecutor().ExecuteExpressionTool(context.Background(), tool, map[string]interface{}{"files": []interface{}{}}); err == nil {
+		t.Error("Expected error for missing File output, got nil")
+	}
+}
+
+func TestExpressionToolStep(t *testing.T) {
+	enterTempDir(t)
+
+	dir := writeWorkflowFiles(t, map[string]string{
+		"echo.cwl": echoToolCWL,
+		"pick.cwl": pickFirstCWL,
+		"workflow.cwl": `cwlVersion: v1.2
+class: Workflow
+requirements:
+  - class: ScatterFeatureRequirement
+inputs:
+  messages:
+    type: string[]
+outputs:
+  first:
+    type: File
+    outputSource: pick/first
+steps:
+  echo:
+    run: echo.cwl
+    scatter: message
+    in:
+      message: messages
+    out: [output]
+  pick:
+    run: pick.cwl
+    in:
+      files: echo/output
+    out: [first]
+`,
+	})
+
+	wf, err := NewParser().ParseWorkflowFile(filepath.Join(dir, "workflow.cwl"))
+	if err != nil {
+		t.Fatalf("Failed to parse workflow: %v", err)
+	}
+
+	result, err := NewExecutor().ExecuteWorkflow(context.Background(), wf, map[string]interface{}{
+		"messages": []interface{}{"one", "two"},
+	})
+	if err != nil {
+		t.Fatalf("Failed to execute workflow: %v", err)
+	}
+
+	first, ok := result.Outputs["first"].(map[string]interface{})
+	if !ok {
+		t.Fatalf("Expected first File, got %v", result.Outputs["first"])
+	}
+	data, err := os.ReadFile(first["path"].(string))
+	if err != nil {
+		t.Fatalf("Failed to read output: %v", err)
+	}
+	if strings.TrimSpace(string(data)) != "one" {
+		t.Errorf("Expected output of first scatter job, got %q", data)
+	}
+}
+
+func TestMatchesType(t *testing.T) {
+	file := map[string]interface{}{"class": "File", "path": "/a"}
+
+	tests := []struct {
+		value    interface{}
+		typ      interface{}
+		expected bool
+	}{
+		{"a", "string", true},
+		{nil, "string", false},
+		{nil, "string?", true},
+		{float64(3), "int", true},
+		{3.5, "int", false},
+		{[]interface{}{file}, "File[]", true},
+		{[]interface{}{"a"}, "File[]", false},
+		{nil, []interface{}{"null", "File"}, true},
+		{[]interface{}{true}, map[string]interface{}{"type": "array", "items": "boolean"}, true},
+	}
+
+	for _, tt := range tests {
+		if got := matchesType(tt.value, tt.typ); got != tt.expected {
+			t.Errorf("Expected matchesType(%v, %v) to be %v, got %v", tt.value, tt.typ, tt.expected, got)
+		}
+	}
+}
@@ -0,0 +1,404 @@
+package cwlgo
+
+import (
+	"bufio"
+	"encoding/xml"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+const (
+	rdfNamespace  = "http://www.w3.org/1999/02/22-rdf-syntax-ns#"
+	rdfsNamespace = "http://www.w3.org/2000/01/rdf-schema#"
+	owlNamespace  = "http://www.w3.org/2002/07/owl#"
+)
+
+// Ontology holds the class hierarchy of an ontology listed in $schemas,
+// used to match File formats against subclasses of the declared format
+type Ontology struct {
+	// superClasses maps a class IRI to its direct superclasses
+	// (rdfs:subClassOf and owl:equivalentClass targets)
+	superClasses map[string][]string
+}
+
+// NewOntology creates an empty ontology
+func NewOntology() *Ontology {
+	return &Ontology{
+		superClasses: make(map[string][]string),
+	}
+}
+
+// LoadOntology loads an ontology from a local RDF/XML (.owl, .rdf, .xml)
+// or N-Triples (.nt) file
+func LoadOntology(path string) (*Ontology, error) {
+	file, err := os.Open(path)
+	if err != nil {
+		return nil, &CWLError{
+			Err:     err,
+			Message: fmt.Sprintf("failed to open ontology file: %s", path),
+		}
+	}
+	defer file.Close()
+
+	ontology := NewOntology()
+
+	switch strings.ToLower(filepath.Ext(path)) {
+	case ".nt":
+		err = ontology.readNTriples(file)
+	default:
+		err = ontology.readRDFXML(file)
+	}
+	if err != nil {
+		return nil, &CWLError{
+			Err:     err,
+			Message: fmt.Sprintf("failed to parse ontology file: %s", path),
+		}
+	}
+
+	return ontology, nil
+}
+
+// AddSubClass records that sub is a subclass of super
+func (o *Ontology) AddSubClass(sub, super string) {
+	o.superClasses[sub] = append(o.superClasses[sub], super)
+}
+
+// IsSubClassOf reports whether class equals super or is a (transitive)
+// subclass of it
+func (o *Ontology) IsSubClassOf(class, super string) bool {
+	if class == super {
+		return true
+	}
+	if o == nil {
+		return false
+	}
+
+	seen := map[string]bool{class: true}
+	queue := []string{class}
+	for len(queue) > 0 {
+		current := queue[0]
+		queue = queue[1:]
+		for _, parent := range o.superClasses[current] {
+			if parent == super {
+				return true
+			}
+			if !seen[parent] {
+				seen[parent] = true
+				queue = append(queue, parent)
+			}
+		}
+	}
+	return false
+}
+
+// readRDFXML reads subclass relations from an RDF/XML document
+func (o *Ontology) readRDFXML(r io.Reader) error {
+	decoder := xml.NewDecoder(bufio.NewReader(r))
+
+	// subjects tracks the rdf:about of each open element, "" if none
+	var subjects []string
+	base := ""
+
+	for {
+		token, err := decoder.Token()
+		if err != nil {
+			if err == io.EOF {
+				return nil
+			}
+			return err
+		}
+
+		switch t := token.(type) {
+		case xml.StartElement:
+			subject := ""
+			resource := ""
+			for _, attr := range t.Attr {
+				switch {
+				case attr.Name.Space == "xml" && attr.Name.Local == "base",
+					attr.Name.Space == "http://www.w3.org/XML/1998/namespace" && attr.Name.Local == "base":
+					base = attr.Value
+				case attr.Name.Space == rdfNamespace && (attr.Name.Local == "about" || attr.Name.Local == "ID"):
+					subject = resolveRDFReference(base, attr.Value)
+				case attr.Name.Space == rdfNamespace && attr.Name.Local == "resource":
+					resource = resolveRDFReference(base, attr.Value)
+				}
+			}
+
+			isRelation := (t.Name.Space == rdfsNamespace && t.Name.Local == "subClassOf") ||
+				(t.Name.Space == owlNamespace && t.Name.Local == "equivalentClass")
+			if isRelation && resource != "" {
+				if current := currentSubject(subjects); current != "" {
+					o.AddSubClass(current, resource)
+				}
+			}
+
+			subjects = append(subjects, subject)
+
+		case xml.EndElement:
+			if len(subjects) > 0 {
+				subjects = subjects[:len(subjects)-1]
+			}
+		}
+	}
+}
+
+// readNTriples reads subclass relations from an N-Triples document
+func (o *Ontology) readNTriples(r io.Reader) error {
+	scanner := bufio.NewScanner(r)
+	scanner.Buffer(make([]byte, 64*1024), 1024*1024)
+
+	for scanner.Scan() {
+		line := strings.TrimSpace(scanner.Text())
+		if line == "" || strings.HasPrefix(line, "#") {
+			continue
+		}
+
+		fields := strings.Fields(strings.TrimSuffix(line, "."))
+		if len(fields) < 3 {
+			continue
+		}
+
+		subject, predicate, object := trimIRI(fields[0]), trimIRI(fields[1]), trimIRI(fields[2])
+		if predicate == rdfsNamespace+"subClassOf" || predicate == owlNamespace+"equivalentClass" {
+			o.AddSubClass(subject, object)
+		}
+	}
+
+	return scanner.Err()
+}
+
+// currentSubject returns the innermost subject on the element stack
+func currentSubject(subjects []string) string {
+	for i := len(subjects) - 1; i >= 0; i-- {
+		if subjects[i] != "" {
+			return subjects[i]
+		}
+	}
+	return ""
+}
+
+// resolveRDFReference resolves a fragment reference against xml:base
+func resolveRDFReference(base, ref string) string {
+	if strings.HasPrefix(ref, "#") && base != "" {
+		return strings.TrimSuffix(base, "#") + ref
+	}
+	return ref
+}
+
+// trimIRI strips the angle brackets from an N-Triples IRI
+func trimIRI(term string) string {
+	return strings.TrimSuffix(strings.TrimPrefix(term, "<"), ">")
+}
+
+// ExpandIRI expands a prefixed name such as "edam:format_1930" using the
+// document's $namespaces. Values without a known prefix are returned as is.
+func ExpandIRI(value string, namespaces map[string]string) string {
+	prefix, local, ok := strings.Cut(value, ":")
+	if !ok {
+		return value
+	}
+	if ns, ok := namespaces[prefix]; ok {
+		return ns + local
+	}
+	return value
+}
+
+// ontologyFor returns the merged ontology for a tool's $schemas, loading
+// and caching each local file. Remote schemas are skipped so that format
+// checks work offline, falling back to exact IRI matching.
+func (e *Executor) ontologyFor(tool *CommandLineTool) (*Ontology, error) {
+	if len(tool.Schemas) == 0 {
+		return nil, nil
+	}
+
+	e.ontologyMu.Lock()
+	defer e.ontologyMu.Unlock()
+
+	if e.ontologies == nil {
+		e.ontologies = make(map[string]*Ontology)
+	}
+
+	merged := NewOntology()
+	for _, schema := range tool.Schemas {
+		if strings.HasPrefix(schema, "http://") || strings.HasPrefix(schema, "https://") {
+			continue
+		}
+		path := strings.TrimPrefix(schema, "file://")
+
+		ontology, ok := e.ontologies[path]
+		if !ok {
+			var err error
+			ontology, err = LoadOntology(path)
+			if err != nil {
+				return nil, err
+			}
+			e.ontologies[path] = ontology
+		}
+
+		for class, parents := range ontology.superClasses {
+			merged.superClasses[class] = append(merged.superClasses[class], parents...)
+		}
+	}
+
+	return merged, nil
+}
+
+// declaredFormats returns the expanded format IRIs of a format field,
+// which may be a string or a list of strings, each of which may be an
+// expression yielding a string or a list of strings
+func declaredFormats(format interface{}, exprCtx *ExpressionContext, namespaces map[string]string) ([]string, error) {
+	var items []interface{}
+	switch f := format.(type) {
+	case nil:
+		return nil, nil
+	case string:
+		items = []interface{}{f}
+	case []interface{}:
+		items = f
+	case []string:
+		for _, item := range f {
+			items = append(items, item)
+		}
+	default:
+		return nil, &CWLError{
+			Err:     ErrInvalidCWL,
+			Message: fmt.Sprintf("format must be a string or a list of strings, got %v", format),
+		}
+	}
+
+	var formats []string
+	for _, item := range items {
+		value, err := exprCtx.Evaluate(item)
+		if err != nil {
+			return nil, &CWLError{
+				Err:     err,
+				Message: fmt.Sprintf("failed to evaluate format %v", item),
+			}
+		}
+
+		values := []interface{}{value}
+		if list, ok := value.([]interface{}); ok {
+			values = list
+		}
+		for _, value := range values {
+			switch v := value.(type) {
+			case nil:
+			case string:
+				if v != "" {
+					formats = append(formats, ExpandIRI(v, namespaces))
+				}
+			default:
+				return nil, &CWLError{
+					Err:     ErrExecution,
+					Message: fmt.Sprintf("format must evaluate to a string, got %v", value),
+				}
+			}
+		}
+	}
+	return formats, nil
+}
+
+// outputFormat returns the expanded format IRI of an output parameter's
+// format field, which may be an expression, or "" if it has none
+func outputFormat(format interface{}, exprCtx *ExpressionContext, namespaces map[string]string) (string, error) {
+	formats, err := declaredFormats(format, exprCtx, namespaces)
+	if err != nil {
+		return "", err
+	}
+	switch len(formats) {
+	case 0:
+		return "", nil
+	case 1:
+		return formats[0], nil
+	}
+	return "", &CWLError{
+		Err:     ErrExecution,
+		Message: fmt.Sprintf("output format must be a single format, got %v", formats),
+	}
+}
+
+// checkInputFormats checks each File input against its parameter's format
+func (e *Executor) checkInputFormats(tool *CommandLineTool, ctx *ExecutionContext) error {
+	var ontology *Ontology
+	loaded := false
+	exprCtx := newExpressionContext(tool, ctx)
+
+	for inputID, inputParam := range tool.Inputs {
+		formats, err := declaredFormats(inputParam.Format, exprCtx, tool.Namespaces)
+		if err != nil {
+			return &CWLError{
+				Err:     err,
+				Message: fmt.Sprintf("input %s", inputID),
+			}
+		}
+		if len(formats) == 0 {
+			continue
+		}
+
+		value, ok := ctx.Inputs[inputID]
+		if !ok {
+			value = inputParam.Default
+		}
+
+		files := collectFiles(value)
+		if len(files) == 0 {
+			continue
+		}
+
+		if !loaded {
+			var err error
+			ontology, err = e.ontologyFor(tool)
+			if err != nil {
+				return err
+			}
+			loaded = true
+		}
+
+		for _, file := range files {
+			fileFormat, _ := file["format"].(string)
+			if fileFormat == "" {
+				return &CWLError{
+					Err:     ErrExecution,
+					Message: fmt.Sprintf("input %s: File has no format, expected one of %v", inputID, formats),
+				}
+			}
+			fileFormat = ExpandIRI(fileFormat, tool.Namespaces)
+
+			matched := false
+			for _, format := range formats {
+				if ontology.IsSubClassOf(fileFormat, format) {
+					matched = true
+					break
+				}
+			}
+			if !matched {
+				return &CWLError{
+					Err:     ErrExecution,
+					Message: fmt.Sprintf("input %s: File format %s is not compatible with %v", inputID, fileFormat, formats),
+				}
+			}
+		}
+	}
+
+	return nil
+}
+
+// collectFiles returns the File objects contained in an input value
+func collectFiles(value interface{}) []map[string]interface{} {
+	switch v := value.(type) {
+	case map[string]interface{}:
+		if class, _ := v["class"].(string); class == "File" {
+			return []map[string]interface{}{v}
+		}
+	case []interface{}:
+		var files []map[string]interface{}
+		for _, item := range v {
+			files = append(files, collectFiles(item)...)
+		}
+		return files
+	}
+	return nil
+}
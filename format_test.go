@@ -0,0 +1,184 @@
+package cwlgo
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+const testOntology = `<?xml version="1.0"?>
+<rdf:RDF xmlns:rdf="http://www.w3.org/1999/02/22-rdf-syntax-ns#"
+         xmlns:rdfs="http://www.w3.org/2000/01/rdf-schema#"
+         xmlns:owl="http://www.w3.org/2002/07/owl#">
+  <owl:Class rdf:about="http://edamontology.org/format_1929">
+    <rdfs:subClassOf rdf:resource="http://edamontology.org/format_2200"/>
+  </owl:Class>
+  <owl:Class rdf:about="http://edamontology.org/format_1930">
+    <rdfs:subClassOf rdf:resource="http://edamontology.org/format_2182"/>
+  </owl:Class>
+  <owl:Class rdf:about="http://edamontology.org/format_2182">
+    <rdfs:subClassOf rdf:resource="http://edamontology.org/format_2200"/>
+  </owl:Class>
+</rdf:RDF>
+`
+
+func TestExpandIRI(t *testing.T) {
+	namespaces := map[string]string{"edam": "http://edamontology.org/"}
+
+	if got := ExpandIRI("edam:format_1930", namespaces); got != "http://edamontology.org/format_1930" {
+		t.Errorf("Expected expanded IRI, got %s", got)
+	}
+
+	if got := ExpandIRI("http://example.com/format", namespaces); got != "http://example.com/format" {
+		t.Errorf("Expected full IRI to be unchanged, got %s", got)
+	}
+}
+
+func TestLoadOntology(t *testing.T) {
+	tempDir, err := os.MkdirTemp("", "cwlgo-test-")
+	if err != nil {
+		t.Fatalf("Failed to create temp dir: %v", err)
+	}
+	defer os.RemoveAll(tempDir)
+
+	ontologyFile := filepath.Join(tempDir, "EDAM.owl")
+	if err := os.WriteFile(ontologyFile, []byte(testOntology), 0644); err != nil {
+		t.Fatalf("Failed to write ontology file: %v", err)
+	}
+
+	ontology, err := LoadOntology(ontologyFile)
+	if err != nil {
+		t.Fatalf("Failed to load ontology: %v", err)
+	}
+
+	// format_1930 (FASTQ) -> format_2182 -> format_2200
+	if !ontology.IsSubClassOf("http://edamontology.org/format_1930", "http://edamontology.org/format_2200") {
+		t.Error("Expected format_1930 to be a transitive subclass of format_2200")
+	}
+
+	if ontology.IsSubClassOf("http://edamontology.org/format_1929", "http://edamontology.org/format_2182") {
+		t.Error("Expected format_1929 not to be a subclass of format_2182")
+	}
+}
+
+func TestCheckInputFormats(t *testing.T) {
+	tempDir, err := os.MkdirTemp("", "cwlgo-test-")
+	if err != nil {
+		t.Fatalf("Failed to create temp dir: %v", err)
+	}
+	defer os.RemoveAll(tempDir)
+
+	ontologyFile := filepath.Join(tempDir, "EDAM.owl")
+	if err := os.WriteFile(ontologyFile, []byte(testOntology), 0644); err != nil {
+		t.Fatalf("Failed to write ontology file: %v", err)
+	}
+
+	tool := &CommandLineTool{
+		CWLVersion:  "v1.2",
+		Class:       "CommandLineTool",
+		BaseCommand: "cat",
+		Namespaces:  map[string]string{"edam": "http://edamontology.org/"},
+		Schemas:     []string{ontologyFile},
+		Inputs: map[string]CommandInputParameter{
+			"reads": {
+				Type:   "File",
+				Format: "edam:format_2200",
+			},
+		},
+	}
+
+	executor := NewExecutor()
+
+	execCtx, err := NewExecutionContext("")
+	if err != nil {
+		t.Fatalf("Failed to create execution context: %v", err)
+	}
+	defer execCtx.Cleanup()
+
+	// Subclass of the declared format
+	execCtx.Inputs = map[string]interface{}{
+		"reads": map[string]interface{}{
+			"class":  "File",
+			"path":   "reads.fastq",
+			"format": "edam:format_1930",
+		},
+	}
+	if err := executor.checkInputFormats(tool, execCtx); err != nil {
+		t.Errorf("Expected subclass format to be accepted, got %v", err)
+	}
+
+	// Unrelated format
+	execCtx.Inputs["reads"] = map[string]interface{}{
+		"class":  "File",
+		"path":   "reads.txt",
+		"format": "http://edamontology.org/format_1964",
+	}
+	if err := executor.checkInputFormats(tool, execCtx); err == nil {
+		t.Error("Expected error for incompatible format, got nil")
+	}
+
+	// Missing format
+	execCtx.Inputs["reads"] = map[string]interface{}{
+		"class": "File",
+		"path":  "reads.txt",
+	}
+	if err := executor.checkInputFormats(tool, execCtx); err == nil {
+		t.Error("Expected error for File without format, got nil")
+	}
+}
+
+func TestExpressionFormats(t *testing.T) {
+	ontologyFile := filepath.Join(t.TempDir(), "EDAM.owl")
+	if err := os.WriteFile(ontologyFile, []byte(testOntology), 0644); err != nil {
+		t.Fatalf("Failed to write ontology file: %v", err)
+	}
+
+	tool := &CommandLineTool{
+		CWLVersion:  "v1.2",
+		Class:       "CommandLineTool",
+		BaseCommand: "cat",
+		Namespaces:  map[string]string{"edam": "http://edamontology.org/"},
+		Schemas:     []string{ontologyFile},
+		Inputs: map[string]CommandInputParameter{
+			"fmt":   {Type: "string"},
+			"reads": {Type: "File", Format: "$(inputs.fmt)"},
+			"other": {Type: "File?", Format: []interface{}{"edam:format_1929", "$(inputs.fmt)"}},
+		},
+		Outputs: map[string]CommandOutputParameter{
+			"output": {Type: "File", Format: "$(inputs.fmt)"},
+		},
+	}
+
+	executor := NewExecutor()
+
+	execCtx, err := NewExecutionContext("")
+	if err != nil {
+		t.Fatalf("Failed to create execution context: %v", err)
+	}
+	defer execCtx.Cleanup()
+
+	// String and list formats are evaluated before the check
+	execCtx.Inputs = map[string]interface{}{
+		"fmt":   "edam:format_2182",
+		"reads": map[string]interface{}{"class": "File", "path": "reads.fastq", "format": "edam:format_1930"},
+		"other": map[string]interface{}{"class": "File", "path": "reads.fq", "format": "edam:format_1930"},
+	}
+	if err := executor.checkInputFormats(tool, execCtx); err != nil {
+		t.Errorf("Expected evaluated formats to accept the inputs, got %v", err)
+	}
+
+	execCtx.Inputs["fmt"] = "edam:format_1929"
+	if err := executor.checkInputFormats(tool, execCtx); err == nil {
+		t.Error("Expected error for a format incompatible with the evaluated one, got nil")
+	}
+
+	// Output formats are evaluated too
+	outputs, err := executor.buildOutputObjects(tool, execCtx, map[string]string{"output": "/out/reads.fastq"})
+	if err != nil {
+		t.Fatalf("Failed to build outputs: %v", err)
+	}
+	file := outputs["output"].(map[string]interface{})
+	if file["format"] != "http://edamontology.org/format_1929" {
+		t.Errorf("Expected output format http://edamontology.org/format_1929, got %v", file["format"])
+	}
+}
@@ -2,4 +2,14 @@ module github.com/user/cwlgo
 
 go 1.23.3
 
-require gopkg.in/yaml.v3 v3.0.1 // indirect
+require (
+	github.com/dop251/goja v0.0.0-20260106131823-651366fbe6e3
+	gopkg.in/yaml.v3 v3.0.1
+)
+
+require (
+	github.com/dlclark/regexp2 v1.11.4 // indirect
+	github.com/go-sourcemap/sourcemap v2.1.3+incompatible // indirect
+	github.com/google/pprof v0.0.0-20230207041349-798e818bf904 // indirect
+	golang.org/x/text v0.21.0 // indirect
+)
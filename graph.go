@@ -0,0 +1,191 @@
+package cwlgo
+
+import (
+	"bytes"
+	"fmt"
+	"net/url"
+	"sort"
+	"strings"
+
+	"gopkg.in/yaml.v3"
+)
+
+// graphMain is the ID of the process run by default from a packed document
+const graphMain = "main"
+
+// selectProcess returns the process named by the URI's fragment. A packed
+// document lists its processes under $graph; without a fragment, the one
+// with ID "main", or the only one, is selected. Step run references to
+// other processes of the graph, such as "#tool", are replaced with those
+// processes, and the selected process inherits the document's cwlVersion,
+// $namespaces and $schemas. Other documents are returned unchanged, after
+// checking that a fragment matches their ID.
+func selectProcess(data []byte, base *url.URL) ([]byte, error) {
+	if base.Fragment == "" && !bytes.Contains(data, []byte("$graph")) {
+		return data, nil
+	}
+
+	var doc map[string]interface{}
+	if err := decodeDocument(data, &doc); err != nil {
+		return nil, err
+	}
+
+	graph, ok := doc["$graph"]
+	if !ok {
+		if id, _ := doc["id"].(string); base.Fragment != "" && !matchesFragment(id, base.Fragment) {
+			return nil, &CWLError{
+				Err:     ErrInvalidCWL,
+				Message: fmt.Sprintf("no process with ID %s in %s", base.Fragment, referenceString(base)),
+			}
+		}
+		return data, nil
+	}
+
+	processes, err := graphProcesses(graph)
+	if err != nil {
+		return nil, err
+	}
+
+	id := base.Fragment
+	if id == "" {
+		switch {
+		case processes[graphMain] != nil:
+			id = graphMain
+		case len(processes) == 1:
+			for only := range processes {
+				id = only
+			}
+		default:
+			return nil, &CWLError{
+				Err:     ErrInvalidCWL,
+				Message: fmt.Sprintf("%s has no %q process; select one of %s with a #fragment", referenceString(base), graphMain, strings.Join(graphIDs(processes), ", ")),
+			}
+		}
+	}
+
+	process, ok := processes[id]
+	if !ok {
+		return nil, &CWLError{
+			Err:     ErrInvalidCWL,
+			Message: fmt.Sprintf("no process with ID %s in %s", id, referenceString(base)),
+		}
+	}
+	if err := inlineGraphRuns(process, processes, []string{id}); err != nil {
+		return nil, err
+	}
+
+	for _, key := range []string{"cwlVersion", "$namespaces", "$schemas"} {
+		if _, ok := process[key]; !ok && doc[key] != nil {
+			process[key] = doc[key]
+		}
+	}
+
+	out, err := yaml.Marshal(process)
+	if err != nil {
+		return nil, &CWLError{Err: err, Message: fmt.Sprintf("failed to read process %s", id)}
+	}
+	return out, nil
+}
+
+// graphProcesses returns the processes of a $graph by ID
+func graphProcesses(graph interface{}) (map[string]map[string]interface{}, error) {
+	items, ok := graph.([]interface{})
+	if !ok {
+		return nil, &CWLError{Err: ErrInvalidCWL, Message: "$graph must be a list of processes"}
+	}
+
+	processes := make(map[string]map[string]interface{}, len(items))
+	for i, item := range items {
+		process, ok := item.(map[string]interface{})
+		if !ok {
+			return nil, &CWLError{Err: ErrInvalidCWL, Message: fmt.Sprintf("$graph[%d] must be a process", i)}
+		}
+		rawID, _ := process["id"].(string)
+		id := graphID(rawID)
+		if id == "" {
+			return nil, &CWLError{Err: ErrInvalidCWL, Message: fmt.Sprintf("$graph[%d] must have an id", i)}
+		}
+		if processes[id] != nil {
+			return nil, &CWLError{Err: ErrInvalidCWL, Message: fmt.Sprintf("duplicate process ID %s in $graph", id)}
+		}
+		process["id"] = id
+		processes[id] = process
+	}
+	return processes, nil
+}
+
+// inlineGraphRuns replaces the run references of a process's steps to
+// other processes of the graph, e.g. "#tool", with those processes,
+// following nested workflows. stack lists the processes being inlined, to
+// detect cycles.
+func inlineGraphRuns(process map[string]interface{}, processes map[string]map[string]interface{}, stack []string) error {
+	steps, _ := process["steps"].(map[string]interface{})
+	for stepID, s := range steps {
+		step, ok := s.(map[string]interface{})
+		if !ok {
+			continue
+		}
+
+		switch run := step["run"].(type) {
+		case string:
+			if !strings.HasPrefix(run, "#") {
+				continue
+			}
+			id := graphID(run)
+			target, ok := processes[id]
+			if !ok {
+				return &CWLError{
+					Err:     ErrInvalidCWL,
+					Message: fmt.Sprintf("step %s runs %s, which is not in $graph", stepID, run),
+				}
+			}
+			for _, seen := range stack {
+				if seen == id {
+					return &CWLError{
+						Err:     ErrInvalidCWL,
+						Message: fmt.Sprintf("step %s runs %s, which contains it", stepID, run),
+					}
+				}
+			}
+			if err := inlineGraphRuns(target, processes, append(stack, id)); err != nil {
+				return err
+			}
+			step["run"] = target
+
+		case map[string]interface{}:
+			if err := inlineGraphRuns(run, processes, stack); err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
+
+// graphID returns the ID of a process without its document, e.g. "main"
+// for "#main" or "packed.cwl#main"
+func graphID(id string) string {
+	_, fragment := splitFragment(id)
+	if fragment != "" || strings.HasSuffix(id, "#") {
+		return fragment
+	}
+	return id
+}
+
+// matchesFragment reports whether a process with an ID is selected by a
+// fragment. A process without an ID is selected as "main".
+func matchesFragment(id, fragment string) bool {
+	if id == "" {
+		return fragment == graphMain
+	}
+	return graphID(id) == fragment
+}
+
+// graphIDs returns the IDs of a graph's processes in order
+func graphIDs(processes map[string]map[string]interface{}) []string {
+	ids := make([]string, 0, len(processes))
+	for id := range processes {
+		ids = append(ids, id)
+	}
+	sort.Strings(ids)
+	return ids
+}
@@ -0,0 +1,285 @@
+package cwlgo
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+const packedCWL = `cwlVersion: v1.2
+$graph:
+  - id: "#main"
+    class: Workflow
+    inputs:
+      message: {type: string}
+    outputs: {}
+    steps:
+      echo:
+        run: "#echo"
+        in: {message: message}
+        out: []
+  - id: echo
+    class: CommandLineTool
+    baseCommand: echo
+    inputs:
+      message:
+        type: string
+        inputBinding: {position: 1}
+    outputs: {}
+`
+
+// cwltoolPackedCWL is the output of cwltool --pack for a workflow running
+// echo.cwl: fields are lists and IDs are scoped by their process
+const cwltoolPackedCWL = `{
+    "$graph": [
+        {
+            "class": "CommandLineTool",
+            "baseCommand": "echo",
+            "inputs": [
+                {
+                    "type": "string",
+                    "inputBinding": {
+                        "position": 1
+                    },
+                    "id": "#echo.cwl/message"
+                }
+            ],
+            "stdout": "output.txt",
+            "outputs": [
+                {
+                    "type": "stdout",
+                    "id": "#echo.cwl/output"
+                }
+            ],
+            "id": "#echo.cwl"
+        },
+        {
+            "class": "Workflow",
+            "inputs": [
+                {
+                    "type": "string",
+                    "id": "#main/message"
+                }
+            ],
+            "outputs": [
+                {
+                    "type": "File",
+                    "outputSource": "#main/echo/output",
+                    "id": "#main/output"
+                }
+            ],
+            "steps": [
+                {
+                    "run": "#echo.cwl",
+                    "in": [
+                        {
+                            "source": "#main/message",
+                            "id": "#main/echo/message"
+                        }
+                    ],
+                    "out": [
+                        "#main/echo/output"
+                    ],
+                    "id": "#main/echo"
+                }
+            ],
+            "id": "#main"
+        }
+    ],
+    "cwlVersion": "v1.2"
+}
+`
+
+func TestParseCwltoolPackedDocument(t *testing.T) {
+	enterTempDir(t)
+	writeFiles(t, ".", map[string]string{"packed.cwl": cwltoolPackedCWL})
+
+	process, err := NewParser().ParseProcessFile("packed.cwl")
+	if err != nil {
+		t.Fatalf("Failed to parse packed document: %v", err)
+	}
+	wf, ok := process.(*Workflow)
+	if !ok {
+		t.Fatalf("Expected a Workflow, got %T", process)
+	}
+	step, ok := wf.Steps["echo"]
+	if !ok {
+		t.Fatalf("Expected step echo, got %v", wf.Steps)
+	}
+	if step.In["message"].Source != "message" || len(step.Out) != 1 || step.Out[0] != "output" {
+		t.Errorf("Expected the step's IDs without their scope, got in %v and out %v", step.In, step.Out)
+	}
+	if wf.Outputs["output"].OutputSource != "echo/output" {
+		t.Errorf("Expected outputSource echo/output, got %v", wf.Outputs["output"].OutputSource)
+	}
+	tool, ok := step.Process.(*CommandLineTool)
+	if !ok {
+		t.Fatalf("Expected the step to run the graph's tool, got %T", step.Process)
+	}
+	if _, ok := tool.Inputs["message"]; !ok {
+		t.Errorf("Expected tool input message, got %v", tool.Inputs)
+	}
+
+	result, err := NewExecutor().ExecuteWorkflow(context.Background(), wf, map[string]interface{}{"message": "packed"})
+	if err != nil {
+		t.Fatalf("Failed to execute packed workflow: %v", err)
+	}
+	output, _ := result.Outputs["output"].(map[string]interface{})
+	data, err := os.ReadFile(fmt.Sprint(output["path"]))
+	if err != nil {
+		t.Fatalf("Failed to read output: %v", err)
+	}
+	if string(data) != "packed\n" {
+		t.Errorf("Expected output %q, got %q", "packed\n", data)
+	}
+
+	report, err := NewParser().ValidateFile("packed.cwl")
+	if err != nil {
+		t.Fatalf("Failed to validate: %v", err)
+	}
+	if len(report.Findings) != 0 {
+		t.Errorf("Expected no findings, got %v", report.Findings)
+	}
+}
+
+func TestParsePackedDocument(t *testing.T) {
+	dir := t.TempDir()
+	writeFiles(t, dir, map[string]string{"packed.cwl": packedCWL})
+	path := filepath.Join(dir, "packed.cwl")
+
+	// Without a fragment, the main process is selected
+	process, err := NewParser().ParseProcessFile(path)
+	if err != nil {
+		t.Fatalf("Failed to parse packed document: %v", err)
+	}
+	wf, ok := process.(*Workflow)
+	if !ok {
+		t.Fatalf("Expected a Workflow, got %T", process)
+	}
+	if wf.CWLVersion != "v1.2" {
+		t.Errorf("Expected cwlVersion inherited from the document, got %q", wf.CWLVersion)
+	}
+	if _, ok := wf.Steps["echo"].Process.(*CommandLineTool); !ok {
+		t.Errorf("Expected the step to run the graph's tool, got %T", wf.Steps["echo"].Process)
+	}
+
+	process, err = NewParser().ParseProcessFile(path + "#echo")
+	if err != nil {
+		t.Fatalf("Failed to parse packed document with a fragment: %v", err)
+	}
+	if tool, ok := process.(*CommandLineTool); !ok || tool.CWLVersion != "v1.2" {
+		t.Errorf("Expected the echo CommandLineTool, got %+v", process)
+	}
+
+	if _, err := NewParser().LoadURI(path + "#echo"); err != nil {
+		t.Errorf("Failed to load packed document with a fragment: %v", err)
+	}
+
+	process, err = NewParser().ParseBytes([]byte(packedCWL))
+	if err != nil {
+		t.Fatalf("Failed to parse packed document from bytes: %v", err)
+	}
+	if _, ok := process.(*Workflow); !ok {
+		t.Errorf("Expected a Workflow, got %T", process)
+	}
+
+	// Steps of standalone documents may run a process of a packed one
+	writeFiles(t, dir, map[string]string{
+		"workflow.cwl": strings.Replace(loaderWorkflowCWL, "tools/echo.cwl", "packed.cwl#echo", 1),
+	})
+	process, err = NewParser().ParseProcessFile(filepath.Join(dir, "workflow.cwl"))
+	if err != nil {
+		t.Fatalf("Failed to parse workflow running a packed process: %v", err)
+	}
+	if _, ok := process.(*Workflow).Steps["echo"].Process.(*CommandLineTool); !ok {
+		t.Errorf("Expected the step to run the packed tool, got %T", process.(*Workflow).Steps["echo"].Process)
+	}
+}
+
+func TestParsePackedDocumentErrors(t *testing.T) {
+	tests := []struct {
+		name     string
+		content  string
+		fragment string
+		expected string
+	}{
+		{
+			name:     "Unknown fragment",
+			content:  packedCWL,
+			fragment: "#missing",
+			expected: "no process with ID missing",
+		},
+		{
+			name:     "No main process",
+			content:  strings.Replace(packedCWL, `"#main"`, "wf", 1),
+			expected: `has no "main" process; select one of echo, wf`,
+		},
+		{
+			name:     "Unknown run reference",
+			content:  strings.Replace(packedCWL, `run: "#echo"`, `run: "#cat"`, 1),
+			expected: "step echo runs #cat, which is not in $graph",
+		},
+		{
+			name:     "Run cycle",
+			content:  strings.Replace(packedCWL, `run: "#echo"`, `run: "#main"`, 1),
+			expected: "step echo runs #main, which contains it",
+		},
+		{
+			name:     "Duplicate ID",
+			content:  strings.Replace(packedCWL, "id: echo", "id: main", 1),
+			expected: "duplicate process ID main in $graph",
+		},
+		{
+			name:     "Fragment of a standalone document",
+			content:  loaderToolCWL,
+			fragment: "#echo",
+			expected: "no process with ID echo",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			dir := t.TempDir()
+			writeFiles(t, dir, map[string]string{"doc.cwl": tt.content})
+
+			_, err := NewParser().ParseProcessFile(filepath.Join(dir, "doc.cwl") + tt.fragment)
+			if err == nil {
+				t.Fatal("Expected error, got nil")
+			}
+			if !strings.Contains(err.Error(), tt.expected) {
+				t.Errorf("Expected error to contain %q, got %v", tt.expected, err)
+			}
+		})
+	}
+}
+
+func TestValidateFilePacked(t *testing.T) {
+	dir := t.TempDir()
+	path := filepath.Join(dir, "packed.cwl")
+	if err := os.WriteFile(path, []byte(packedCWL), 0644); err != nil {
+		t.Fatalf("Failed to write packed document: %v", err)
+	}
+
+	report, err := NewParser().ValidateFile(path)
+	if err != nil {
+		t.Fatalf("Failed to validate: %v", err)
+	}
+	if len(report.Findings) != 0 {
+		t.Errorf("Expected no findings, got %v", report.Findings)
+	}
+
+	invalid := strings.Replace(packedCWL, "in: {message: message}", "in: {message: text}", 1)
+	if err := os.WriteFile(path, []byte(invalid), 0644); err != nil {
+		t.Fatalf("Failed to write packed document: %v", err)
+	}
+	report, err = NewParser().ValidateFile(path + "#main")
+	if err != nil {
+		t.Fatalf("Failed to validate: %v", err)
+	}
+	if len(report.Errors()) != 1 || report.Errors()[0].Line != 11 || !strings.Contains(report.Errors()[0].Message, "text") {
+		t.Errorf("Expected an unknown source error at line 11, got %v", report.Findings)
+	}
+}
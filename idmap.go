@@ -0,0 +1,185 @@
+package cwlgo
+
+import (
+	"strings"
+
+	"gopkg.in/yaml.v3"
+)
+
+// normalizeDocument rewrites the list forms of a document's inputs,
+// outputs, steps and step inputs into the maps keyed by ID that processes
+// are read from, and strips the scope prefixes that packed documents give
+// IDs and references, e.g. "#main/echo/message". Documents that need no
+// rewriting, or that are not valid YAML or JSON, are returned unchanged.
+func normalizeDocument(data []byte) ([]byte, error) {
+	var doc yaml.Node
+	if err := yaml.Unmarshal(data, &doc); err != nil || len(doc.Content) == 0 {
+		return data, nil
+	}
+	if !normalizeDocumentNode(doc.Content[0]) {
+		return data, nil
+	}
+
+	out, err := yaml.Marshal(&doc)
+	if err != nil {
+		return nil, &CWLError{Err: err, Message: "failed to write normalized document"}
+	}
+	return out, nil
+}
+
+// normalizeDocumentNode normalizes the process at the root of a document,
+// or each process of its $graph, and reports whether anything changed
+func normalizeDocumentNode(root *yaml.Node) bool {
+	graph := mappingValue(root, "$graph")
+	if graph == nil {
+		return normalizeProcess(root)
+	}
+	if graph.Kind != yaml.SequenceNode {
+		return false
+	}
+	changed := false
+	for _, process := range graph.Content {
+		if normalizeProcess(process) {
+			changed = true
+		}
+	}
+	return changed
+}
+
+// normalizeProcess normalizes a process node in place, following inline
+// processes of its steps, and reports whether anything changed
+func normalizeProcess(process *yaml.Node) bool {
+	if process.Kind != yaml.MappingNode {
+		return false
+	}
+	scope := graphID(scalarValue(mappingValue(process, "id")))
+
+	changed := false
+	for _, field := range []string{"inputs", "outputs", "steps"} {
+		if toIDMap(mappingValue(process, field), scope) {
+			changed = true
+		}
+	}
+
+	for _, pair := range mappingPairs(mappingValue(process, "outputs")) {
+		if stripScopes(mappingValue(pair[1], "outputSource"), scope) {
+			changed = true
+		}
+	}
+
+	for _, pair := range mappingPairs(mappingValue(process, "steps")) {
+		step := pair[1]
+		stepScope := pair[0].Value
+		if scope != "" {
+			stepScope = scope + "/" + stepScope
+		}
+
+		in := mappingValue(step, "in")
+		if toIDMap(in, stepScope) {
+			changed = true
+		}
+		for _, input := range mappingPairs(in) {
+			source := input[1]
+			if input[1].Kind == yaml.MappingNode {
+				source = mappingValue(input[1], "source")
+			}
+			if stripScopes(source, scope) {
+				changed = true
+			}
+		}
+
+		// Outputs are listed as IDs or as objects with an id
+		if out := mappingValue(step, "out"); out != nil && out.Kind == yaml.SequenceNode {
+			for _, item := range out.Content {
+				if item.Kind == yaml.MappingNode {
+					item = mappingValue(item, "id")
+				}
+				if stripScopes(item, stepScope) {
+					changed = true
+				}
+			}
+		}
+		if stripScopes(mappingValue(step, "scatter"), stepScope) {
+			changed = true
+		}
+
+		if run := mappingValue(step, "run"); run != nil && normalizeProcess(run) {
+			changed = true
+		}
+	}
+	return changed
+}
+
+// toIDMap turns a list of objects with ids into a map from each id, with
+// its scope stripped, to the object without the id. Other nodes are left
+// as they are.
+func toIDMap(node *yaml.Node, scope string) bool {
+	if node == nil || node.Kind != yaml.SequenceNode {
+		return false
+	}
+	for _, item := range node.Content {
+		if scalarValue(mappingValue(item, "id")) == "" {
+			return false
+		}
+	}
+
+	content := make([]*yaml.Node, 0, 2*len(node.Content))
+	for _, item := range node.Content {
+		var fields []*yaml.Node
+		var key *yaml.Node
+		for _, pair := range mappingPairs(item) {
+			if pair[0].Value == "id" {
+				key = pair[1]
+				continue
+			}
+			fields = append(fields, pair[0], pair[1])
+		}
+		item.Content = fields
+		key.Value = stripScope(key.Value, scope)
+		content = append(content, key, item)
+	}
+	*node = yaml.Node{Kind: yaml.MappingNode, Tag: "!!map", Content: content, Line: node.Line, Column: node.Column}
+	return true
+}
+
+// stripScopes strips the scope from a reference or from each of a list of
+// references, and reports whether any changed
+func stripScopes(node *yaml.Node, scope string) bool {
+	if node == nil {
+		return false
+	}
+	nodes := []*yaml.Node{node}
+	if node.Kind == yaml.SequenceNode {
+		nodes = node.Content
+	}
+
+	changed := false
+	for _, item := range nodes {
+		if item.Kind != yaml.ScalarNode {
+			continue
+		}
+		if stripped := stripScope(item.Value, scope); stripped != item.Value {
+			item.Value = stripped
+			changed = true
+		}
+	}
+	return changed
+}
+
+// stripScope returns a reference relative to a scope, e.g. "echo/output"
+// for "#main/echo/output" in the scope "main", or "message" for
+// "#message". Other references are returned unchanged.
+func stripScope(ref, scope string) string {
+	i := strings.Index(ref, "#")
+	if i < 0 {
+		return ref
+	}
+	fragment := ref[i+1:]
+	if scope != "" && strings.HasPrefix(fragment, scope+"/") {
+		return fragment[len(scope)+1:]
+	}
+	if !strings.Contains(fragment, "/") {
+		return fragment
+	}
+	return ref
+}
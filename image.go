@@ -0,0 +1,173 @@
+package cwlgo
+
+import (
+	"bytes"
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"io"
+	"net/http"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+)
+
+// prepareDockerStyleImage makes the image of a DockerRequirement available
+// to a Docker-compatible engine before the tool runs. If the image is
+// already present nothing is done; otherwise it is loaded (dockerLoad),
+// built (dockerFile), imported (dockerImport) or pulled (dockerPull), in that
+// order of preference. The resolved reference is stored in config.ImageID.
+func prepareDockerStyleImage(ctx context.Context, engine string, execCtx *ExecutionContext) error {
+	config := execCtx.Container
+
+	// Images built from a Dockerfile are tagged by dockerImageId or by a
+	// hash of the Dockerfile so identical builds are reused
+	if config.File != "" && config.ImageID == "" {
+		sum := sha256.Sum256([]byte(config.File))
+		config.ImageID = "cwlgo-dockerfile:" + hex.EncodeToString(sum[:])[:12]
+	}
+
+	ref := containerImage(config)
+	if ref != "" && dockerImagePresent(ctx, engine, ref) {
+		config.ImageID = ref
+		return nil
+	}
+
+	switch {
+	case config.Load != "":
+		loaded, err := dockerLoadImage(ctx, engine, config.Load, execCtx.WorkingDir)
+		if err != nil {
+			return err
+		}
+		if ref == "" {
+			ref = loaded
+		}
+
+	case config.File != "":
+		if _, err := runImageCommand(ctx, strings.NewReader(config.File), engine, "build", "-t", ref, "-"); err != nil {
+			return err
+		}
+
+	case config.Import != "":
+		args := []string{"import", resolveImageSource(config.Import, execCtx.WorkingDir)}
+		if ref != "" {
+			args = append(args, ref)
+		}
+		output, err := runImageCommand(ctx, nil, engine, args...)
+		if err != nil {
+			return err
+		}
+		if ref == "" {
+			ref = strings.TrimSpace(output)
+		}
+
+	case config.Pull && config.Image != "":
+		if _, err := runImageCommand(ctx, nil, engine, "pull", config.Image); err != nil {
+			return err
+		}
+		ref = config.Image
+	}
+
+	if ref == "" {
+		return &CWLError{
+			Err:     ErrExecution,
+			Message: "could not determine the container image to run",
+		}
+	}
+
+	config.ImageID = ref
+	return nil
+}
+
+// dockerImagePresent reports whether the engine already has the image
+func dockerImagePresent(ctx context.Context, engine, ref string) bool {
+	cmd := exec.CommandContext(ctx, engine, "image", "inspect", ref)
+	cmd.Stdout = io.Discard
+	cmd.Stderr = io.Discard
+	return cmd.Run() == nil
+}
+
+// dockerLoadImage loads an image tarball from a local path or an http(s)
+// URL and returns the loaded image reference
+func dockerLoadImage(ctx context.Context, engine, source, workingDir string) (string, error) {
+	var input io.Reader
+
+	if strings.HasPrefix(source, "http://") || strings.HasPrefix(source, "https://") {
+		req, err := http.NewRequestWithContext(ctx, http.MethodGet, source, nil)
+		if err != nil {
+			return "", &CWLError{Err: err, Message: fmt.Sprintf("invalid dockerLoad URL: %s", source)}
+		}
+		resp, err := http.DefaultClient.Do(req)
+		if err != nil {
+			return "", &CWLError{Err: err, Message: fmt.Sprintf("failed to download image: %s", source)}
+		}
+		defer resp.Body.Close()
+		if resp.StatusCode != http.StatusOK {
+			return "", &CWLError{
+				Err:     ErrExecution,
+				Message: fmt.Sprintf("failed to download image %s: %s", source, resp.Status),
+			}
+		}
+		input = resp.Body
+	} else {
+		file, err := os.Open(resolveImageSource(source, workingDir))
+		if err != nil {
+			return "", &CWLError{Err: err, Message: fmt.Sprintf("failed to open image archive: %s", source)}
+		}
+		defer file.Close()
+		input = file
+	}
+
+	output, err := runImageCommand(ctx, input, engine, "load")
+	if err != nil {
+		return "", err
+	}
+
+	return parseLoadedImage(output), nil
+}
+
+// parseLoadedImage extracts the image reference from "docker load" or
+// "podman load" output, e.g. "Loaded image: ubuntu:20.04"
+func parseLoadedImage(output string) string {
+	for _, line := range strings.Split(output, "\n") {
+		line = strings.TrimSpace(line)
+		if !strings.HasPrefix(line, "Loaded image") {
+			continue
+		}
+		if _, value, ok := strings.Cut(line, ": "); ok {
+			ref, _, _ := strings.Cut(value, ",")
+			return strings.TrimSpace(ref)
+		}
+	}
+	return ""
+}
+
+// resolveImageSource resolves a relative archive path against workingDir
+func resolveImageSource(source, workingDir string) string {
+	source = strings.TrimPrefix(source, "file://")
+	if filepath.IsAbs(source) || strings.Contains(source, "://") {
+		return source
+	}
+	return filepath.Join(workingDir, source)
+}
+
+// runImageCommand runs an image management command and returns its stdout
+func runImageCommand(ctx context.Context, stdin io.Reader, engine string, args ...string) (string, error) {
+	cmd := exec.CommandContext(ctx, engine, args...)
+	cmd.Stdin = stdin
+
+	var stdout, stderr bytes.Buffer
+	cmd.Stdout = &stdout
+	cmd.Stderr = &stderr
+
+	if err := cmd.Run(); err != nil {
+		return "", &CWLError{
+			Err:     err,
+			Message: fmt.Sprintf("%s %s failed: %s", engine, args[0], strings.TrimSpace(stderr.String())),
+		}
+	}
+
+	return stdout.String(), nil
+}
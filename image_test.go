@@ -0,0 +1,118 @@
+package cwlgo
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+// fakeEngine is a shell script standing in for the docker CLI. It logs its
+// arguments to $FAKE_ENGINE_LOG and reports images as present when
+// $FAKE_ENGINE_PRESENT is set. "ps" lists the names in $FAKE_ENGINE_PS.
+const fakeEngine = `#!/bin/sh
+echo "$@" >> "$FAKE_ENGINE_LOG"
+case "$1" in
+  image) [ -n "$FAKE_ENGINE_PRESENT" ] && exit 0; exit 1 ;;
+  ps) for name in $FAKE_ENGINE_PS; do echo "$name"; done ;;
+  load) cat > /dev/null; echo "Loaded image: test/image:1" ;;
+  build) cat > /dev/null ;;
+esac
+exit 0
+`
+
+func setupFakeEngine(t *testing.T) (string, string) {
+	tempDir := t.TempDir()
+
+	engine := filepath.Join(tempDir, "fake-docker")
+	if err := os.WriteFile(engine, []byte(fakeEngine), 0755); err != nil {
+		t.Fatalf("Failed to write fake engine: %v", err)
+	}
+
+	logFile := filepath.Join(tempDir, "engine.log")
+	t.Setenv("FAKE_ENGINE_LOG", logFile)
+	return engine, logFile
+}
+
+func readEngineLog(t *testing.T, logFile string) string {
+	data, err := os.ReadFile(logFile)
+	if err != nil && !os.IsNotExist(err) {
+		t.Fatalf("Failed to read engine log: %v", err)
+	}
+	return string(data)
+}
+
+func TestPrepareDockerFile(t *testing.T) {
+	engine, logFile := setupFakeEngine(t)
+
+	execCtx := &ExecutionContext{
+		WorkingDir: t.TempDir(),
+		Container: &ContainerConfig{
+			Type: "docker",
+			File: "FROM busybox\n",
+		},
+	}
+
+	if err := prepareDockerStyleImage(context.Background(), engine, execCtx); err != nil {
+		t.Fatalf("Failed to prepare image: %v", err)
+	}
+
+	if !strings.HasPrefix(execCtx.Container.ImageID, "cwlgo-dockerfile:") {
+		t.Errorf("Expected content-hash tag, got %s", execCtx.Container.ImageID)
+	}
+
+	if log := readEngineLog(t, logFile); !strings.Contains(log, "build -t "+execCtx.Container.ImageID+" -") {
+		t.Errorf("Expected image to be built, engine log:\n%s", log)
+	}
+}
+
+func TestPrepareDockerLoad(t *testing.T) {
+	engine, _ := setupFakeEngine(t)
+
+	workDir := t.TempDir()
+	if err := os.WriteFile(filepath.Join(workDir, "image.tar"), []byte("tarball"), 0644); err != nil {
+		t.Fatalf("Failed to write image archive: %v", err)
+	}
+
+	execCtx := &ExecutionContext{
+		WorkingDir: workDir,
+		Container: &ContainerConfig{
+			Type: "docker",
+			Load: "image.tar",
+		},
+	}
+
+	if err := prepareDockerStyleImage(context.Background(), engine, execCtx); err != nil {
+		t.Fatalf("Failed to prepare image: %v", err)
+	}
+
+	if execCtx.Container.ImageID != "test/image:1" {
+		t.Errorf("Expected loaded image test/image:1, got %s", execCtx.Container.ImageID)
+	}
+}
+
+func TestPrepareSkipsPullWhenPresent(t *testing.T) {
+	engine, logFile := setupFakeEngine(t)
+	t.Setenv("FAKE_ENGINE_PRESENT", "1")
+
+	execCtx := &ExecutionContext{
+		Container: &ContainerConfig{
+			Type:  "docker",
+			Image: "ubuntu:20.04",
+			Pull:  true,
+		},
+	}
+
+	if err := prepareDockerStyleImage(context.Background(), engine, execCtx); err != nil {
+		t.Fatalf("Failed to prepare image: %v", err)
+	}
+
+	if log := readEngineLog(t, logFile); strings.Contains(log, "pull") {
+		t.Errorf("Expected no pull for a present image, engine log:\n%s", log)
+	}
+
+	if execCtx.Container.ImageID != "ubuntu:20.04" {
+		t.Errorf("Expected image ubuntu:20.04, got %s", execCtx.Container.ImageID)
+	}
+}
@@ -0,0 +1,79 @@
+package cwlgo
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"gopkg.in/yaml.v3"
+)
+
+// LoadJobFile reads a YAML or JSON job file, the input object of a run.
+// The paths and locations of the Files and Directories in it are resolved
+// relative to the file.
+func LoadJobFile(path string) (map[string]interface{}, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, &CWLError{
+			Err:     err,
+			Message: fmt.Sprintf("failed to open job file: %s", path),
+		}
+	}
+
+	job := make(map[string]interface{})
+	if err := yaml.Unmarshal(data, &job); err != nil {
+		return nil, &CWLError{
+			Err:     err,
+			Message: fmt.Sprintf("failed to parse job file: %s", path),
+		}
+	}
+
+	baseDir, err := filepath.Abs(filepath.Dir(path))
+	if err != nil {
+		return nil, &CWLError{Err: err, Message: "failed to resolve job file directory"}
+	}
+	for inputID, value := range job {
+		job[inputID] = resolveLocations(value, baseDir)
+	}
+	return job, nil
+}
+
+// resolveLocations sets the absolute path and location of each File and
+// Directory in a value from its path or location
+func resolveLocations(value interface{}, baseDir string) interface{} {
+	switch v := value.(type) {
+	case map[string]interface{}:
+		class, _ := v["class"].(string)
+		if class != "File" && class != "Directory" {
+			for key, item := range v {
+				v[key] = resolveLocations(item, baseDir)
+			}
+			return v
+		}
+
+		path, _ := v["path"].(string)
+		if path == "" {
+			location, _ := v["location"].(string)
+			path = strings.TrimPrefix(location, "file://")
+		}
+		if path != "" {
+			if !filepath.IsAbs(path) {
+				path = filepath.Join(baseDir, path)
+			}
+			v["path"] = path
+			v["location"] = "file://" + path
+		}
+		if secondary, ok := v["secondaryFiles"]; ok {
+			v["secondaryFiles"] = resolveLocations(secondary, baseDir)
+		}
+		return v
+
+	case []interface{}:
+		for i, item := range v {
+			v[i] = resolveLocations(item, baseDir)
+		}
+		return v
+	}
+	return value
+}
@@ -0,0 +1,314 @@
+package cwlgo
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+
+	"gopkg.in/yaml.v3"
+)
+
+// Fetcher retrieves the content of CWL documents by URI
+type Fetcher interface {
+	Fetch(uri *url.URL) ([]byte, error)
+}
+
+// FetcherFunc adapts a function to the Fetcher interface
+type FetcherFunc func(uri *url.URL) ([]byte, error)
+
+// Fetch calls f(uri)
+func (f FetcherFunc) Fetch(uri *url.URL) ([]byte, error) {
+	return f(uri)
+}
+
+// FileFetcher reads file:// URIs from the local filesystem
+type FileFetcher struct{}
+
+// Fetch reads the file at the URI's path
+func (FileFetcher) Fetch(uri *url.URL) ([]byte, error) {
+	return os.ReadFile(uri.Path)
+}
+
+// HTTPFetcher retrieves http:// and https:// URIs
+type HTTPFetcher struct {
+	Client *http.Client // http.DefaultClient if nil
+}
+
+// Fetch gets the URI and returns the response body
+func (f *HTTPFetcher) Fetch(uri *url.URL) ([]byte, error) {
+	client := f.Client
+	if client == nil {
+		client = http.DefaultClient
+	}
+
+	resp, err := client.Get(uri.String())
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("GET %s: %s", uri.Redacted(), resp.Status)
+	}
+	return io.ReadAll(resp.Body)
+}
+
+var (
+	fetchersMu sync.RWMutex
+	fetchers   = make(map[string]Fetcher)
+)
+
+func init() {
+	RegisterFetcher("file", FileFetcher{})
+	RegisterFetcher("http", &HTTPFetcher{})
+	RegisterFetcher("https", &HTTPFetcher{})
+}
+
+// RegisterFetcher makes a fetcher handle the URIs of a scheme, such as
+// "https" or an application-specific "db", replacing any fetcher
+// previously registered for it
+func RegisterFetcher(scheme string, fetcher Fetcher) {
+	fetchersMu.Lock()
+	defer fetchersMu.Unlock()
+	fetchers[strings.ToLower(scheme)] = fetcher
+}
+
+// LookupFetcher returns the fetcher registered for a URI scheme
+func LookupFetcher(scheme string) (Fetcher, error) {
+	fetchersMu.RLock()
+	defer fetchersMu.RUnlock()
+
+	fetcher, ok := fetchers[strings.ToLower(scheme)]
+	if !ok {
+		return nil, &CWLError{
+			Err:     ErrUnsupported,
+			Message: fmt.Sprintf("unsupported URI scheme: %s", scheme),
+		}
+	}
+	return fetcher, nil
+}
+
+// Parse parses a CWL document of any supported class from a reader. The
+// format is detected from the content, so the reader need not support
+// seeking. Relative references are resolved against the current directory.
+func (p *Parser) Parse(r io.Reader) (Process, error) {
+	data, err := io.ReadAll(r)
+	if err != nil {
+		return nil, &CWLError{Err: err, Message: "failed to read CWL document"}
+	}
+	return p.ParseBytes(data)
+}
+
+// ParseBytes parses a CWL document of any supported class. Relative
+// references and directives are resolved against the current directory.
+func (p *Parser) ParseBytes(data []byte) (Process, error) {
+	cwd, err := os.Getwd()
+	if err != nil {
+		return nil, &CWLError{Err: err, Message: "failed to get current working directory"}
+	}
+	base := &url.URL{Scheme: "file", Path: cwd + "/"}
+	if data, err = prepareDocument(data, base, p.Root); err != nil {
+		return nil, err
+	}
+	return p.parseProcess(data, base, "")
+}
+
+// LoadURI fetches and parses a CWL document of any supported class, using
+// the fetcher registered for the URI's scheme. A URI without a scheme is a
+// local path. References in the document, such as the run files of steps
+// and $import directives, are resolved against the URI and fetched the
+// same way. The URI's fragment selects a process of a packed document,
+// e.g. "workflow.cwl#main".
+func (p *Parser) LoadURI(uri string) (Process, error) {
+	u, err := parseURI(uri)
+	if err != nil {
+		return nil, err
+	}
+	data, err := fetchDocument(u)
+	if err != nil {
+		return nil, err
+	}
+	if data, err = prepareDocument(data, u, p.Root); err != nil {
+		return nil, err
+	}
+	return p.parseProcess(data, u, "")
+}
+
+// prepareDocument expands a document's directives, rewrites list forms of
+// its fields into maps and, for packed documents, selects the process
+// named by the URI's fragment. Directives may only name local files under
+// root, when set.
+func prepareDocument(data []byte, base *url.URL, root string) ([]byte, error) {
+	data, err := resolveDirectives(data, base, root)
+	if err != nil {
+		return nil, err
+	}
+	if data, err = normalizeDocument(data); err != nil {
+		return nil, err
+	}
+	return selectProcess(data, base)
+}
+
+// fetchDocument retrieves a document with the fetcher for its scheme
+func fetchDocument(uri *url.URL) ([]byte, error) {
+	fetcher, err := LookupFetcher(uri.Scheme)
+	if err != nil {
+		return nil, err
+	}
+	data, err := fetcher.Fetch(uri)
+	if err != nil {
+		return nil, &CWLError{
+			Err:     err,
+			Message: fmt.Sprintf("failed to fetch CWL document: %s", referenceString(uri)),
+		}
+	}
+	return data, nil
+}
+
+// parseURI parses an absolute URI, or turns a local path with an optional
+// fragment into a file URI
+func parseURI(uri string) (*url.URL, error) {
+	if !strings.Contains(uri, "://") {
+		path, fragment := splitFragment(uri)
+		u, err := fileURI(path)
+		if err != nil {
+			return nil, err
+		}
+		u.Fragment = fragment
+		return u, nil
+	}
+	u, err := url.Parse(uri)
+	if err != nil {
+		return nil, &CWLError{Err: err, Message: fmt.Sprintf("invalid URI: %s", uri)}
+	}
+	return u, nil
+}
+
+// fileURI returns the file URI of a local path
+func fileURI(path string) (*url.URL, error) {
+	abs, err := filepath.Abs(path)
+	if err != nil {
+		return nil, &CWLError{Err: err, Message: fmt.Sprintf("failed to resolve path: %s", path)}
+	}
+	return &url.URL{Scheme: "file", Path: abs}, nil
+}
+
+// splitFragment splits a path or URI at its fragment, e.g. into
+// "workflow.cwl" and "main" for "workflow.cwl#main"
+func splitFragment(ref string) (string, string) {
+	if i := strings.LastIndex(ref, "#"); i >= 0 {
+		return ref[:i], ref[i+1:]
+	}
+	return ref, ""
+}
+
+// resolveReference resolves a reference in a document, such as a step's
+// run file, against the document's URI. Relative references are paths,
+// optionally with a fragment; a fragment alone, e.g. "#tool", refers to
+// the document itself.
+func resolveReference(base *url.URL, ref string) (*url.URL, error) {
+	if strings.Contains(ref, "://") {
+		return parseURI(ref)
+	}
+	path, fragment := splitFragment(ref)
+	if path == "" {
+		resolved := *base
+		resolved.Fragment = fragment
+		return &resolved, nil
+	}
+	if base.Scheme == "file" {
+		if !filepath.IsAbs(path) {
+			path = filepath.Join(filepath.Dir(base.Path), path)
+		}
+		resolved, err := fileURI(path)
+		if err != nil {
+			return nil, err
+		}
+		resolved.Fragment = fragment
+		return resolved, nil
+	}
+	return base.ResolveReference(&url.URL{Path: path, Fragment: fragment}), nil
+}
+
+// checkReference refuses a resolved reference that a document may not
+// make: to a local file from a remote document, or to a local file outside
+// root, when set
+func checkReference(base, uri *url.URL, root string) error {
+	if uri.Scheme != "file" {
+		return nil
+	}
+	if base.Scheme != "file" {
+		return &CWLError{
+			Err:     ErrInvalidCWL,
+			Message: fmt.Sprintf("remote document %s may not refer to local file %s", referenceString(base), uri.Path),
+		}
+	}
+	if root != "" && !withinRoot(uri.Path, root) {
+		return &CWLError{
+			Err:     ErrInvalidCWL,
+			Message: fmt.Sprintf("%s refers to %s, which is outside the root %s", referenceString(base), uri.Path, root),
+		}
+	}
+	return nil
+}
+
+// checkAbsoluteReference refuses an absolute path or URI that a document
+// may not refer to, as checkReference does
+func checkAbsoluteReference(base *url.URL, ref, root string) error {
+	uri, err := parseURI(ref)
+	if err != nil {
+		return err
+	}
+	return checkReference(base, uri, root)
+}
+
+// withinRoot reports whether a path is root or under it, once symbolic
+// links are followed
+func withinRoot(path, root string) bool {
+	rel, err := filepath.Rel(realPath(root), realPath(path))
+	return err == nil && rel != ".." && !strings.HasPrefix(rel, ".."+string(filepath.Separator))
+}
+
+// realPath returns the absolute form of a path with symbolic links
+// followed, or only made absolute if it cannot be resolved
+func realPath(path string) string {
+	abs, err := filepath.Abs(path)
+	if err != nil {
+		return path
+	}
+	if resolved, err := filepath.EvalSymlinks(abs); err == nil {
+		return resolved
+	}
+	return abs
+}
+
+// referenceString returns a resolved reference as a local path for file
+// URIs, and as a URL otherwise
+func referenceString(uri *url.URL) string {
+	if uri.Scheme == "file" {
+		return uri.Path
+	}
+	return uri.String()
+}
+
+// decodeDocument decodes a YAML or JSON document, telling them apart by
+// the first non-space character: JSON documents are objects
+func decodeDocument(data []byte, v interface{}) error {
+	if trimmed := bytes.TrimSpace(data); len(trimmed) > 0 && trimmed[0] == '{' {
+		if err := json.Unmarshal(data, v); err != nil {
+			return &CWLError{Err: err, Message: "failed to parse JSON"}
+		}
+		return nil
+	}
+	if err := yaml.Unmarshal(data, v); err != nil {
+		return &CWLError{Err: err, Message: "failed to parse YAML"}
+	}
+	return nil
+}
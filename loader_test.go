@@ -0,0 +1,220 @@
+package cwlgo
+
+import (
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+const loaderToolCWL = `cwlVersion: v1.2
+class: CommandLineTool
+baseCommand: echo
+$schemas: [formats.owl]
+inputs:
+  message:
+    type: string
+    inputBinding: {position: 1}
+outputs: {}
+`
+
+const loaderWorkflowCWL = `cwlVersion: v1.2
+class: Workflow
+inputs:
+  message: {type: string}
+outputs: {}
+steps:
+  echo:
+    run: tools/echo.cwl
+    in: {message: message}
+    out: []
+`
+
+func TestParseBytes(t *testing.T) {
+	tests := []struct {
+		name    string
+		content string
+		class   string
+	}{
+		{name: "YAML", content: loaderToolCWL, class: "CommandLineTool"},
+		{
+			name:    "JSON",
+			content: ` {"cwlVersion": "v1.2", "class": "ExpressionTool", "expression": "$({'out': 1})", "inputs": {}, "outputs": {"out": {"type": "int"}}}`,
+			class:   "ExpressionTool",
+		},
+		{
+			name:    "List forms",
+			content: "cwlVersion: v1.2\nclass: CommandLineTool\nbaseCommand: echo\ninputs:\n  - id: message\n    type: string\noutputs: []\n",
+			class:   "CommandLineTool",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			process, err := NewParser().ParseBytes([]byte(tt.content))
+			if err != nil {
+				t.Fatalf("Failed to parse: %v", err)
+			}
+			class := ""
+			switch p := process.(type) {
+			case *CommandLineTool:
+				class = p.Class
+			case *ExpressionTool:
+				class = p.Class
+			}
+			if class != tt.class {
+				t.Errorf("Expected %s, got %T", tt.class, process)
+			}
+		})
+	}
+}
+
+func TestParseReader(t *testing.T) {
+	// A pipe cannot seek, so the format must be detected from the content
+	r, w := io.Pipe()
+	go func() {
+		w.Write([]byte(loaderToolCWL))
+		w.Close()
+	}()
+
+	process, err := NewParser().Parse(r)
+	if err != nil {
+		t.Fatalf("Failed to parse from a pipe: %v", err)
+	}
+	if _, ok := process.(*CommandLineTool); !ok {
+		t.Errorf("Expected a CommandLineTool, got %T", process)
+	}
+}
+
+func TestLoadURI(t *testing.T) {
+	docs := map[string]string{
+		"/cwl/workflow.cwl":       loaderWorkflowCWL,
+		"/cwl/tools/echo.cwl":     loaderToolCWL,
+		"/cwl/missing-run.cwl":    strings.Replace(loaderWorkflowCWL, "tools/echo.cwl", "tools/none.cwl", 1),
+		"/cwl/absolute-run.cwl":   strings.Replace(loaderWorkflowCWL, "tools/echo.cwl", "db://tools/echo", 1),
+		"/cwl/unknown-scheme.cwl": strings.Replace(loaderWorkflowCWL, "tools/echo.cwl", "ftp://host/echo.cwl", 1),
+	}
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		doc, ok := docs[r.URL.Path]
+		if !ok {
+			http.NotFound(w, r)
+			return
+		}
+		io.WriteString(w, doc)
+	}))
+	defer server.Close()
+
+	// Relative run references are fetched from the same server
+	process, err := NewParser().LoadURI(server.URL + "/cwl/workflow.cwl")
+	if err != nil {
+		t.Fatalf("Failed to load workflow: %v", err)
+	}
+	wf, ok := process.(*Workflow)
+	if !ok {
+		t.Fatalf("Expected a Workflow, got %T", process)
+	}
+	tool, ok := wf.Steps["echo"].Process.(*CommandLineTool)
+	if !ok {
+		t.Fatalf("Expected the step to run a CommandLineTool, got %T", wf.Steps["echo"].Process)
+	}
+	if expected := server.URL + "/cwl/tools/formats.owl"; len(tool.Schemas) != 1 || tool.Schemas[0] != expected {
+		t.Errorf("Expected $schemas resolved to %s, got %v", expected, tool.Schemas)
+	}
+
+	if _, err := NewParser().LoadURI(server.URL + "/cwl/missing-run.cwl"); err == nil || !strings.Contains(err.Error(), "404") {
+		t.Errorf("Expected a 404 error for a missing run document, got %v", err)
+	}
+	if _, err := NewParser().LoadURI(server.URL + "/cwl/unknown-scheme.cwl"); err == nil || !strings.Contains(err.Error(), "unsupported URI scheme: ftp") {
+		t.Errorf("Expected an unsupported scheme error, got %v", err)
+	}
+
+	// Custom fetchers handle their own schemes
+	RegisterFetcher("db", FetcherFunc(func(uri *url.URL) ([]byte, error) {
+		if uri.Host+uri.Path != "tools/echo" {
+			t.Errorf("Expected db://tools/echo, got %s", uri)
+		}
+		return []byte(loaderToolCWL), nil
+	}))
+	if _, err := NewParser().LoadURI(server.URL + "/cwl/absolute-run.cwl"); err != nil {
+		t.Errorf("Failed to load a run document from a custom fetcher: %v", err)
+	}
+}
+
+func TestRemoteDocumentLocalReferences(t *testing.T) {
+	dir := t.TempDir()
+	writeFiles(t, dir, map[string]string{"echo.cwl": loaderToolCWL, "secret.txt": "secret"})
+	echo := "file://" + filepath.Join(dir, "echo.cwl")
+	secret := filepath.Join(dir, "secret.txt")
+
+	docs := map[string]string{
+		"/run.cwl":      strings.Replace(loaderWorkflowCWL, "tools/echo.cwl", echo, 1),
+		"/import.cwl":   strings.Replace(loaderWorkflowCWL, "run: tools/echo.cwl", "run: {$import: \""+echo+"\"}", 1),
+		"/include.cwl":  strings.Replace(loaderToolCWL, "baseCommand: echo", "baseCommand: {$include: \"file://"+secret+"\"}", 1),
+		"/location.cwl": strings.Replace(loaderToolCWL, "type: string", "type: File\n    default: {class: File, location: \"file://"+secret+"\"}", 1),
+		"/path.cwl":     strings.Replace(loaderToolCWL, "type: string", "type: File\n    default: {class: File, path: "+secret+"}", 1),
+	}
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		doc, ok := docs[r.URL.Path]
+		if !ok {
+			http.NotFound(w, r)
+			return
+		}
+		io.WriteString(w, doc)
+	}))
+	defer server.Close()
+
+	for path := range docs {
+		_, err := NewParser().LoadURI(server.URL + path)
+		if err == nil || !strings.Contains(err.Error(), "may not refer to local file") {
+			t.Errorf("Expected %s to be refused a local file, got %v", path, err)
+		}
+	}
+}
+
+func TestParserRoot(t *testing.T) {
+	dir := t.TempDir()
+	root := filepath.Join(dir, "root")
+	writeFiles(t, dir, map[string]string{
+		"outside/echo.cwl":    loaderToolCWL,
+		"outside/secret.txt":  "secret",
+		"root/tools/echo.cwl": loaderToolCWL,
+		"root/workflow.cwl":   loaderWorkflowCWL,
+		"root/run.cwl":        strings.Replace(loaderWorkflowCWL, "tools/echo.cwl", "../outside/echo.cwl", 1),
+		"root/link.cwl":       strings.Replace(loaderWorkflowCWL, "tools/echo.cwl", "tools/link.cwl", 1),
+		"root/include.cwl":    strings.Replace(loaderToolCWL, "baseCommand: echo", "baseCommand: {$include: ../outside/secret.txt}", 1),
+		"root/default.cwl":    strings.Replace(loaderToolCWL, "type: string", "type: File\n    default: {class: File, path: ../outside/secret.txt}", 1),
+	})
+	if err := os.Symlink(filepath.Join(dir, "outside", "echo.cwl"), filepath.Join(root, "tools", "link.cwl")); err != nil {
+		t.Fatalf("Failed to create symlink: %v", err)
+	}
+
+	parser := NewParser()
+	parser.Root = root
+
+	if _, err := parser.ParseProcessFile(filepath.Join(root, "workflow.cwl")); err != nil {
+		t.Errorf("Failed to parse a workflow within the root: %v", err)
+	}
+	for _, name := range []string{"run.cwl", "link.cwl", "include.cwl", "default.cwl"} {
+		_, err := parser.ParseProcessFile(filepath.Join(root, name))
+		if err == nil || !strings.Contains(err.Error(), "outside the root") {
+			t.Errorf("Expected %s to be refused a file outside the root, got %v", name, err)
+		}
+	}
+
+	// Without a root, references are not confined
+	if _, err := NewParser().ParseProcessFile(filepath.Join(root, "run.cwl")); err != nil {
+		t.Errorf("Failed to parse a workflow without a root: %v", err)
+	}
+
+	report, err := parser.ValidateFile(filepath.Join(root, "run.cwl"))
+	if err != nil {
+		t.Fatalf("Failed to validate: %v", err)
+	}
+	if errs := report.Errors(); len(errs) == 0 || !strings.Contains(errs[0].Message, "outside the root") {
+		t.Errorf("Expected an outside the root error, got %v", report.Findings)
+	}
+}
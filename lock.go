@@ -0,0 +1,131 @@
+package cwlgo
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// createLockFile creates path, holding the pid and host of this process,
+// and returns a function that removes it. It fails with an error
+// satisfying os.IsExist if the file already exists.
+func createLockFile(path string) (func(), error) {
+	file, err := os.OpenFile(path, os.O_CREATE|os.O_EXCL|os.O_WRONLY, 0644)
+	if err != nil {
+		return nil, err
+	}
+	host, _ := os.Hostname()
+	fmt.Fprintf(file, "%d %s\n", os.Getpid(), host)
+	file.Close()
+	return func() { os.Remove(path) }, nil
+}
+
+// readLockOwner returns the pid and host written into a lock file. The host
+// is empty for locks holding only a pid. ok is false if the file holds
+// neither, e.g. because it is still being written.
+func readLockOwner(path string) (pid int, host string, ok bool, err error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return 0, "", false, err
+	}
+	fields := strings.Fields(string(data))
+	if len(fields) == 0 {
+		return 0, "", false, nil
+	}
+	pid, convErr := strconv.Atoi(fields[0])
+	if convErr != nil {
+		return 0, "", false, nil
+	}
+	if len(fields) > 1 {
+		host = fields[1]
+	}
+	return pid, host, true, nil
+}
+
+// lockOwnerGone reports whether the process holding a lock is known to
+// have exited, which can only be checked on the host it ran on
+func lockOwnerGone(pid int, host string) bool {
+	if localHost, _ := os.Hostname(); host != "" && host != localHost {
+		return false
+	}
+	return !processAlive(pid)
+}
+
+// staleLockAge is how old a lock file must be before it is considered left
+// behind by a crashed process
+const staleLockAge = 2 * time.Hour
+
+// acquireFileLock takes an exclusive lock by creating path, waiting while
+// another process holds it. It returns a function that releases the lock.
+func acquireFileLock(ctx context.Context, path string) (func(), error) {
+	for {
+		unlock, err := createLockFile(path)
+		if err == nil {
+			return unlock, nil
+		}
+		if !os.IsExist(err) {
+			return nil, &CWLError{Err: err, Message: fmt.Sprintf("failed to create lock file: %s", path)}
+		}
+
+		// Break locks left behind by crashed processes on this host, and by
+		// any process once they are old enough
+		if pid, host, ok, err := readLockOwner(path); err == nil && ok && lockOwnerGone(pid, host) {
+			os.Remove(path)
+			continue
+		}
+		if info, err := os.Stat(path); err == nil && time.Since(info.ModTime()) > staleLockAge {
+			os.Remove(path)
+			continue
+		}
+
+		select {
+		case <-ctx.Done():
+			return nil, &CWLError{Err: ctx.Err(), Message: fmt.Sprintf("timed out waiting for lock: %s", path)}
+		case <-time.After(500 * time.Millisecond):
+		}
+	}
+}
+
+// lockRun creates the lock file of a run directory, breaking a lock left
+// behind by a process that is no longer running. It returns a function that
+// releases the lock.
+func lockRun(runDir, runID string) (func(), error) {
+	path := filepath.Join(runDir, runLockFile)
+	for {
+		unlock, err := createLockFile(path)
+		if err == nil {
+			return unlock, nil
+		}
+		if !os.IsExist(err) {
+			return nil, &CWLError{Err: err, Message: fmt.Sprintf("failed to lock run %s", runID)}
+		}
+
+		pid, host, ok, err := readLockOwner(path)
+		if err != nil && !os.IsNotExist(err) {
+			return nil, &CWLError{Err: err, Message: fmt.Sprintf("failed to read lock of run %s", runID)}
+		}
+		if ok && !lockOwnerGone(pid, host) {
+			owner := fmt.Sprintf("process %d", pid)
+			if host != "" {
+				owner += " on " + host
+			}
+			return nil, &CWLError{
+				Err:     ErrExecution,
+				Message: fmt.Sprintf("run %s is already being executed by %s", runID, owner),
+			}
+		}
+
+		// A lock without a pid may be one still being written
+		if info, statErr := os.Stat(path); !ok && statErr == nil && time.Since(info.ModTime()) < staleLockAge {
+			return nil, &CWLError{
+				Err:     ErrExecution,
+				Message: fmt.Sprintf("run %s is already being executed", runID),
+			}
+		}
+		os.Remove(path)
+	}
+}
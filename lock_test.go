@@ -0,0 +1,48 @@
+package cwlgo
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+func TestAcquireFileLockStale(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "image.sif.lock")
+	host, _ := os.Hostname()
+
+	// A lock of a process that is gone from this host is broken at once
+	if err := os.WriteFile(path, []byte(fmt.Sprintf("1073741824 %s\n", host)), 0644); err != nil {
+		t.Fatalf("Failed to write lock file: %v", err)
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), 2*time.Second)
+	defer cancel()
+	unlock, err := acquireFileLock(ctx, path)
+	if err != nil {
+		t.Fatalf("Failed to break stale lock: %v", err)
+	}
+
+	// The new lock records this process
+	pid, lockHost, ok, err := readLockOwner(path)
+	if err != nil || !ok || pid != os.Getpid() || lockHost != host {
+		t.Errorf("Expected lock of process %d on %s, got %d on %s (%v)", os.Getpid(), host, pid, lockHost, err)
+	}
+	unlock()
+
+	// Locks of live processes and of other hosts are waited for
+	for _, owner := range []string{
+		fmt.Sprintf("%d %s", os.Getpid(), host),
+		"1073741824 some-other-host",
+	} {
+		if err := os.WriteFile(path, []byte(owner+"\n"), 0644); err != nil {
+			t.Fatalf("Failed to write lock file: %v", err)
+		}
+		ctx, cancel := context.WithTimeout(context.Background(), 100*time.Millisecond)
+		if _, err := acquireFileLock(ctx, path); err == nil {
+			t.Errorf("Expected to wait for the lock held by %q, got nil", owner)
+		}
+		cancel()
+	}
+}
@@ -1,9 +1,8 @@
 package cwlgo
 
 import (
-	"encoding/json"
 	"fmt"
-	"io"
+	"net/url"
 	"os"
 	"path/filepath"
 	"strings"
@@ -15,6 +14,12 @@ import (
 type Parser struct {
 	// Configuration options for the parser
 	StrictValidation bool
+
+	// Root, when set, confines the local files that documents refer to,
+	// such as run files, directive targets and default Files, to this
+	// directory. Remote documents may never refer to local files.
+	Root string
+
 	// Add more configuration options as needed
 }
 
@@ -27,76 +32,468 @@ func NewParser() *Parser {
 
 // ParseFile parses a CWL file and returns a CommandLineTool
 func (p *Parser) ParseFile(filePath string) (*CommandLineTool, error) {
-	// Open the file
-	file, err := os.Open(filePath)
+	data, base, err := readDocumentFile(filePath, p.Root)
 	if err != nil {
-		return nil, &CWLError{
+		return nil, err
+	}
+	return p.parseCommandLineTool(data, base, "")
+}
+
+// ParseWorkflowFile parses a CWL Workflow file and loads the processes run
+// by its steps
+func (p *Parser) ParseWorkflowFile(filePath string) (*Workflow, error) {
+	data, base, err := readDocumentFile(filePath, p.Root)
+	if err != nil {
+		return nil, err
+	}
+	return p.parseWorkflow(data, base, "")
+}
+
+// ParseExpressionToolFile parses a CWL ExpressionTool file
+func (p *Parser) ParseExpressionToolFile(filePath string) (*ExpressionTool, error) {
+	data, base, err := readDocumentFile(filePath, p.Root)
+	if err != nil {
+		return nil, err
+	}
+	return p.parseExpressionTool(data, base, "")
+}
+
+// ParseProcessFile parses a CWL file of any supported class: a
+// CommandLineTool, Workflow or ExpressionTool
+func (p *Parser) ParseProcessFile(filePath string) (Process, error) {
+	data, base, err := readDocumentFile(filePath, p.Root)
+	if err != nil {
+		return nil, err
+	}
+	return p.parseProcess(data, base, "")
+}
+
+// readDocumentFile reads a local CWL file and returns its content and URI.
+// Directives are expanded, and a fragment such as "packed.cwl#main"
+// selects a process of a packed document.
+func readDocumentFile(filePath, root string) ([]byte, *url.URL, error) {
+	base, err := parseURI(filePath)
+	if err != nil {
+		return nil, nil, err
+	}
+	data, err := os.ReadFile(base.Path)
+	if err != nil {
+		return nil, nil, &CWLError{
 			Err:     err,
 			Message: fmt.Sprintf("failed to open CWL file: %s", filePath),
 		}
 	}
-	defer file.Close()
+	if data, err = prepareDocument(data, base, root); err != nil {
+		return nil, nil, err
+	}
+	return data, base, nil
+}
 
-	// Determine the file format based on extension
-	var tool *CommandLineTool
-	ext := strings.ToLower(filepath.Ext(filePath))
+// parseProcess parses a document of any supported class. References in
+// the document are resolved against base, and a document without a
+// cwlVersion inherits the given one.
+func (p *Parser) parseProcess(data []byte, base *url.URL, cwlVersion string) (Process, error) {
+	var doc struct {
+		Class string `yaml:"class" json:"class"`
+	}
+	if err := decodeDocument(data, &doc); err != nil {
+		return nil, err
+	}
 
-	switch ext {
-	case ".json":
-		tool, err = p.parseJSON(file)
-	case ".yaml", ".yml":
-		tool, err = p.parseYAML(file)
+	switch doc.Class {
+	case "CommandLineTool":
+		return p.parseCommandLineTool(data, base, cwlVersion)
+	case "Workflow":
+		return p.parseWorkflow(data, base, cwlVersion)
+	case "ExpressionTool":
+		return p.parseExpressionTool(data, base, cwlVersion)
 	default:
-		// Try to parse as YAML first, then JSON if that fails
-		tool, err = p.parseYAML(file)
-		if err != nil {
-			// Reset file position to beginning
-			if _, seekErr := file.Seek(0, 0); seekErr != nil {
-				return nil, &CWLError{
-					Err:     seekErr,
-					Message: "failed to reset file position",
-				}
-			}
-			tool, err = p.parseJSON(file)
+		return nil, &CWLError{
+			Err:     ErrUnsupported,
+			Message: fmt.Sprintf("unsupported process class: %s", doc.Class),
 		}
 	}
+}
 
-	if err != nil {
+// parseCommandLineTool parses and validates a CommandLineTool document
+func (p *Parser) parseCommandLineTool(data []byte, base *url.URL, cwlVersion string) (*CommandLineTool, error) {
+	var tool CommandLineTool
+	if err := decodeDocument(data, &tool); err != nil {
+		return nil, err
+	}
+	if tool.CWLVersion == "" {
+		tool.CWLVersion = cwlVersion
+	}
+
+	// Resolve local $schemas and default Files relative to the document
+	if err := resolveSchemas(tool.Schemas, base, p.Root); err != nil {
 		return nil, err
 	}
+	for _, input := range tool.Inputs {
+		if err := resolveDefaultFiles(input.Default, base, p.Root); err != nil {
+			return nil, err
+		}
+	}
 
 	// Validate the parsed tool
-	if err := p.validateCommandLineTool(tool); err != nil {
+	if err := p.validateCommandLineTool(&tool); err != nil {
 		return nil, err
 	}
 
-	return tool, nil
+	return &tool, nil
 }
 
-// parseYAML parses a CWL document from YAML format
-func (p *Parser) parseYAML(r io.Reader) (*CommandLineTool, error) {
-	var tool CommandLineTool
-	decoder := yaml.NewDecoder(r)
-	if err := decoder.Decode(&tool); err != nil {
-		return nil, &CWLError{
-			Err:     err,
-			Message: "failed to parse YAML",
+// parseWorkflow parses a Workflow document, loads the processes run by
+// its steps and validates it
+func (p *Parser) parseWorkflow(data []byte, base *url.URL, cwlVersion string) (*Workflow, error) {
+	var wf Workflow
+	if err := decodeDocument(data, &wf); err != nil {
+		return nil, err
+	}
+	if wf.CWLVersion == "" {
+		wf.CWLVersion = cwlVersion
+	}
+
+	if err := resolveSchemas(wf.Schemas, base, p.Root); err != nil {
+		return nil, err
+	}
+	for _, input := range wf.Inputs {
+		if err := resolveDefaultFiles(input.Default, base, p.Root); err != nil {
+			return nil, err
+		}
+	}
+	for _, step := range wf.Steps {
+		for _, in := range step.In {
+			if err := resolveDefaultFiles(in.Default, base, p.Root); err != nil {
+				return nil, err
+			}
+		}
+	}
+
+	if err := p.loadSteps(&wf, base); err != nil {
+		return nil, err
+	}
+
+	if err := p.validateWorkflow(&wf); err != nil {
+		return nil, err
+	}
+
+	return &wf, nil
+}
+
+// parseExpressionTool parses and validates an ExpressionTool document
+func (p *Parser) parseExpressionTool(data []byte, base *url.URL, cwlVersion string) (*ExpressionTool, error) {
+	var tool ExpressionTool
+	if err := decodeDocument(data, &tool); err != nil {
+		return nil, err
+	}
+	if tool.CWLVersion == "" {
+		tool.CWLVersion = cwlVersion
+	}
+
+	if err := resolveSchemas(tool.Schemas, base, p.Root); err != nil {
+		return nil, err
+	}
+	for _, input := range tool.Inputs {
+		if err := resolveDefaultFiles(input.Default, base, p.Root); err != nil {
+			return nil, err
 		}
 	}
+
+	if err := p.validateExpressionTool(&tool); err != nil {
+		return nil, err
+	}
+
 	return &tool, nil
 }
 
-// parseJSON parses a CWL document from JSON format
-func (p *Parser) parseJSON(r io.Reader) (*CommandLineTool, error) {
-	var tool CommandLineTool
-	decoder := json.NewDecoder(r)
-	if err := decoder.Decode(&tool); err != nil {
+// resolveSchemas rewrites relative $schemas entries against the document's
+// URI: to local paths for local documents, and to URLs otherwise. Entries
+// naming local files the document may not refer to are refused.
+func resolveSchemas(schemas []string, base *url.URL, root string) error {
+	for i, schema := range schemas {
+		if strings.Contains(schema, "://") || filepath.IsAbs(schema) {
+			if err := checkAbsoluteReference(base, schema, root); err != nil {
+				return err
+			}
+			continue
+		}
+		resolved, err := resolveReference(base, schema)
+		if err != nil {
+			continue
+		}
+		if err := checkReference(base, resolved, root); err != nil {
+			return err
+		}
+		schemas[i] = referenceString(resolved)
+	}
+	return nil
+}
+
+// resolveDefaultFiles rewrites the relative paths and locations of the
+// Files and Directories in a default value against the document's URI, so
+// that they do not depend on the directory the job runs in. Locations
+// become URIs, as do relative paths in remote documents. Files the
+// document may not refer to are refused.
+func resolveDefaultFiles(value interface{}, base *url.URL, root string) error {
+	switch v := value.(type) {
+	case map[string]interface{}:
+		if class, _ := v["class"].(string); class == "File" || class == "Directory" {
+			for _, key := range []string{"location", "path"} {
+				ref, ok := v[key].(string)
+				if !ok || ref == "" {
+					continue
+				}
+				if strings.Contains(ref, "://") || filepath.IsAbs(ref) {
+					if err := checkAbsoluteReference(base, ref, root); err != nil {
+						return err
+					}
+					continue
+				}
+				resolved, err := resolveReference(base, ref)
+				if err != nil {
+					continue
+				}
+				if err := checkReference(base, resolved, root); err != nil {
+					return err
+				}
+				if key == "path" && resolved.Scheme == "file" {
+					v[key] = resolved.Path
+				} else {
+					delete(v, key)
+					v["location"] = resolved.String()
+				}
+			}
+		}
+		// secondaryFiles and listing hold Files too
+		for _, item := range v {
+			if err := resolveDefaultFiles(item, base, root); err != nil {
+				return err
+			}
+		}
+	case []interface{}:
+		for _, item := range v {
+			if err := resolveDefaultFiles(item, base, root); err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
+
+// validateExpressionTool validates a parsed ExpressionTool
+func (p *Parser) validateExpressionTool(tool *ExpressionTool) error {
+	if tool.CWLVersion == "" {
+		return &CWLError{
+			Err:     ErrInvalidCWL,
+			Message: "cwlVersion is required",
+		}
+	}
+
+	if tool.Class != "ExpressionTool" {
+		return &CWLError{
+			Err:     ErrInvalidCWL,
+			Message: "class must be 'ExpressionTool'",
+		}
+	}
+
+	if !IsExpression(tool.Expression) {
+		return &CWLError{
+			Err:     ErrInvalidCWL,
+			Message: "expression is required and must be a CWL expression",
+		}
+	}
+
+	return nil
+}
+
+// loadSteps loads the process each step runs, from a document referenced
+// relative to base or from an inline document
+func (p *Parser) loadSteps(wf *Workflow, base *url.URL) error {
+	for stepID, step := range wf.Steps {
+		if step.ID == "" {
+			step.ID = stepID
+		}
+
+		process, err := p.loadProcess(step.Run, base, wf.CWLVersion)
+		if err != nil {
+			return &CWLError{
+				Err:     err,
+				Message: fmt.Sprintf("failed to load run of step %s", stepID),
+			}
+		}
+		step.Process = process
+
+		wf.Steps[stepID] = step
+	}
+	return nil
+}
+
+// loadProcess loads the process referenced by a step's run field, fetching
+// referenced documents by URI. Inline processes inherit cwlVersion from the
+// enclosing document.
+func (p *Parser) loadProcess(run interface{}, base *url.URL, cwlVersion string) (Process, error) {
+	switch r := run.(type) {
+	case string:
+		uri, err := resolveReference(base, r)
+		if err != nil {
+			return nil, err
+		}
+		if err := checkReference(base, uri, p.Root); err != nil {
+			return nil, err
+		}
+		data, err := fetchDocument(uri)
+		if err != nil {
+			return nil, err
+		}
+		if data, err = prepareDocument(data, uri, p.Root); err != nil {
+			return nil, err
+		}
+		return p.parseProcess(data, uri, "")
+
+	case map[string]interface{}:
+		class, _ := r["class"].(string)
+
+		// Round-trip the inline document through YAML into its typed form
+		data, err := yaml.Marshal(r)
+		if err != nil {
+			return nil, &CWLError{Err: err, Message: "failed to read inline process"}
+		}
+
+		switch class {
+		case "CommandLineTool", "Workflow", "ExpressionTool":
+			return p.parseProcess(data, base, cwlVersion)
+		default:
+			return nil, &CWLError{
+				Err:     ErrInvalidCWL,
+				Message: fmt.Sprintf("unsupported class for step run: %s", class),
+			}
+		}
+
+	default:
 		return nil, &CWLError{
-			Err:     err,
-			Message: "failed to parse JSON",
+			Err:     ErrInvalidCWL,
+			Message: "step run must be a file reference or an inline process",
 		}
 	}
-	return &tool, nil
+}
+
+// validateWorkflow validates a parsed Workflow
+func (p *Parser) validateWorkflow(wf *Workflow) error {
+	if wf.CWLVersion == "" {
+		return &CWLError{
+			Err:     ErrInvalidCWL,
+			Message: "cwlVersion is required",
+		}
+	}
+
+	if wf.Class != "Workflow" {
+		return &CWLError{
+			Err:     ErrInvalidCWL,
+			Message: "class must be 'Workflow'",
+		}
+	}
+
+	// Every source must name a workflow input or an output of a step
+	known := make(map[string]bool)
+	for inputID := range wf.Inputs {
+		known[inputID] = true
+	}
+	for stepID, step := range wf.Steps {
+		for _, outputID := range stepOutputIDs(step) {
+			known[stepID+"/"+outputID] = true
+		}
+	}
+
+	for stepID, step := range wf.Steps {
+		for inputID, in := range step.In {
+			for _, source := range sourceIDs(in.Source) {
+				if !known[source] {
+					return &CWLError{
+						Err:     ErrInvalidCWL,
+						Message: fmt.Sprintf("input %s of step %s has unknown source %s", inputID, stepID, source),
+					}
+				}
+			}
+			if !validLinkMerge(in.LinkMerge) {
+				return &CWLError{
+					Err:     ErrInvalidCWL,
+					Message: fmt.Sprintf("unsupported linkMerge %s on input %s of step %s", in.LinkMerge, inputID, stepID),
+				}
+			}
+			if !validPickValue(in.PickValue) {
+				return &CWLError{
+					Err:     ErrInvalidCWL,
+					Message: fmt.Sprintf("unsupported pickValue %s on input %s of step %s", in.PickValue, inputID, stepID),
+				}
+			}
+		}
+
+		for _, name := range scatterInputs(step) {
+			if _, ok := step.In[name]; !ok {
+				return &CWLError{
+					Err:     ErrInvalidCWL,
+					Message: fmt.Sprintf("step %s scatters over %s, which is not one of its inputs", stepID, name),
+				}
+			}
+		}
+
+		switch step.ScatterMethod {
+		case "", ScatterDotProduct, ScatterNestedCrossProduct, ScatterFlatCrossProduct:
+		default:
+			return &CWLError{
+				Err:     ErrInvalidCWL,
+				Message: fmt.Sprintf("unsupported scatterMethod %s on step %s", step.ScatterMethod, stepID),
+			}
+		}
+	}
+
+	for outputID, output := range wf.Outputs {
+		for _, source := range sourceIDs(output.OutputSource) {
+			if !known[source] {
+				return &CWLError{
+					Err:     ErrInvalidCWL,
+					Message: fmt.Sprintf("workflow output %s has unknown outputSource %s", outputID, source),
+				}
+			}
+		}
+		if !validLinkMerge(output.LinkMerge) {
+			return &CWLError{
+				Err:     ErrInvalidCWL,
+				Message: fmt.Sprintf("unsupported linkMerge %s on workflow output %s", output.LinkMerge, outputID),
+			}
+		}
+		if !validPickValue(output.PickValue) {
+			return &CWLError{
+				Err:     ErrInvalidCWL,
+				Message: fmt.Sprintf("unsupported pickValue %s on workflow output %s", output.PickValue, outputID),
+			}
+		}
+	}
+
+	if _, err := stepOrder(wf); err != nil {
+		return err
+	}
+
+	return nil
+}
+
+// validLinkMerge reports whether a linkMerge method is supported
+func validLinkMerge(method string) bool {
+	switch method {
+	case "", MergeNested, MergeFlattened:
+		return true
+	}
+	return false
+}
+
+// validPickValue reports whether a pickValue method is supported
+func validPickValue(method string) bool {
+	switch method {
+	case "", PickFirstNonNull, PickTheOnlyNonNull, PickAllNonNull:
+		return true
+	}
+	return false
 }
 
 // validateCommandLineTool validates a parsed CommandLineTool
@@ -123,16 +520,19 @@ func (p *Parser) validateCommandLineTool(tool *CommandLineTool) error {
 		}
 	}
 
-	// Validate baseCommand
-	if tool.BaseCommand == nil {
+	// Without baseCommand, the arguments and input bindings must build the
+	// command line
+	if tool.BaseCommand == nil && !hasCommandLineBindings(tool) {
 		return &CWLError{
 			Err:     ErrInvalidCWL,
-			Message: "baseCommand is required",
+			Message: "baseCommand is required when no arguments or input bindings build the command line",
 		}
 	}
 
 	// Check if baseCommand is a string or []string
 	switch cmd := tool.BaseCommand.(type) {
+	case nil:
+		// Valid: the command line is built from arguments and inputs
 	case string:
 		// Valid: baseCommand is a string
 	case []interface{}:
@@ -157,6 +557,20 @@ func (p *Parser) validateCommandLineTool(tool *CommandLineTool) error {
 	return nil
 }
 
+// hasCommandLineBindings reports whether a tool has arguments or inputs
+// bound to the command line
+func hasCommandLineBindings(tool *CommandLineTool) bool {
+	if len(tool.Arguments) > 0 {
+		return true
+	}
+	for _, input := range tool.Inputs {
+		if input.Binding != nil {
+			return true
+		}
+	}
+	return false
+}
+
 // ParseRequirement parses a requirement from a map
 func ParseRequirement(reqMap map[string]interface{}) (Requirement, error) {
 	class, ok := reqMap["class"].(string)
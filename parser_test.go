@@ -198,7 +198,7 @@ func TestValidateCommandLineTool(t *testing.T) {
 		t.Error("Expected error for invalid class, got nil")
 	}
 
-	// Missing baseCommand
+	// Missing baseCommand with nothing else to build the command line
 	tool = &CommandLineTool{
 		CWLVersion: "v1.2",
 		Class:      "CommandLineTool",
@@ -209,6 +209,18 @@ func TestValidateCommandLineTool(t *testing.T) {
 		t.Error("Expected error for missing baseCommand, got nil")
 	}
 
+	// Missing baseCommand with arguments building the command line
+	tool = &CommandLineTool{
+		CWLVersion: "v1.2",
+		Class:      "CommandLineTool",
+		Arguments:  []CommandLineBinding{{ValueFrom: "$(inputs.script.path)"}},
+	}
+
+	err = parser.validateCommandLineTool(tool)
+	if err != nil {
+		t.Errorf("Expected no error for tool built from arguments, got %v", err)
+	}
+
 	// Valid tool
 	tool = &CommandLineTool{
 		CWLVersion:  "v1.2",
@@ -221,3 +233,52 @@ func TestValidateCommandLineTool(t *testing.T) {
 		t.Errorf("Expected no error for valid tool, got %v", err)
 	}
 }
+
+func TestParseDefaultFiles(t *testing.T) {
+	dir := t.TempDir()
+	writeFiles(t, dir, map[string]string{
+		"tools/align.cwl": `cwlVersion: v1.2
+class: CommandLineTool
+baseCommand: cat
+inputs:
+  reference:
+    type: File
+    default: {class: File, path: data/ref.fa, secondaryFiles: [{class: File, location: data/ref.fa.fai}]}
+    inputBinding: {position: 1}
+outputs: {}
+`,
+		"workflow.cwl": `cwlVersion: v1.2
+class: Workflow
+inputs: {}
+outputs: {}
+steps:
+  align:
+    run: tools/align.cwl
+    in:
+      reference: {default: {class: File, location: genome/hg38.fa}}
+    out: []
+`,
+	})
+
+	process, err := NewParser().ParseProcessFile(filepath.Join(dir, "workflow.cwl"))
+	if err != nil {
+		t.Fatalf("Failed to parse workflow: %v", err)
+	}
+	wf := process.(*Workflow)
+
+	stepDefault := wf.Steps["align"].In["reference"].Default.(map[string]interface{})
+	if expected := "file://" + filepath.Join(dir, "genome/hg38.fa"); stepDefault["location"] != expected {
+		t.Errorf("Expected step default at %s, got %v", expected, stepDefault["location"])
+	}
+
+	// Defaults in a tool are relative to the tool, not the workflow
+	tool := wf.Steps["align"].Process.(*CommandLineTool)
+	toolDefault := tool.Inputs["reference"].Default.(map[string]interface{})
+	if expected := filepath.Join(dir, "tools/data/ref.fa"); toolDefault["path"] != expected {
+		t.Errorf("Expected tool default at %s, got %v", expected, toolDefault["path"])
+	}
+	secondary := toolDefault["secondaryFiles"].([]interface{})[0].(map[string]interface{})
+	if expected := "file://" + filepath.Join(dir, "tools/data/ref.fa.fai"); secondary["location"] != expected {
+		t.Errorf("Expected secondary file at %s, got %v", expected, secondary["location"])
+	}
+}
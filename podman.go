@@ -0,0 +1,53 @@
+package cwlgo
+
+import (
+	"context"
+	"os"
+)
+
+// PodmanRuntime runs tools with Podman, including rootless Podman on hosts
+// without a Docker daemon
+type PodmanRuntime struct {
+	// UserNS is the --userns mode used when running rootless. The default
+	// "keep-id" maps the invoking user into the container so that output
+	// files are owned by them. Empty disables the flag.
+	UserNS string
+}
+
+// Name implements the ContainerRuntime interface
+func (p *PodmanRuntime) Name() string {
+	return "podman"
+}
+
+// Available checks if Podman is available on the system
+func (p *PodmanRuntime) Available() error {
+	return checkCommandAvailable("podman")
+}
+
+// Prepare makes the tool's image available, loading, building, importing
+// or pulling it only when it is not already present
+func (p *PodmanRuntime) Prepare(ctx context.Context, execCtx *ExecutionContext) error {
+	return prepareDockerStyleImage(ctx, "podman", execCtx)
+}
+
+// WrapCommand builds a Podman command for executing a tool
+func (p *PodmanRuntime) WrapCommand(cmdArgs []string, execCtx *ExecutionContext) ([]string, error) {
+	var extraArgs []string
+
+	// Map the invoking user into the user namespace when rootless
+	if p.UserNS != "" && os.Geteuid() != 0 {
+		extraArgs = append(extraArgs, "--userns="+p.UserNS)
+	}
+
+	return buildDockerStyleCommand("podman", extraArgs, cmdArgs, execCtx), nil
+}
+
+// Cleanup kills and removes the container if it is still around
+func (p *PodmanRuntime) Cleanup(execCtx *ExecutionContext) {
+	cleanupDockerStyleContainer("podman", execCtx)
+}
+
+// RemoveOrphans removes containers left behind by crashed cwlgo processes
+func (p *PodmanRuntime) RemoveOrphans(ctx context.Context) error {
+	return removeOrphanedContainers(ctx, "podman")
+}
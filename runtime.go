@@ -0,0 +1,135 @@
+package cwlgo
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"os/exec"
+	"sort"
+	"strings"
+	"sync"
+)
+
+// ContainerRuntime describes how to run a command inside a container engine.
+// Process launch, I/O streaming and exit status handling are shared by all
+// runtimes, so an implementation only has to wrap the command line.
+type ContainerRuntime interface {
+	// Name returns the name used in ContainerConfig.Type, e.g. "docker"
+	Name() string
+
+	// Available returns an error if the runtime cannot be used on this host
+	Available() error
+
+	// WrapCommand returns the host command line that runs cmdArgs inside
+	// the container described by execCtx.Container
+	WrapCommand(cmdArgs []string, execCtx *ExecutionContext) ([]string, error)
+}
+
+// ContainerPreparer is implemented by runtimes that need to make the image
+// available (pull, load, build, ...) before the command is run
+type ContainerPreparer interface {
+	Prepare(ctx context.Context, execCtx *ExecutionContext) error
+}
+
+// ContainerCleaner is implemented by runtimes that leave resources behind,
+// such as containers, which must be removed after the command exits
+type ContainerCleaner interface {
+	Cleanup(execCtx *ExecutionContext)
+}
+
+// ContainerJanitor is implemented by runtimes that can remove containers
+// left behind by earlier runs that crashed before cleaning up
+type ContainerJanitor interface {
+	RemoveOrphans(ctx context.Context) error
+}
+
+var (
+	runtimesMu sync.RWMutex
+	runtimes   = make(map[string]ContainerRuntime)
+)
+
+func init() {
+	RegisterContainerRuntime(&DockerRuntime{})
+	RegisterContainerRuntime(&PodmanRuntime{UserNS: "keep-id"})
+	RegisterContainerRuntime(&SingularityRuntime{})
+}
+
+// RegisterContainerRuntime makes a container runtime available under its
+// name, replacing any runtime previously registered with that name
+func RegisterContainerRuntime(runtime ContainerRuntime) {
+	runtimesMu.Lock()
+	defer runtimesMu.Unlock()
+	runtimes[runtime.Name()] = runtime
+}
+
+// LookupContainerRuntime returns the container runtime registered under name
+func LookupContainerRuntime(name string) (ContainerRuntime, error) {
+	runtimesMu.RLock()
+	defer runtimesMu.RUnlock()
+
+	runtime, ok := runtimes[name]
+	if !ok {
+		return nil, &CWLError{
+			Err:     ErrExecution,
+			Message: fmt.Sprintf("unsupported container type: %s", name),
+		}
+	}
+	return runtime, nil
+}
+
+// ContainerRuntimes returns the names of all registered container runtimes
+func ContainerRuntimes() []string {
+	runtimesMu.RLock()
+	defer runtimesMu.RUnlock()
+
+	names := make([]string, 0, len(runtimes))
+	for name := range runtimes {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+// checkRuntimeAvailable checks if the named runtime is registered and usable
+func checkRuntimeAvailable(name string) error {
+	runtime, err := LookupContainerRuntime(name)
+	if err != nil {
+		return err
+	}
+	return runtime.Available()
+}
+
+// selectRuntime returns the first available runtime in preference order
+func selectRuntime(preference []string) (string, error) {
+	var errs []string
+	for _, name := range preference {
+		err := checkRuntimeAvailable(name)
+		if err == nil {
+			return name, nil
+		}
+		errs = append(errs, err.Error())
+	}
+	return "", fmt.Errorf("no container runtime available among %v: %s", preference, strings.Join(errs, "; "))
+}
+
+// checkCommandAvailable runs "<command> --version" to check that a container
+// engine's CLI is installed
+func checkCommandAvailable(command string) error {
+	cmd := exec.Command(command, "--version")
+	var stderr bytes.Buffer
+	cmd.Stderr = &stderr
+
+	if err := cmd.Run(); err != nil {
+		return fmt.Errorf("%s not available: %v - %s", command, err, stderr.String())
+	}
+
+	return nil
+}
+
+// containerImage returns the image reference to run
+func containerImage(config *ContainerConfig) string {
+	if config.ImageID != "" {
+		return config.ImageID
+	}
+	return config.Image
+}
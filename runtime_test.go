@@ -0,0 +1,301 @@
+package cwlgo
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"os/exec"
+	"strings"
+	"testing"
+)
+
+// wrapperRuntime is a test runtime that runs the command through env(1)
+type wrapperRuntime struct{}
+
+func (w *wrapperRuntime) Name() string     { return "test-wrapper" }
+func (w *wrapperRuntime) Available() error { return nil }
+func (w *wrapperRuntime) WrapCommand(cmdArgs []string, execCtx *ExecutionContext) ([]string, error) {
+	return append([]string{"env", "WRAPPED=yes"}, cmdArgs...), nil
+}
+
+func TestRegisterContainerRuntime(t *testing.T) {
+	RegisterContainerRuntime(&wrapperRuntime{})
+
+	if _, err := LookupContainerRuntime("test-wrapper"); err != nil {
+		t.Fatalf("Expected registered runtime to be found: %v", err)
+	}
+
+	if _, err := LookupContainerRuntime("no-such-runtime"); err == nil {
+		t.Error("Expected error for unknown runtime, got nil")
+	}
+
+	tool := &CommandLineTool{
+		CWLVersion:  "v1.2",
+		Class:       "CommandLineTool",
+		BaseCommand: "printenv",
+	}
+
+	executor := NewExecutor()
+
+	execCtx, err := NewExecutionContext("")
+	if err != nil {
+		t.Fatalf("Failed to create execution context: %v", err)
+	}
+	defer execCtx.Cleanup()
+
+	execCtx.Container = &ContainerConfig{Type: "test-wrapper"}
+
+	result, err := executor.runCommand(context.Background(), tool, []string{"printenv", "WRAPPED"}, execCtx)
+	if err != nil {
+		t.Fatalf("Failed to run command: %v", err)
+	}
+	defer os.Remove(result.StdoutPath)
+	defer os.Remove(result.StderrPath)
+
+	if strings.TrimSpace(result.Stdout) != "yes" {
+		t.Errorf("Expected command to run through the wrapper runtime, got %q", result.Stdout)
+	}
+}
+
+func TestDockerWrapCommand(t *testing.T) {
+	execCtx := &ExecutionContext{
+		WorkingDir: "/work",
+		OutputDir:  "/work/output",
+		Container: &ContainerConfig{
+			Type:  "docker",
+			Image: "ubuntu:20.04",
+		},
+	}
+
+	cmd, err := (&DockerRuntime{}).WrapCommand([]string{"echo", "hello"}, execCtx)
+	if err != nil {
+		t.Fatalf("Failed to wrap command: %v", err)
+	}
+
+	joined := strings.Join(cmd, " ")
+	if !strings.HasPrefix(joined, "docker run --rm") {
+		t.Errorf("Expected docker run command, got %s", joined)
+	}
+	if !strings.HasSuffix(joined, "ubuntu:20.04 echo hello") {
+		t.Errorf("Expected image followed by the tool command, got %s", joined)
+	}
+}
+
+// unavailableRuntime is a test runtime that is never available
+type unavailableRuntime struct{ wrapperRuntime }
+
+func (u *unavailableRuntime) Name() string     { return "test-unavailable" }
+func (u *unavailableRuntime) Available() error { return os.ErrNotExist }
+
+func TestSelectRuntime(t *testing.T) {
+	RegisterContainerRuntime(&wrapperRuntime{})
+	RegisterContainerRuntime(&unavailableRuntime{})
+
+	name, err := selectRuntime([]string{"test-unavailable", "test-wrapper"})
+	if err != nil {
+		t.Fatalf("Failed to select runtime: %v", err)
+	}
+	if name != "test-wrapper" {
+		t.Errorf("Expected first available runtime test-wrapper, got %s", name)
+	}
+
+	if _, err := selectRuntime([]string{"test-unavailable"}); err == nil {
+		t.Error("Expected error when no runtime is available, got nil")
+	}
+}
+
+func TestPodmanWrapCommand(t *testing.T) {
+	execCtx := &ExecutionContext{
+		WorkingDir: "/work",
+		OutputDir:  "/work/output",
+		Container: &ContainerConfig{
+			Type:  "podman",
+			Image: "ubuntu:20.04",
+		},
+	}
+
+	cmd, err := (&PodmanRuntime{UserNS: "keep-id"}).WrapCommand([]string{"echo", "hello"}, execCtx)
+	if err != nil {
+		t.Fatalf("Failed to wrap command: %v", err)
+	}
+
+	joined := strings.Join(cmd, " ")
+	if !strings.HasPrefix(joined, "podman run --rm") {
+		t.Errorf("Expected podman run command, got %s", joined)
+	}
+
+	rootless := os.Geteuid() != 0
+	if strings.Contains(joined, "--userns=keep-id") != rootless {
+		t.Errorf("Expected --userns=keep-id only when rootless (rootless=%v), got %s", rootless, joined)
+	}
+}
+
+func TestNetworkAccess(t *testing.T) {
+	tests := []struct {
+		name         string
+		requirements []map[string]interface{}
+		hints        []map[string]interface{}
+		expected     bool
+		expectError  bool
+	}{
+		{
+			name:     "No requirement",
+			expected: false,
+		},
+		{
+			name: "Enabled",
+			requirements: []map[string]interface{}{
+				{"class": "NetworkAccess", "networkAccess": true},
+			},
+			expected: true,
+		},
+		{
+			name: "Expression",
+			requirements: []map[string]interface{}{
+				{"class": "NetworkAccess", "networkAccess": "$(inputs.online)"},
+			},
+			expected: true,
+		},
+		{
+			name: "Hint",
+			hints: []map[string]interface{}{
+				{"class": "NetworkAccess", "networkAccess": true},
+			},
+			expected: true,
+		},
+		{
+			name: "Requirement overrides hint",
+			requirements: []map[string]interface{}{
+				{"class": "NetworkAccess", "networkAccess": false},
+			},
+			hints: []map[string]interface{}{
+				{"class": "NetworkAccess", "networkAccess": true},
+			},
+			expected: false,
+		},
+		{
+			name: "Not a boolean",
+			requirements: []map[string]interface{}{
+				{"class": "NetworkAccess", "networkAccess": "yes"},
+			},
+			expectError: true,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			tool := &CommandLineTool{
+				CWLVersion:   "v1.2",
+				Class:        "CommandLineTool",
+				BaseCommand:  "curl",
+				Requirements: tt.requirements,
+				Hints:        tt.hints,
+				Inputs: map[string]CommandInputParameter{
+					"online": {Type: "boolean", Default: true},
+				},
+			}
+
+			execCtx := &ExecutionContext{
+				WorkingDir:      "/work",
+				OutputDir:       "/work/output",
+				EnvironmentVars: map[string]string{},
+			}
+
+			err := NewExecutor().processRequirements(tool, execCtx)
+			if tt.expectError {
+				if err == nil {
+					t.Error("Expected error, got nil")
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("Failed to process requirements: %v", err)
+			}
+			if execCtx.NetworkAccess != tt.expected {
+				t.Errorf("Expected NetworkAccess %v, got %v", tt.expected, execCtx.NetworkAccess)
+			}
+
+			execCtx.Container = &ContainerConfig{Type: "docker", Image: "ubuntu:20.04"}
+
+			docker := strings.Join(buildDockerStyleCommand("docker", nil, []string{"true"}, execCtx), " ")
+			if strings.Contains(docker, "--network none") == tt.expected {
+				t.Errorf("Expected --network none only without network access, got %s", docker)
+			}
+
+			// Singularity isolates only when the executor enables it
+			singularity, err := (&SingularityRuntime{Command: "singularity"}).WrapCommand([]string{"true"}, execCtx)
+			if err != nil {
+				t.Fatalf("Failed to wrap command: %v", err)
+			}
+			if strings.Contains(strings.Join(singularity, " "), "--net") {
+				t.Errorf("Expected no --net without SingularityNetworkIsolation, got %v", singularity)
+			}
+
+			execCtx.Container.NetworkIsolation = true
+			singularity, err = (&SingularityRuntime{Command: "singularity"}).WrapCommand([]string{"true"}, execCtx)
+			if err != nil {
+				t.Fatalf("Failed to wrap command: %v", err)
+			}
+			if strings.Contains(strings.Join(singularity, " "), "--net --network none") == tt.expected {
+				t.Errorf("Expected --net --network none only without network access, got %v", singularity)
+			}
+		})
+	}
+}
+
+func TestContainerCleanup(t *testing.T) {
+	engine, logFile := setupFakeEngine(t)
+
+	execCtx := &ExecutionContext{
+		WorkingDir: "/work",
+		OutputDir:  "/work/output",
+		Container:  &ContainerConfig{Type: "docker", Image: "ubuntu:20.04"},
+	}
+
+	// Containers are started with a generated name
+	cmd := buildDockerStyleCommand(engine, nil, []string{"true"}, execCtx)
+	name := execCtx.Container.Name
+	if !strings.HasPrefix(name, containerNamePrefix) {
+		t.Fatalf("Expected generated container name, got %q", name)
+	}
+	if !strings.Contains(strings.Join(cmd, " "), "--name "+name) {
+		t.Errorf("Expected --name %s in command, got %v", name, cmd)
+	}
+	if pid, ok := containerOwner(name); !ok || pid != os.Getpid() {
+		t.Errorf("Expected container owner %d, got %d", os.Getpid(), pid)
+	}
+
+	// Cleanup kills and removes the container by name
+	cleanupDockerStyleContainer(engine, execCtx)
+
+	log := readEngineLog(t, logFile)
+	if !strings.Contains(log, "kill "+name) || !strings.Contains(log, "rm -f "+name) {
+		t.Errorf("Expected kill and rm of %s, got %q", name, log)
+	}
+}
+
+func TestRemoveOrphanedContainers(t *testing.T) {
+	engine, logFile := setupFakeEngine(t)
+
+	// A pid of a process that has exited
+	exited := exec.Command("true")
+	if err := exited.Run(); err != nil {
+		t.Fatalf("Failed to run process: %v", err)
+	}
+	orphan := fmt.Sprintf("cwlgo-%d-0123456789abcdef", exited.Process.Pid)
+	running := newContainerName()
+
+	t.Setenv("FAKE_ENGINE_PS", orphan+" "+running+" cwlgo-unrelated")
+
+	if err := removeOrphanedContainers(context.Background(), engine); err != nil {
+		t.Fatalf("Failed to remove orphans: %v", err)
+	}
+
+	log := readEngineLog(t, logFile)
+	if !strings.Contains(log, "rm -f "+orphan+"\n") {
+		t.Errorf("Expected orphan %s to be removed, got %q", orphan, log)
+	}
+	if strings.Contains(log, running) || strings.Contains(log, "cwlgo-unrelated") {
+		t.Errorf("Expected only the orphan to be removed, got %q", log)
+	}
+}
@@ -0,0 +1,213 @@
+package cwlgo
+
+import (
+	"context"
+	"fmt"
+	"path/filepath"
+	"sync"
+)
+
+// Scatter methods
+const (
+	ScatterDotProduct         = "dotproduct"
+	ScatterNestedCrossProduct = "nested_crossproduct"
+	ScatterFlatCrossProduct   = "flat_crossproduct"
+)
+
+// scatterInputs returns the input IDs a step scatters over
+func scatterInputs(step WorkflowStep) []string {
+	return sourceIDs(step.Scatter)
+}
+
+// runScatter runs one job per combination of a step's scattered inputs in
+// parallel on at most MaxCores goroutines, as the executor's scheduler
+// allows, and gathers each output into an array shaped by the scatter method
+func (r *workflowRun) runScatter(ctx context.Context, wf *Workflow, stepID string, step WorkflowStep, inputs map[string]interface{}, dir string) (map[string]interface{}, error) {
+	jobs, dims, err := scatterJobs(stepID, step, inputs)
+	if err != nil {
+		return nil, err
+	}
+
+	results := make([]map[string]interface{}, len(jobs))
+
+	ctx, cancel := context.WithCancel(ctx)
+	defer cancel()
+
+	// Each job takes at least a core, so no more than MaxCores workers are
+	// needed to keep the scheduler busy
+	workers := r.executor.MaxCores
+	if workers < 1 {
+		workers = 1
+	}
+	if workers > len(jobs) {
+		workers = len(jobs)
+	}
+
+	var (
+		wg       sync.WaitGroup
+		mu       sync.Mutex
+		firstErr error
+	)
+	indices := make(chan int)
+	for w := 0; w < workers; w++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for i := range indices {
+				jobDir := filepath.Join(dir, fmt.Sprintf("%s_%d", stepID, i))
+				outputs, err := r.runJob(ctx, wf, step, jobs[i], jobDir)
+
+				mu.Lock()
+				if err != nil {
+					// The first failure cancels the remaining jobs
+					if firstErr == nil {
+						firstErr = err
+						cancel()
+					}
+				} else {
+					results[i] = outputs
+				}
+				mu.Unlock()
+			}
+		}()
+	}
+feed:
+	for i := range jobs {
+		select {
+		case indices <- i:
+		case <-ctx.Done():
+			break feed
+		}
+	}
+	close(indices)
+	wg.Wait()
+
+	if firstErr != nil {
+		return nil, firstErr
+	}
+	if err := ctx.Err(); err != nil {
+		return nil, &CWLError{Err: err, Message: fmt.Sprintf("scatter of step %s was cancelled", stepID)}
+	}
+
+	outputs := make(map[string]interface{})
+	for _, outputID := range stepOutputIDs(step) {
+		flat := make([]interface{}, len(results))
+		for i, result := range results {
+			flat[i] = result[outputID]
+		}
+		outputs[outputID] = reshape(flat, dims)
+	}
+
+	return outputs, nil
+}
+
+// scatterJobs returns the inputs of each job of a scattered step, in
+// row-major order, and the dimensions of the step's output arrays
+func scatterJobs(stepID string, step WorkflowStep, inputs map[string]interface{}) ([]map[string]interface{}, []int, error) {
+	names := scatterInputs(step)
+	arrays := make([][]interface{}, len(names))
+	for i, name := range names {
+		array, ok := inputs[name].([]interface{})
+		if !ok {
+			return nil, nil, &CWLError{
+				Err:     ErrExecution,
+				Message: fmt.Sprintf("scatter input %s of step %s must be an array, got %T", name, stepID, inputs[name]),
+			}
+		}
+		arrays[i] = array
+	}
+
+	method := step.ScatterMethod
+	if method == "" {
+		method = ScatterDotProduct
+	}
+
+	var combinations [][]interface{}
+	var dims []int
+
+	switch method {
+	case ScatterDotProduct:
+		n := len(arrays[0])
+		for i, array := range arrays {
+			if len(array) != n {
+				return nil, nil, &CWLError{
+					Err:     ErrExecution,
+					Message: fmt.Sprintf("dotproduct scatter of step %s needs arrays of equal length: %s has %d items, %s has %d", stepID, names[0], n, names[i], len(array)),
+				}
+			}
+		}
+		for i := 0; i < n; i++ {
+			combination := make([]interface{}, len(arrays))
+			for j, array := range arrays {
+				combination[j] = array[i]
+			}
+			combinations = append(combinations, combination)
+		}
+		dims = []int{n}
+
+	case ScatterNestedCrossProduct, ScatterFlatCrossProduct:
+		combinations = crossProduct(arrays)
+		if method == ScatterNestedCrossProduct {
+			for _, array := range arrays {
+				dims = append(dims, len(array))
+			}
+		} else {
+			dims = []int{len(combinations)}
+		}
+
+	default:
+		return nil, nil, &CWLError{
+			Err:     ErrInvalidCWL,
+			Message: fmt.Sprintf("unsupported scatterMethod %s on step %s", method, stepID),
+		}
+	}
+
+	jobs := make([]map[string]interface{}, len(combinations))
+	for i, combination := range combinations {
+		job := make(map[string]interface{}, len(inputs))
+		for k, v := range inputs {
+			job[k] = v
+		}
+		for j, name := range names {
+			job[name] = combination[j]
+		}
+		jobs[i] = job
+	}
+
+	return jobs, dims, nil
+}
+
+// crossProduct returns every combination of one item from each array, with
+// the first array varying slowest
+func crossProduct(arrays [][]interface{}) [][]interface{} {
+	combinations := [][]interface{}{{}}
+	for _, array := range arrays {
+		var next [][]interface{}
+		for _, prefix := range combinations {
+			for _, item := range array {
+				combination := append(append([]interface{}{}, prefix...), item)
+				next = append(next, combination)
+			}
+		}
+		combinations = next
+	}
+	return combinations
+}
+
+// reshape nests a flat row-major list into arrays of the given dimensions
+func reshape(flat []interface{}, dims []int) []interface{} {
+	if len(dims) <= 1 {
+		return flat
+	}
+
+	size := 1
+	for _, d := range dims[1:] {
+		size *= d
+	}
+
+	nested := make([]interface{}, dims[0])
+	for i := range nested {
+		nested[i] = reshape(flat[i*size:(i+1)*size], dims[1:])
+	}
+	return nested
+}
@@ -0,0 +1,252 @@
+package cwlgo
+
+import (
+	"context"
+	"fmt"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+)
+
+// JobRequest describes the resources a job needs before it may start
+type JobRequest struct {
+	Name     string
+	Cores    int
+	RAM      int64 // in MiB
+	Priority int   // Higher priorities are started first
+}
+
+// JobInfo describes a job waiting for or holding resources
+type JobInfo struct {
+	JobRequest
+	Since time.Time // When the job was queued or started
+}
+
+// QueueState is a snapshot of the scheduler's resources and jobs
+type QueueState struct {
+	TotalCores int
+	UsedCores  int
+	TotalRAM   int64
+	UsedRAM    int64
+	Running    []JobInfo
+	Waiting    []JobInfo // In the order they will be considered
+}
+
+// maxBypass is how many later jobs may start ahead of the first waiting job
+// while it does not fit, before they must wait for it
+const maxBypass = 8
+
+// Scheduler starts jobs only when their cores and RAM are free, so that the
+// jobs running at once never exceed its totals. Waiting jobs are considered
+// by priority, then in arrival order, and a job that fits may start ahead
+// of a larger one, at most maxBypass times, so that a steady stream of
+// small jobs cannot starve it.
+type Scheduler struct {
+	totalCores int
+	totalRAM   int64
+
+	mu        sync.Mutex
+	usedCores int
+	usedRAM   int64
+	seq       int
+	waiting   []*queuedJob
+	running   map[*queuedJob]bool
+}
+
+// queuedJob is a job known to the scheduler
+type queuedJob struct {
+	JobInfo
+	seq      int
+	started  bool
+	bypassed int // Jobs started ahead of this one while it did not fit
+	ready    chan struct{}
+}
+
+// NewScheduler creates a scheduler for the given number of cores and MiB
+// of RAM
+func NewScheduler(cores int, ram int64) *Scheduler {
+	return &Scheduler{
+		totalCores: cores,
+		totalRAM:   ram,
+		running:    make(map[*queuedJob]bool),
+	}
+}
+
+// Acquire waits until the resources of req are free and reserves them. The
+// returned function releases them and must be called when the job is done.
+func (s *Scheduler) Acquire(ctx context.Context, req JobRequest) (func(), error) {
+	if req.Cores < 1 {
+		req.Cores = 1
+	}
+	if req.RAM < 0 {
+		req.RAM = 0
+	}
+	if req.Cores > s.totalCores || req.RAM > s.totalRAM {
+		return nil, &CWLError{
+			Err: ErrExecution,
+			Message: fmt.Sprintf("job %s needs %d cores and %d MiB RAM, more than the %d cores and %d MiB available",
+				req.Name, req.Cores, req.RAM, s.totalCores, s.totalRAM),
+		}
+	}
+
+	s.mu.Lock()
+	s.seq++
+	job := &queuedJob{
+		JobInfo: JobInfo{JobRequest: req, Since: time.Now()},
+		seq:     s.seq,
+		ready:   make(chan struct{}),
+	}
+	s.waiting = append(s.waiting, job)
+	sort.SliceStable(s.waiting, func(i, j int) bool {
+		if s.waiting[i].Priority != s.waiting[j].Priority {
+			return s.waiting[i].Priority > s.waiting[j].Priority
+		}
+		return s.waiting[i].seq < s.waiting[j].seq
+	})
+	s.dispatch()
+	s.mu.Unlock()
+
+	select {
+	case <-job.ready:
+		return s.releaser(job), nil
+	case <-ctx.Done():
+		s.mu.Lock()
+		if job.started {
+			// Started just as the context was cancelled
+			s.mu.Unlock()
+			s.releaser(job)()
+		} else {
+			// Jobs held back by this one may fit now
+			s.remove(job)
+			s.dispatch()
+			s.mu.Unlock()
+		}
+		return nil, &CWLError{Err: ctx.Err(), Message: fmt.Sprintf("job %s was cancelled while waiting for resources", req.Name)}
+	}
+}
+
+// releaser returns a function that frees a running job's resources once
+func (s *Scheduler) releaser(job *queuedJob) func() {
+	var once sync.Once
+	return func() {
+		once.Do(func() {
+			s.mu.Lock()
+			defer s.mu.Unlock()
+			delete(s.running, job)
+			s.usedCores -= job.Cores
+			s.usedRAM -= job.RAM
+			s.dispatch()
+		})
+	}
+}
+
+// dispatch starts the waiting jobs that fit in the free resources, in
+// order. Once the first job that does not fit has been bypassed maxBypass
+// times, the resources are reserved for it and no later job starts. The
+// caller must hold s.mu.
+func (s *Scheduler) dispatch() {
+	var blocked *queuedJob
+	waiting := s.waiting[:0]
+	for _, job := range s.waiting {
+		fits := s.usedCores+job.Cores <= s.totalCores && s.usedRAM+job.RAM <= s.totalRAM
+		if fits && (blocked == nil || blocked.bypassed < maxBypass) {
+			if blocked != nil {
+				blocked.bypassed++
+			}
+			s.usedCores += job.Cores
+			s.usedRAM += job.RAM
+			job.started = true
+			job.Since = time.Now()
+			s.running[job] = true
+			close(job.ready)
+			continue
+		}
+		if blocked == nil && !fits {
+			blocked = job
+		}
+		waiting = append(waiting, job)
+	}
+	s.waiting = waiting
+}
+
+// remove drops a job from the waiting list. The caller must hold s.mu.
+func (s *Scheduler) remove(job *queuedJob) {
+	for i, waiting := range s.waiting {
+		if waiting == job {
+			s.waiting = append(s.waiting[:i], s.waiting[i+1:]...)
+			return
+		}
+	}
+}
+
+// State returns a snapshot of the scheduler's resources and jobs
+func (s *Scheduler) State() QueueState {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	state := QueueState{
+		TotalCores: s.totalCores,
+		UsedCores:  s.usedCores,
+		TotalRAM:   s.totalRAM,
+		UsedRAM:    s.usedRAM,
+	}
+	for _, job := range s.waiting {
+		state.Waiting = append(state.Waiting, job.JobInfo)
+	}
+	for job := range s.running {
+		state.Running = append(state.Running, job.JobInfo)
+	}
+	sort.Slice(state.Running, func(i, j int) bool {
+		return state.Running[i].Since.Before(state.Running[j].Since)
+	})
+	return state
+}
+
+// Scheduler returns the scheduler that workflow jobs of this executor share,
+// created from MaxCores and MaxRAM on first use
+func (e *Executor) Scheduler() *Scheduler {
+	e.schedulerMu.Lock()
+	defer e.schedulerMu.Unlock()
+
+	if e.scheduler == nil {
+		e.scheduler = NewScheduler(e.MaxCores, e.MaxRAM)
+	}
+	return e.scheduler
+}
+
+// jobRequest returns the resources a tool needs from its
+// ResourceRequirement, with the same defaults as NewExecutionContext
+func jobRequest(name string, tool *CommandLineTool, priority int) JobRequest {
+	req := JobRequest{Name: name, Cores: 1, RAM: 1024, Priority: priority}
+	for _, reqMap := range tool.Requirements {
+		if reqMap["class"] != "ResourceRequirement" {
+			continue
+		}
+		if coresMin := reqMap["coresMin"]; isNumber(coresMin) {
+			req.Cores = int(toNumber(coresMin))
+		}
+		if ramMin := reqMap["ramMin"]; isNumber(ramMin) {
+			req.RAM = int64(toNumber(ramMin))
+		}
+	}
+	return req
+}
+
+// stepPriority returns the priority from a step's Priority hint, e.g.
+// {class: cwlgo:Priority, priority: 10}, or zero
+func stepPriority(step WorkflowStep) int {
+	for _, hint := range step.Hints {
+		class, _ := hint["class"].(string)
+		if i := strings.LastIndexAny(class, ":#/"); i >= 0 {
+			class = class[i+1:]
+		}
+		if class != "Priority" {
+			continue
+		}
+		if priority := hint["priority"]; isNumber(priority) {
+			return int(toNumber(priority))
+		}
+	}
+	return 0
+}
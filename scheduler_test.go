@@ -0,0 +1,287 @@
+package cwlgo
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sync"
+	"testing"
+	"time"
+)
+
+func TestSchedulerNeverOvercommits(t *testing.T) {
+	scheduler := NewScheduler(4, 4096)
+
+	var (
+		wg      sync.WaitGroup
+		mu      sync.Mutex
+		running int
+		maxSeen int
+	)
+	for i := 0; i < 8; i++ {
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+			release, err := scheduler.Acquire(context.Background(), JobRequest{Name: fmt.Sprintf("job%d", i), Cores: 1 + i%2, RAM: 1024})
+			if err != nil {
+				t.Errorf("Failed to acquire resources: %v", err)
+				return
+			}
+			defer release()
+
+			state := scheduler.State()
+			if state.UsedCores > state.TotalCores || state.UsedRAM > state.TotalRAM {
+				t.Errorf("Expected resources within totals, got %d/%d cores and %d/%d MiB",
+					state.UsedCores, state.TotalCores, state.UsedRAM, state.TotalRAM)
+			}
+
+			mu.Lock()
+			running++
+			if running > maxSeen {
+				maxSeen = running
+			}
+			mu.Unlock()
+
+			time.Sleep(10 * time.Millisecond)
+
+			mu.Lock()
+			running--
+			mu.Unlock()
+		}(i)
+	}
+	wg.Wait()
+
+	if maxSeen > 4 {
+		t.Errorf("Expected at most 4 jobs at once, got %d", maxSeen)
+	}
+	if state := scheduler.State(); state.UsedCores != 0 || len(state.Running) != 0 {
+		t.Errorf("Expected all resources released, got %+v", state)
+	}
+}
+
+func TestSchedulerPriority(t *testing.T) {
+	scheduler := NewScheduler(1, 1024)
+
+	release, err := scheduler.Acquire(context.Background(), JobRequest{Name: "first"})
+	if err != nil {
+		t.Fatalf("Failed to acquire resources: %v", err)
+	}
+
+	var (
+		wg    sync.WaitGroup
+		mu    sync.Mutex
+		order []string
+	)
+	for i, req := range []JobRequest{
+		{Name: "low", Priority: 0},
+		{Name: "high", Priority: 10},
+	} {
+		wg.Add(1)
+		go func(req JobRequest) {
+			defer wg.Done()
+			release, err := scheduler.Acquire(context.Background(), req)
+			if err != nil {
+				t.Errorf("Failed to acquire resources: %v", err)
+				return
+			}
+			mu.Lock()
+			order = append(order, req.Name)
+			mu.Unlock()
+			release()
+		}(req)
+
+		// Queue the jobs one after the other
+		waitForWaiting(t, scheduler, i+1)
+	}
+
+	state := scheduler.State()
+	if len(state.Running) != 1 || state.Running[0].Name != "first" {
+		t.Errorf("Expected first to be running, got %+v", state.Running)
+	}
+	if len(state.Waiting) != 2 || state.Waiting[0].Name != "high" {
+		t.Errorf("Expected high to be considered first, got %+v", state.Waiting)
+	}
+
+	release()
+	wg.Wait()
+
+	if len(order) != 2 || order[0] != "high" || order[1] != "low" {
+		t.Errorf("Expected high before low, got %v", order)
+	}
+}
+
+func TestSchedulerBypassLimit(t *testing.T) {
+	scheduler := NewScheduler(16, 16*1024)
+
+	first, err := scheduler.Acquire(context.Background(), JobRequest{Name: "first", Cores: 1, RAM: 1024})
+	if err != nil {
+		t.Fatalf("Failed to acquire resources: %v", err)
+	}
+
+	var (
+		wg       sync.WaitGroup
+		mu       sync.Mutex
+		order    []string
+		releases = make(chan func(), 32)
+	)
+	acquire := func(req JobRequest) {
+		defer wg.Done()
+		release, err := scheduler.Acquire(context.Background(), req)
+		if err != nil {
+			t.Errorf("Failed to acquire resources: %v", err)
+			return
+		}
+		mu.Lock()
+		order = append(order, req.Name)
+		mu.Unlock()
+		releases <- release
+	}
+
+	// A large job waits for all cores while small jobs keep arriving
+	wg.Add(1)
+	go acquire(JobRequest{Name: "large", Cores: 16, RAM: 1024, Priority: 10})
+	waitForWaiting(t, scheduler, 1)
+
+	for i := 0; i < maxBypass+4; i++ {
+		wg.Add(1)
+		go acquire(JobRequest{Name: fmt.Sprintf("small%d", i), Cores: 1, RAM: 1024})
+	}
+	waitForWaiting(t, scheduler, 5)
+
+	state := scheduler.State()
+	if len(state.Running) != maxBypass+1 {
+		t.Errorf("Expected %d small jobs to bypass the large one, got %+v", maxBypass, state.Running)
+	}
+	if state.Waiting[0].Name != "large" {
+		t.Errorf("Expected the large job first in line, got %+v", state.Waiting)
+	}
+
+	// Once the bypassing jobs finish, the large job starts before the rest
+	first()
+	for i := 0; i < maxBypass; i++ {
+		(<-releases)()
+	}
+	(<-releases)()
+	mu.Lock()
+	if len(order) != maxBypass+1 || order[maxBypass] != "large" {
+		t.Errorf("Expected the large job to start after %d small jobs, got %v", maxBypass, order)
+	}
+	mu.Unlock()
+
+	for i := 0; i < 4; i++ {
+		(<-releases)()
+	}
+	wg.Wait()
+}
+
+func TestSchedulerCancelBlockedJob(t *testing.T) {
+	scheduler := NewScheduler(maxBypass+2, 64*1024)
+
+	first, err := scheduler.Acquire(context.Background(), JobRequest{Name: "first", Cores: 1, RAM: 1024})
+	if err != nil {
+		t.Fatalf("Failed to acquire resources: %v", err)
+	}
+	defer first()
+
+	// A large job waits for all cores and small jobs use up its bypasses
+	ctx, cancel := context.WithCancel(context.Background())
+	errs := make(chan error, 1)
+	go func() {
+		_, err := scheduler.Acquire(ctx, JobRequest{Name: "large", Cores: maxBypass + 2, RAM: 1024})
+		errs <- err
+	}()
+	waitForWaiting(t, scheduler, 1)
+
+	releases := make(chan func(), maxBypass+1)
+	for i := 0; i < maxBypass+1; i++ {
+		go func(i int) {
+			release, err := scheduler.Acquire(context.Background(), JobRequest{Name: fmt.Sprintf("small%d", i), Cores: 1, RAM: 1024})
+			if err != nil {
+				t.Errorf("Failed to acquire resources: %v", err)
+				return
+			}
+			releases <- release
+		}(i)
+	}
+	waitForWaiting(t, scheduler, 2)
+	for i := 0; i < maxBypass; i++ {
+		defer (<-releases)()
+	}
+
+	// Cancelling the large job starts the small job held back behind it
+	cancel()
+	if err := <-errs; !errors.Is(err, context.Canceled) {
+		t.Errorf("Expected context.Canceled, got %v", err)
+	}
+	select {
+	case release := <-releases:
+		release()
+	case <-time.After(5 * time.Second):
+		t.Fatalf("Expected the held back job to start, got %+v", scheduler.State())
+	}
+}
+
+func TestSchedulerErrors(t *testing.T) {
+	scheduler := NewScheduler(2, 2048)
+
+	if _, err := scheduler.Acquire(context.Background(), JobRequest{Name: "big", Cores: 3}); err == nil {
+		t.Error("Expected error for job larger than the scheduler, got nil")
+	}
+
+	release, err := scheduler.Acquire(context.Background(), JobRequest{Name: "running", Cores: 2})
+	if err != nil {
+		t.Fatalf("Failed to acquire resources: %v", err)
+	}
+	defer release()
+
+	// A cancelled job leaves the queue
+	ctx, cancel := context.WithCancel(context.Background())
+	errs := make(chan error, 1)
+	go func() {
+		_, err := scheduler.Acquire(ctx, JobRequest{Name: "waiting"})
+		errs <- err
+	}()
+	waitForWaiting(t, scheduler, 1)
+	cancel()
+
+	if err := <-errs; !errors.Is(err, context.Canceled) {
+		t.Errorf("Expected context.Canceled, got %v", err)
+	}
+	if state := scheduler.State(); len(state.Waiting) != 0 {
+		t.Errorf("Expected no waiting jobs, got %+v", state.Waiting)
+	}
+}
+
+func TestJobRequest(t *testing.T) {
+	tool := &CommandLineTool{
+		Requirements: []map[string]interface{}{
+			{"class": "ResourceRequirement", "coresMin": 2, "ramMin": float64(512)},
+		},
+	}
+	step := WorkflowStep{
+		Hints: []map[string]interface{}{
+			{"class": "cwlgo:Priority", "priority": 5},
+		},
+	}
+
+	req := jobRequest("job", tool, stepPriority(step))
+	if req.Cores != 2 || req.RAM != 512 || req.Priority != 5 {
+		t.Errorf("Expected 2 cores, 512 MiB and priority 5, got %+v", req)
+	}
+
+	req = jobRequest("job", &CommandLineTool{}, stepPriority(WorkflowStep{}))
+	if req.Cores != 1 || req.RAM != 1024 || req.Priority != 0 {
+		t.Errorf("Expected defaults of 1 core, 1024 MiB and priority 0, got %+v", req)
+	}
+}
+
+// waitForWaiting waits until the scheduler has n waiting jobs
+func waitForWaiting(t *testing.T, scheduler *Scheduler, n int) {
+	deadline := time.Now().Add(5 * time.Second)
+	for len(scheduler.State().Waiting) < n {
+		if time.Now().After(deadline) {
+			t.Fatalf("Expected %d waiting jobs, got %+v", n, scheduler.State().Waiting)
+		}
+		time.Sleep(time.Millisecond)
+	}
+}
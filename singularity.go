@@ -0,0 +1,260 @@
+package cwlgo
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+)
+
+// SingularityRuntime runs tools with Singularity or Apptainer
+type SingularityRuntime struct {
+	// Command is the CLI to use. Empty selects singularity if installed,
+	// otherwise apptainer.
+	Command string
+
+	// CacheDir is where converted SIF images are kept. Empty uses
+	// $CWLGO_SIF_CACHE, or cwlgo/sif under the user cache directory.
+	CacheDir string
+}
+
+// Name implements the ContainerRuntime interface
+func (s *SingularityRuntime) Name() string {
+	return "singularity"
+}
+
+// Available checks if Singularity/Apptainer is available on the system
+func (s *SingularityRuntime) Available() error {
+	if s.Command != "" {
+		return checkCommandAvailable(s.Command)
+	}
+
+	// Try singularity first
+	if err := checkCommandAvailable("singularity"); err == nil {
+		return nil
+	}
+
+	// If singularity fails, try apptainer
+	if err := checkCommandAvailable("apptainer"); err != nil {
+		return fmt.Errorf("neither singularity nor apptainer available: %v", err)
+	}
+
+	return nil
+}
+
+// command returns the CLI to use, preferring singularity over apptainer
+func (s *SingularityRuntime) command() string {
+	if s.Command != "" {
+		return s.Command
+	}
+	if _, err := exec.LookPath("singularity"); err != nil {
+		return "apptainer"
+	}
+	return "singularity"
+}
+
+// WrapCommand builds a Singularity command for executing a tool
+func (s *SingularityRuntime) WrapCommand(cmdArgs []string, execCtx *ExecutionContext) ([]string, error) {
+	containerCmd := []string{s.command(), "exec"}
+
+	// Add bind mounts
+	outputDir := containerOutputDir(execCtx.Container)
+	bindMounts := []string{
+		fmt.Sprintf("%s:%s", execCtx.WorkingDir, execCtx.WorkingDir),
+		fmt.Sprintf("%s:%s", execCtx.OutputDir, outputDir),
+	}
+	if execCtx.TempDir != "" {
+		bindMounts = append(bindMounts, fmt.Sprintf("%s:%s", execCtx.TempDir, ContainerTmpDir))
+	}
+
+	// Add additional volumes if specified
+	bindMounts = append(bindMounts, execCtx.Container.Volumes...)
+
+	// Join all bind mounts
+	if len(bindMounts) > 0 {
+		containerCmd = append(containerCmd, "--bind", strings.Join(bindMounts, ","))
+	}
+
+	// Isolate the container from the network unless NetworkAccess allows
+	// it, where the executor enabled isolation
+	if !execCtx.NetworkAccess && execCtx.Container.NetworkIsolation {
+		containerCmd = append(containerCmd, "--net", "--network", "none")
+	}
+
+	// Run in the output directory, which is also HOME. Singularity always
+	// runs as the invoking user and does not allow HOME to be set with
+	// --env, so it is passed with --home instead.
+	containerCmd = append(containerCmd, "--pwd", outputDir)
+	containerCmd = append(containerCmd, "--home", fmt.Sprintf("%s:%s", execCtx.OutputDir, outputDir))
+
+	// Add environment variables
+	for name, value := range execCtx.EnvironmentVars {
+		containerCmd = append(containerCmd, "--env", fmt.Sprintf("%s=%s", name, value))
+	}
+
+	// Add container environment variables if specified
+	for _, env := range execCtx.Container.EnvVars {
+		if strings.HasPrefix(env, "HOME=") {
+			continue
+		}
+		containerCmd = append(containerCmd, "--env", env)
+	}
+
+	// Add image
+	containerCmd = append(containerCmd, containerImage(execCtx.Container))
+
+	// Add command and arguments
+	containerCmd = append(containerCmd, cmdArgs...)
+
+	return containerCmd, nil
+}
+
+// Prepare resolves the tool's image to a local SIF file. Local .sif files
+// are used in place; docker:// and other remote references, as well as
+// docker-archive tarballs, are converted once into the SIF cache and reused
+// by later jobs. A DockerRequirement run under Singularity is translated to
+// a docker:// reference; dockerImport and dockerFile are not supported.
+func (s *SingularityRuntime) Prepare(ctx context.Context, execCtx *ExecutionContext) error {
+	config := execCtx.Container
+
+	var source, key string
+	switch {
+	case config.Load != "":
+		path := resolveImageSource(config.Load, execCtx.WorkingDir)
+		info, err := os.Stat(path)
+		if err != nil {
+			return &CWLError{Err: err, Message: fmt.Sprintf("failed to open image archive: %s", config.Load)}
+		}
+		if strings.HasSuffix(path, ".sif") {
+			config.ImageID = path
+			return nil
+		}
+		// Tarballs are keyed by their identity so a changed file is rebuilt
+		source = "docker-archive://" + path
+		key = fmt.Sprintf("%s:%d:%d", path, info.Size(), info.ModTime().UnixNano())
+
+	case config.Image != "":
+		source = singularitySource(config.Image)
+
+	case config.ImageID != "":
+		if _, err := os.Stat(config.ImageID); err == nil {
+			return nil
+		}
+		source = singularitySource(config.ImageID)
+
+	case config.File != "":
+		return &CWLError{
+			Err:     ErrExecution,
+			Message: "building images from a definition file is not supported for Singularity",
+		}
+
+	case config.Import != "":
+		return &CWLError{
+			Err:     ErrUnsupported,
+			Message: fmt.Sprintf("dockerImport is not supported for Singularity; give a dockerPull, dockerLoad or dockerImageId for %s", config.Import),
+		}
+
+	default:
+		return &CWLError{
+			Err:     ErrExecution,
+			Message: "could not determine the container image to run",
+		}
+	}
+
+	if key == "" {
+		key = source
+	}
+
+	sif, err := s.cachedImage(ctx, source, key)
+	if err != nil {
+		return err
+	}
+	config.ImageID = sif
+	return nil
+}
+
+// singularitySource returns a Singularity build source for an image
+// reference, treating references without a scheme as Docker images
+func singularitySource(ref string) string {
+	if strings.Contains(ref, "://") {
+		return ref
+	}
+	return "docker://" + ref
+}
+
+// cacheDir returns the SIF cache directory
+func (s *SingularityRuntime) cacheDir() (string, error) {
+	if s.CacheDir != "" {
+		return s.CacheDir, nil
+	}
+	if dir := os.Getenv("CWLGO_SIF_CACHE"); dir != "" {
+		return dir, nil
+	}
+	dir, err := os.UserCacheDir()
+	if err != nil {
+		return "", &CWLError{Err: err, Message: "failed to determine SIF cache directory"}
+	}
+	return filepath.Join(dir, "cwlgo", "sif"), nil
+}
+
+// sifName returns the cache file name for a source, readable but unique
+func sifName(source, key string) string {
+	sum := sha256.Sum256([]byte(key))
+	name := source
+	if i := strings.LastIndex(name, "/"); i >= 0 {
+		name = name[i+1:]
+	}
+	name = strings.Map(func(r rune) rune {
+		if r >= 'a' && r <= 'z' || r >= 'A' && r <= 'Z' || r >= '0' && r <= '9' || r == '.' || r == '-' || r == '_' {
+			return r
+		}
+		return '_'
+	}, name)
+	return fmt.Sprintf("%s-%s.sif", name, hex.EncodeToString(sum[:])[:16])
+}
+
+// cachedImage returns the cached SIF for source, building it under a lock
+// if it does not exist yet
+func (s *SingularityRuntime) cachedImage(ctx context.Context, source, key string) (string, error) {
+	dir, err := s.cacheDir()
+	if err != nil {
+		return "", err
+	}
+	if err := os.MkdirAll(dir, 0755); err != nil {
+		return "", &CWLError{Err: err, Message: fmt.Sprintf("failed to create SIF cache directory: %s", dir)}
+	}
+
+	sif := filepath.Join(dir, sifName(source, key))
+	if _, err := os.Stat(sif); err == nil {
+		return sif, nil
+	}
+
+	// Serialize conversions of the same image across concurrent jobs
+	unlock, err := acquireFileLock(ctx, sif+".lock")
+	if err != nil {
+		return "", err
+	}
+	defer unlock()
+
+	// Another job may have built the image while we waited for the lock
+	if _, err := os.Stat(sif); err == nil {
+		return sif, nil
+	}
+
+	tmp := fmt.Sprintf("%s.tmp-%d", sif, os.Getpid())
+	defer os.Remove(tmp)
+
+	if _, err := runImageCommand(ctx, nil, s.command(), "build", tmp, source); err != nil {
+		return "", err
+	}
+
+	if err := os.Rename(tmp, sif); err != nil {
+		return "", &CWLError{Err: err, Message: fmt.Sprintf("failed to store SIF image: %s", sif)}
+	}
+
+	return sif, nil
+}
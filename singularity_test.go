@@ -0,0 +1,101 @@
+package cwlgo
+
+import (
+	"context"
+	"errors"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"testing"
+)
+
+// fakeSingularity stands in for the singularity CLI. "build <sif> <source>"
+// writes the source reference into the SIF file and logs the call.
+const fakeSingularity = `#!/bin/sh
+echo "$@" >> "$FAKE_ENGINE_LOG"
+if [ "$1" = "build" ]; then
+  echo "$3" > "$2"
+fi
+exit 0
+`
+
+func TestSingularityImageCache(t *testing.T) {
+	tempDir := t.TempDir()
+
+	command := filepath.Join(tempDir, "fake-singularity")
+	if err := os.WriteFile(command, []byte(fakeSingularity), 0755); err != nil {
+		t.Fatalf("Failed to write fake singularity: %v", err)
+	}
+	logFile := filepath.Join(tempDir, "engine.log")
+	t.Setenv("FAKE_ENGINE_LOG", logFile)
+
+	runtime := &SingularityRuntime{
+		Command:  command,
+		CacheDir: filepath.Join(tempDir, "cache"),
+	}
+
+	// Concurrent jobs with the same dockerPull reference share one SIF
+	var wg sync.WaitGroup
+	images := make([]string, 4)
+	errs := make([]error, 4)
+	for i := range images {
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+			execCtx := &ExecutionContext{
+				Container: &ContainerConfig{Type: "singularity", Image: "ubuntu:20.04", Pull: true},
+			}
+			errs[i] = runtime.Prepare(context.Background(), execCtx)
+			images[i] = execCtx.Container.ImageID
+		}(i)
+	}
+	wg.Wait()
+
+	for i := range images {
+		if errs[i] != nil {
+			t.Fatalf("Failed to prepare image: %v", errs[i])
+		}
+		if images[i] != images[0] {
+			t.Errorf("Expected all jobs to use %s, got %s", images[0], images[i])
+		}
+	}
+
+	content, err := os.ReadFile(images[0])
+	if err != nil {
+		t.Fatalf("Failed to read cached SIF: %v", err)
+	}
+	if strings.TrimSpace(string(content)) != "docker://ubuntu:20.04" {
+		t.Errorf("Expected dockerPull to be converted from docker://ubuntu:20.04, got %s", content)
+	}
+
+	log, _ := os.ReadFile(logFile)
+	if builds := strings.Count(string(log), "build "); builds != 1 {
+		t.Errorf("Expected exactly one build, got %d:\n%s", builds, log)
+	}
+
+	// Local SIF files are used in place without building
+	localSIF := filepath.Join(tempDir, "tool.sif")
+	if err := os.WriteFile(localSIF, []byte("sif"), 0644); err != nil {
+		t.Fatalf("Failed to write local SIF: %v", err)
+	}
+	execCtx := &ExecutionContext{
+		WorkingDir: tempDir,
+		Container:  &ContainerConfig{Type: "singularity", Load: "tool.sif"},
+	}
+	if err := runtime.Prepare(context.Background(), execCtx); err != nil {
+		t.Fatalf("Failed to prepare local SIF: %v", err)
+	}
+	if execCtx.Container.ImageID != localSIF {
+		t.Errorf("Expected local SIF %s, got %s", localSIF, execCtx.Container.ImageID)
+	}
+
+	// dockerImport tarballs cannot be converted
+	execCtx = &ExecutionContext{
+		WorkingDir: tempDir,
+		Container:  &ContainerConfig{Type: "singularity", Import: "rootfs.tar"},
+	}
+	if err := runtime.Prepare(context.Background(), execCtx); !errors.Is(err, ErrUnsupported) {
+		t.Errorf("Expected ErrUnsupported for dockerImport, got %v", err)
+	}
+}
@@ -0,0 +1,314 @@
+package cwlgo
+
+import (
+	"fmt"
+	"os"
+	"path"
+	"path/filepath"
+	"strings"
+)
+
+const (
+	// ContainerStagingDir is the directory inside containers under which
+	// input Files and Directories are mounted
+	ContainerStagingDir = "/var/lib/cwl"
+
+	// DefaultContainerOutputDir is where the output directory is mounted
+	// when dockerOutputDirectory is not given
+	DefaultContainerOutputDir = "/var/spool/cwl"
+
+	// ContainerTmpDir is where the job's temporary directory is mounted
+	ContainerTmpDir = "/tmp"
+)
+
+// PathMapping maps a host path to its location inside a container
+type PathMapping struct {
+	HostPath      string
+	ContainerPath string
+	ReadOnly      bool
+}
+
+// ContainerPath returns the container-side path of a host path, or the path
+// unchanged if it is not mapped
+func (ctx *ExecutionContext) ContainerPath(hostPath string) string {
+	return remapPath(hostPath, ctx.PathMappings, func(m PathMapping) (string, string) {
+		return m.HostPath, m.ContainerPath
+	})
+}
+
+// HostPath returns the host-side path of a container path, or the path
+// unchanged if it is not mapped
+func (ctx *ExecutionContext) HostPath(containerPath string) string {
+	return remapPath(containerPath, ctx.PathMappings, func(m PathMapping) (string, string) {
+		return m.ContainerPath, m.HostPath
+	})
+}
+
+// remapPath rewrites path using the mapping with the longest matching prefix
+func remapPath(path string, mappings []PathMapping, direction func(PathMapping) (string, string)) string {
+	best := -1
+	bestLen := 0
+	for i, mapping := range mappings {
+		from, _ := direction(mapping)
+		if (path == from || strings.HasPrefix(path, from+"/")) && len(from) > bestLen {
+			best = i
+			bestLen = len(from)
+		}
+	}
+	if best < 0 {
+		return path
+	}
+
+	from, to := direction(mappings[best])
+	return to + strings.TrimPrefix(path, from)
+}
+
+// stageInputs mounts each input File and Directory read-only into the
+// container and rewrites the input values, and with them the command line
+// and $(inputs.x.path), to the container-side paths. The host-side values
+// are kept in HostInputs.
+func (e *Executor) stageInputs(tool *CommandLineTool, execCtx *ExecutionContext) error {
+	if execCtx.Container == nil {
+		return nil
+	}
+
+	staged := make(map[string]string) // host path -> container path
+	inputs := make(map[string]interface{}, len(execCtx.Inputs))
+
+	for inputID, value := range execCtx.Inputs {
+		rewritten, err := e.stageValue(value, execCtx, staged)
+		if err != nil {
+			return &CWLError{
+				Err:     err,
+				Message: fmt.Sprintf("failed to stage input %s", inputID),
+			}
+		}
+		inputs[inputID] = rewritten
+	}
+
+	// Defaults are staged too so that they are visible in the container
+	for inputID, inputParam := range tool.Inputs {
+		if _, ok := inputs[inputID]; ok || inputParam.Default == nil {
+			continue
+		}
+		if len(collectFiles(inputParam.Default)) == 0 && !hasDirectory(inputParam.Default) {
+			continue
+		}
+		rewritten, err := e.stageValue(inputParam.Default, execCtx, staged)
+		if err != nil {
+			return &CWLError{
+				Err:     err,
+				Message: fmt.Sprintf("failed to stage default of input %s", inputID),
+			}
+		}
+		inputs[inputID] = rewritten
+	}
+
+	execCtx.HostInputs = execCtx.Inputs
+	execCtx.Inputs = inputs
+	return nil
+}
+
+// stageValue returns a copy of value with File and Directory paths mapped
+// into the container, recording a read-only mount for each. The secondary
+// files of a File are mounted beside it, the listing of a Directory is
+// mapped below it, and Files in records are staged too.
+func (e *Executor) stageValue(value interface{}, execCtx *ExecutionContext, staged map[string]string) (interface{}, error) {
+	switch v := value.(type) {
+	case map[string]interface{}:
+		class, _ := v["class"].(string)
+		if class != "File" && class != "Directory" {
+			record := make(map[string]interface{}, len(v))
+			for k, item := range v {
+				field, err := e.stageValue(item, execCtx, staged)
+				if err != nil {
+					return nil, err
+				}
+				record[k] = field
+			}
+			return record, nil
+		}
+
+		hostPath := stagingHostPath(v, execCtx)
+		if hostPath == "" {
+			return v, nil
+		}
+		containerPath, ok := staged[hostPath]
+		if !ok {
+			containerPath = fmt.Sprintf("%s/stg%d/%s", ContainerStagingDir, len(staged), filepath.Base(hostPath))
+			staged[hostPath] = containerPath
+			mountStaged(execCtx, hostPath, containerPath)
+		}
+		return e.stagedObject(v, hostPath, containerPath, false, execCtx, staged)
+
+	case []interface{}:
+		items := make([]interface{}, len(v))
+		for i, item := range v {
+			stagedItem, err := e.stageValue(item, execCtx, staged)
+			if err != nil {
+				return nil, err
+			}
+			items[i] = stagedItem
+		}
+		return items, nil
+	}
+
+	return value, nil
+}
+
+// stagedObject returns a copy of a File or Directory with its path set to
+// containerPath. Secondary files are mounted in the same directory, unless
+// covered, because the directory holding the object is mounted already.
+// Listing entries under a Directory are mapped below its container path.
+func (e *Executor) stagedObject(v map[string]interface{}, hostPath, containerPath string, covered bool, execCtx *ExecutionContext, staged map[string]string) (map[string]interface{}, error) {
+	object := make(map[string]interface{}, len(v))
+	for k, item := range v {
+		object[k] = item
+	}
+	object["path"] = containerPath
+	delete(object, "dirname")
+	if _, ok := v["location"]; ok {
+		object["location"] = "file://" + hostPath
+	}
+
+	if secondaryFiles, ok := v["secondaryFiles"].([]interface{}); ok {
+		items := make([]interface{}, len(secondaryFiles))
+		for i, item := range secondaryFiles {
+			secondary, _ := item.(map[string]interface{})
+			secondaryHostPath := stagingHostPath(secondary, execCtx)
+			if secondaryHostPath == "" {
+				items[i] = item
+				continue
+			}
+			secondaryPath := path.Join(path.Dir(containerPath), filepath.Base(secondaryHostPath))
+			secondaryCovered := covered && filepath.Dir(secondaryHostPath) == filepath.Dir(hostPath)
+			if !secondaryCovered {
+				mountStaged(execCtx, secondaryHostPath, secondaryPath)
+			}
+			stagedSecondary, err := e.stagedObject(secondary, secondaryHostPath, secondaryPath, secondaryCovered, execCtx, staged)
+			if err != nil {
+				return nil, err
+			}
+			items[i] = stagedSecondary
+		}
+		object["secondaryFiles"] = items
+	}
+
+	if listing, ok := v["listing"].([]interface{}); ok && v["class"] == "Directory" {
+		items := make([]interface{}, len(listing))
+		for i, item := range listing {
+			entry, _ := item.(map[string]interface{})
+			entryHostPath := stagingHostPath(entry, execCtx)
+			rel, err := filepath.Rel(hostPath, entryHostPath)
+			if entryHostPath == "" || err != nil || rel == ".." || strings.HasPrefix(rel, ".."+string(filepath.Separator)) {
+				// Entries outside the Directory are staged on their own
+				stagedEntry, err := e.stageValue(item, execCtx, staged)
+				if err != nil {
+					return nil, err
+				}
+				items[i] = stagedEntry
+				continue
+			}
+			stagedEntry, err := e.stagedObject(entry, entryHostPath, path.Join(containerPath, filepath.ToSlash(rel)), true, execCtx, staged)
+			if err != nil {
+				return nil, err
+			}
+			items[i] = stagedEntry
+		}
+		object["listing"] = items
+	}
+
+	return object, nil
+}
+
+// stagingHostPath returns the absolute host path of a File or Directory,
+// or "" if it has none
+func stagingHostPath(v map[string]interface{}, execCtx *ExecutionContext) string {
+	hostPath, _ := v["path"].(string)
+	if hostPath == "" {
+		if location, ok := v["location"].(string); ok && strings.HasPrefix(location, "file://") {
+			hostPath = strings.TrimPrefix(location, "file://")
+		}
+	}
+	if hostPath == "" {
+		return ""
+	}
+	if !filepath.IsAbs(hostPath) {
+		hostPath = filepath.Join(execCtx.WorkingDir, hostPath)
+	}
+	return filepath.Clean(hostPath)
+}
+
+// mountStaged mounts a host path read-only at a container path, unless it
+// is mounted there already
+func mountStaged(execCtx *ExecutionContext, hostPath, containerPath string) {
+	for _, mapping := range execCtx.PathMappings {
+		if mapping.ContainerPath == containerPath {
+			return
+		}
+	}
+	execCtx.PathMappings = append(execCtx.PathMappings, PathMapping{
+		HostPath:      hostPath,
+		ContainerPath: containerPath,
+		ReadOnly:      true,
+	})
+	execCtx.Container.Volumes = append(execCtx.Container.Volumes,
+		fmt.Sprintf("%s:%s:ro", hostPath, containerPath))
+}
+
+// hasDirectory reports whether a value contains a Directory object
+func hasDirectory(value interface{}) bool {
+	switch v := value.(type) {
+	case map[string]interface{}:
+		class, _ := v["class"].(string)
+		return class == "Directory"
+	case []interface{}:
+		for _, item := range v {
+			if hasDirectory(item) {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+// mountJobDirs maps the output and temporary directories into the container
+// and sets the user, HOME and TMPDIR the tool runs with. The output
+// directory is mounted at dockerOutputDirectory, or DefaultContainerOutputDir,
+// and is also the container's working directory.
+func (e *Executor) mountJobDirs(execCtx *ExecutionContext) {
+	config := execCtx.Container
+	if config == nil {
+		return
+	}
+
+	if config.OutputDir == "" {
+		config.OutputDir = DefaultContainerOutputDir
+	}
+	config.WorkDir = config.OutputDir
+
+	execCtx.PathMappings = append(execCtx.PathMappings,
+		PathMapping{HostPath: execCtx.OutputDir, ContainerPath: config.OutputDir})
+	config.EnvVars = append(config.EnvVars, "HOME="+config.OutputDir)
+
+	if execCtx.TempDir != "" {
+		execCtx.PathMappings = append(execCtx.PathMappings,
+			PathMapping{HostPath: execCtx.TempDir, ContainerPath: ContainerTmpDir})
+		config.EnvVars = append(config.EnvVars, "TMPDIR="+ContainerTmpDir)
+	}
+
+	// Run as the invoking user so output files are not owned by root
+	if !e.ContainerRunAsRoot && config.User == "" {
+		if uid, gid := os.Getuid(), os.Getgid(); uid >= 0 && gid >= 0 {
+			config.User = fmt.Sprintf("%d:%d", uid, gid)
+		}
+	}
+}
+
+// containerOutputDir returns the output directory inside the container
+func containerOutputDir(config *ContainerConfig) string {
+	if config.OutputDir != "" {
+		return config.OutputDir
+	}
+	return DefaultContainerOutputDir
+}
@@ -0,0 +1,199 @@
+package cwlgo
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestStageInputs(t *testing.T) {
+	tool := &CommandLineTool{
+		CWLVersion:  "v1.2",
+		Class:       "CommandLineTool",
+		BaseCommand: "wc",
+		Inputs: map[string]CommandInputParameter{
+			"reads": {
+				Type: "File",
+				Binding: &CommandLineBinding{
+					Position: 2,
+				},
+			},
+		},
+		Arguments: []CommandLineBinding{
+			{
+				Position:  1,
+				Prefix:    "--index=",
+				Separate:  new(bool),
+				ValueFrom: "$(inputs.reads.path).idx",
+			},
+		},
+	}
+
+	executor := NewExecutor()
+
+	execCtx, err := NewExecutionContext("")
+	if err != nil {
+		t.Fatalf("Failed to create execution context: %v", err)
+	}
+	defer execCtx.Cleanup()
+
+	execCtx.Container = &ContainerConfig{Type: "docker", Image: "ubuntu:20.04"}
+	execCtx.Inputs = map[string]interface{}{
+		"reads": map[string]interface{}{
+			"class": "File",
+			"path":  "/data/samples/reads.fastq",
+		},
+	}
+
+	if err := executor.stageInputs(tool, execCtx); err != nil {
+		t.Fatalf("Failed to stage inputs: %v", err)
+	}
+
+	containerPath := ContainerStagingDir + "/stg0/reads.fastq"
+
+	// The input is mounted read-only at the staging path
+	expectedVolume := "/data/samples/reads.fastq:" + containerPath + ":ro"
+	if len(execCtx.Container.Volumes) != 1 || execCtx.Container.Volumes[0] != expectedVolume {
+		t.Errorf("Expected volume %s, got %v", expectedVolume, execCtx.Container.Volumes)
+	}
+
+	// The command line and expressions use the container path
+	cmdArgs, err := executor.BuildCommandLine(tool, execCtx)
+	if err != nil {
+		t.Fatalf("Failed to build command line: %v", err)
+	}
+	expected := "wc --index=" + containerPath + ".idx " + containerPath
+	if got := strings.Join(cmdArgs, " "); got != expected {
+		t.Errorf("Expected command line %q, got %q", expected, got)
+	}
+
+	// Container paths map back to host paths
+	if got := execCtx.HostPath(containerPath); got != "/data/samples/reads.fastq" {
+		t.Errorf("Expected host path /data/samples/reads.fastq, got %s", got)
+	}
+
+	// The original values are kept
+	hostReads := execCtx.HostInputs["reads"].(map[string]interface{})
+	if hostReads["path"] != "/data/samples/reads.fastq" {
+		t.Errorf("Expected host inputs to keep the host path, got %v", hostReads["path"])
+	}
+}
+
+func TestMountJobDirs(t *testing.T) {
+	executor := NewExecutor()
+
+	execCtx := &ExecutionContext{
+		WorkingDir: "/work",
+		TempDir:    "/tmp/cwl-job",
+		OutputDir:  "/work/output",
+		Container:  &ContainerConfig{Type: "docker", Image: "ubuntu:20.04"},
+	}
+
+	executor.mountJobDirs(execCtx)
+
+	// Outputs written in the container map back to the host output directory
+	if got := execCtx.HostPath(DefaultContainerOutputDir + "/result.txt"); got != "/work/output/result.txt" {
+		t.Errorf("Expected host path /work/output/result.txt, got %s", got)
+	}
+	if got := execCtx.ContainerPath("/tmp/cwl-job/scratch"); got != ContainerTmpDir+"/scratch" {
+		t.Errorf("Expected container path %s/scratch, got %s", ContainerTmpDir, got)
+	}
+
+	cmd := strings.Join(buildDockerStyleCommand("docker", nil, []string{"true"}, execCtx), " ")
+	for _, expected := range []string{
+		"-v /work/output:" + DefaultContainerOutputDir,
+		"-v /tmp/cwl-job:" + ContainerTmpDir,
+		"-w " + DefaultContainerOutputDir,
+		"-e HOME=" + DefaultContainerOutputDir,
+		"-e TMPDIR=" + ContainerTmpDir,
+		"--user ",
+	} {
+		if !strings.Contains(cmd, expected) {
+			t.Errorf("Expected %q in command, got %q", expected, cmd)
+		}
+	}
+
+	// dockerOutputDirectory overrides the default and root can be requested
+	executor.ContainerRunAsRoot = true
+	execCtx = &ExecutionContext{
+		WorkingDir: "/work",
+		OutputDir:  "/work/output",
+		Container:  &ContainerConfig{Type: "docker", Image: "ubuntu:20.04", OutputDir: "/results"},
+	}
+	executor.mountJobDirs(execCtx)
+
+	cmd = strings.Join(buildDockerStyleCommand("docker", nil, []string{"true"}, execCtx), " ")
+	if !strings.Contains(cmd, "-v /work/output:/results") || !strings.Contains(cmd, "-w /results") {
+		t.Errorf("Expected output directory mounted at /results, got %q", cmd)
+	}
+	if strings.Contains(cmd, "--user") {
+		t.Errorf("Expected no --user when running as root, got %q", cmd)
+	}
+}
+
+func TestStageSecondaryFilesAndRecords(t *testing.T) {
+	executor := NewExecutor()
+
+	execCtx := &ExecutionContext{
+		WorkingDir: "/work",
+		Container:  &ContainerConfig{Type: "docker", Image: "ubuntu:20.04"},
+	}
+	staged := make(map[string]string)
+
+	value := map[string]interface{}{
+		"reference": map[string]interface{}{
+			"class": "File",
+			"path":  "/data/ref.fa",
+			"secondaryFiles": []interface{}{
+				map[string]interface{}{"class": "File", "path": "/data/ref.fa.fai"},
+			},
+		},
+		"samples": map[string]interface{}{
+			"class": "Directory",
+			"path":  "/data/samples",
+			"listing": []interface{}{
+				map[string]interface{}{"class": "File", "path": "/data/samples/a/reads.fastq"},
+			},
+		},
+	}
+
+	result, err := executor.stageValue(value, execCtx, staged)
+	if err != nil {
+		t.Fatalf("Failed to stage value: %v", err)
+	}
+	record := result.(map[string]interface{})
+
+	// Files in record fields are staged
+	reference := record["reference"].(map[string]interface{})
+	referencePath, _ := reference["path"].(string)
+	if !strings.HasPrefix(referencePath, ContainerStagingDir+"/stg") || !strings.HasSuffix(referencePath, "/ref.fa") {
+		t.Fatalf("Expected the record's File to be staged, got %v", reference["path"])
+	}
+
+	// Secondary files are mounted beside their primary
+	secondary := reference["secondaryFiles"].([]interface{})[0].(map[string]interface{})
+	expected := strings.TrimSuffix(referencePath, "ref.fa") + "ref.fa.fai"
+	if secondary["path"] != expected {
+		t.Errorf("Expected secondary file at %s, got %v", expected, secondary["path"])
+	}
+	if got := execCtx.HostPath(expected); got != "/data/ref.fa.fai" {
+		t.Errorf("Expected %s to be mounted from /data/ref.fa.fai, got %s", expected, got)
+	}
+
+	// Listings are mapped below their Directory without mounts of their own
+	samples := record["samples"].(map[string]interface{})
+	entry := samples["listing"].([]interface{})[0].(map[string]interface{})
+	if expected := samples["path"].(string) + "/a/reads.fastq"; entry["path"] != expected {
+		t.Errorf("Expected listing entry at %s, got %v", expected, entry["path"])
+	}
+	if len(execCtx.Container.Volumes) != 3 {
+		t.Errorf("Expected 3 volumes, got %v", execCtx.Container.Volumes)
+	}
+
+	// Staging the same File again adds no mounts
+	if _, err := executor.stageValue(value["reference"], execCtx, staged); err != nil {
+		t.Fatalf("Failed to stage value: %v", err)
+	}
+	if len(execCtx.Container.Volumes) != 3 {
+		t.Errorf("Expected 3 volumes after staging again, got %v", execCtx.Container.Volumes)
+	}
+}
@@ -0,0 +1,188 @@
+package cwlgo
+
+import (
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"sync"
+)
+
+// DefaultCaptureLimit is the default number of bytes of stdout and stderr
+// kept in memory for ExecuteResult
+const DefaultCaptureLimit = 64 * 1024
+
+// cappedBuffer keeps at most limit bytes in memory and silently drops the
+// rest, so that it never causes a short write inside an io.MultiWriter
+type cappedBuffer struct {
+	mu        sync.Mutex
+	buf       []byte
+	limit     int64
+	truncated bool
+}
+
+// Write implements io.Writer
+func (b *cappedBuffer) Write(p []byte) (int, error) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	remaining := b.limit - int64(len(b.buf))
+	if remaining <= 0 {
+		if len(p) > 0 {
+			b.truncated = true
+		}
+		return len(p), nil
+	}
+
+	if int64(len(p)) > remaining {
+		b.buf = append(b.buf, p[:remaining]...)
+		b.truncated = true
+	} else {
+		b.buf = append(b.buf, p...)
+	}
+	return len(p), nil
+}
+
+// String returns the captured bytes
+func (b *cappedBuffer) String() string {
+	if b == nil {
+		return ""
+	}
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	return string(b.buf)
+}
+
+// Truncated reports whether output was dropped because of the limit
+func (b *cappedBuffer) Truncated() bool {
+	if b == nil {
+		return false
+	}
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	return b.truncated
+}
+
+// processStreams holds the stdin, stdout and stderr of a running tool
+type processStreams struct {
+	Stdin  io.Reader
+	Stdout io.Writer
+	Stderr io.Writer
+
+	StdoutPath string
+	StderrPath string
+
+	stdoutCapture *cappedBuffer
+	stderrCapture *cappedBuffer
+	files         []*os.File
+}
+
+// openStreams sets up the process streams for a job. stdout and stderr are
+// streamed to the resolved stdout/stderr files in the output directory when
+// the tool redirects them. They are additionally copied to a size-capped
+// in-memory buffer when CaptureLimit is positive, and to the executor's
+// StdoutWriter/StderrWriter sinks when set; other output is discarded.
+func (e *Executor) openStreams(execCtx *ExecutionContext) (*processStreams, error) {
+	streams := &processStreams{}
+
+	// Handle stdin if specified
+	if execCtx.Stdin != "" {
+		stdinFile, err := os.Open(execCtx.Stdin)
+		if err != nil {
+			return nil, &CWLError{
+				Err:     err,
+				Message: fmt.Sprintf("failed to open stdin file: %s", execCtx.Stdin),
+			}
+		}
+		streams.files = append(streams.files, stdinFile)
+		streams.Stdin = stdinFile
+	}
+
+	var stdoutWriters, stderrWriters []io.Writer
+	if execCtx.Stdout != "" {
+		stdoutFile, err := e.createStreamFile(execCtx, execCtx.Stdout, "stdout")
+		if err != nil {
+			streams.Close()
+			return nil, err
+		}
+		streams.files = append(streams.files, stdoutFile)
+		streams.StdoutPath = stdoutFile.Name()
+		stdoutWriters = append(stdoutWriters, stdoutFile)
+	}
+	if execCtx.Stderr != "" {
+		stderrFile, err := e.createStreamFile(execCtx, execCtx.Stderr, "stderr")
+		if err != nil {
+			streams.Close()
+			return nil, err
+		}
+		streams.files = append(streams.files, stderrFile)
+		streams.StderrPath = stderrFile.Name()
+		stderrWriters = append(stderrWriters, stderrFile)
+	}
+
+	if e.CaptureLimit > 0 {
+		streams.stdoutCapture = &cappedBuffer{limit: e.CaptureLimit}
+		streams.stderrCapture = &cappedBuffer{limit: e.CaptureLimit}
+		stdoutWriters = append(stdoutWriters, streams.stdoutCapture)
+		stderrWriters = append(stderrWriters, streams.stderrCapture)
+	}
+
+	if e.StdoutWriter != nil {
+		stdoutWriters = append(stdoutWriters, e.StdoutWriter)
+	}
+	if e.StderrWriter != nil {
+		stderrWriters = append(stderrWriters, e.StderrWriter)
+	}
+
+	streams.Stdout = io.Discard
+	if len(stdoutWriters) > 0 {
+		streams.Stdout = io.MultiWriter(stdoutWriters...)
+	}
+	streams.Stderr = io.Discard
+	if len(stderrWriters) > 0 {
+		streams.Stderr = io.MultiWriter(stderrWriters...)
+	}
+
+	return streams, nil
+}
+
+// createStreamFile creates the file a stream is redirected to, named
+// relative to the output directory
+func (e *Executor) createStreamFile(execCtx *ExecutionContext, name, stream string) (*os.File, error) {
+	path := filepath.Join(execCtx.OutputDir, name)
+	if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+		return nil, &CWLError{
+			Err:     err,
+			Message: fmt.Sprintf("failed to create directory for %s file: %s", stream, path),
+		}
+	}
+	file, err := os.Create(path)
+	if err != nil {
+		return nil, &CWLError{
+			Err:     err,
+			Message: fmt.Sprintf("failed to create %s file: %s", stream, path),
+		}
+	}
+	return file, nil
+}
+
+// Close closes all files opened for the streams
+func (s *processStreams) Close() {
+	for _, file := range s.files {
+		file.Close()
+	}
+	s.files = nil
+}
+
+// result fills in the captured output of an ExecuteResult
+func (s *processStreams) result(exitCode int) *ExecuteResult {
+	return &ExecuteResult{
+		ExitCode:        exitCode,
+		Stdout:          s.stdoutCapture.String(),
+		Stderr:          s.stderrCapture.String(),
+		StdoutPath:      s.StdoutPath,
+		StderrPath:      s.StderrPath,
+		StdoutTruncated: s.stdoutCapture.Truncated(),
+		StderrTruncated: s.stderrCapture.Truncated(),
+	}
+}
@@ -0,0 +1,123 @@
+package cwlgo
+
+import (
+	"bytes"
+	"context"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestCappedBuffer(t *testing.T) {
+	buf := &cappedBuffer{limit: 5}
+
+	n, err := buf.Write([]byte("Hello, CWL!"))
+	if err != nil {
+		t.Fatalf("Unexpected write error: %v", err)
+	}
+	if n != 11 {
+		t.Errorf("Expected write to report 11 bytes, got %d", n)
+	}
+
+	if buf.String() != "Hello" {
+		t.Errorf("Expected captured output 'Hello', got %s", buf.String())
+	}
+
+	if !buf.Truncated() {
+		t.Error("Expected buffer to be marked as truncated")
+	}
+}
+
+func TestExecuteStreamsOutput(t *testing.T) {
+	tool := &CommandLineTool{
+		CWLVersion:  "v1.2",
+		Class:       "CommandLineTool",
+		BaseCommand: "echo",
+		Inputs: map[string]CommandInputParameter{
+			"message": {
+				Type: "string",
+				Binding: &CommandLineBinding{
+					Position: 1,
+				},
+			},
+		},
+		Outputs: map[string]CommandOutputParameter{
+			"output": {
+				Type: "stdout",
+			},
+		},
+		Stdout: "streamed.txt",
+	}
+
+	inputs := map[string]interface{}{
+		"message": "Hello, CWL!",
+	}
+
+	// Disable in-memory capture and attach a live sink instead
+	var sink bytes.Buffer
+	executor := NewExecutor()
+	executor.CaptureLimit = 0
+	executor.StdoutWriter = &sink
+
+	result, err := executor.Execute(context.Background(), tool, inputs)
+	if err != nil {
+		t.Fatalf("Failed to execute tool: %v", err)
+	}
+	defer os.Remove(result.StdoutPath)
+	defer os.Remove(result.StderrPath)
+
+	if result.Stdout != "" {
+		t.Errorf("Expected no captured stdout, got %s", result.Stdout)
+	}
+
+	if !strings.Contains(sink.String(), "Hello, CWL!") {
+		t.Errorf("Expected sink to receive stdout, got %s", sink.String())
+	}
+
+	content, err := os.ReadFile(result.StdoutPath)
+	if err != nil {
+		t.Fatalf("Failed to read stdout file: %v", err)
+	}
+	if !strings.Contains(string(content), "Hello, CWL!") {
+		t.Errorf("Expected stdout file to contain 'Hello, CWL!', got %s", content)
+	}
+
+	if result.OutputFiles["output"] != result.StdoutPath {
+		t.Errorf("Expected stdout output to be %s, got %s", result.StdoutPath, result.OutputFiles["output"])
+	}
+}
+
+func TestExecuteWithoutRedirects(t *testing.T) {
+	enterTempDir(t)
+
+	tool := &CommandLineTool{
+		CWLVersion:  "v1.2",
+		Class:       "CommandLineTool",
+		BaseCommand: []interface{}{"sh", "-c", "echo out; echo err >&2"},
+		Outputs: map[string]CommandOutputParameter{
+			"errors": {Type: "stderr"},
+		},
+	}
+
+	result, err := NewExecutor().Execute(context.Background(), tool, map[string]interface{}{})
+	if err != nil {
+		t.Fatalf("Failed to execute tool: %v", err)
+	}
+
+	if result.Stdout != "out\n" || result.StdoutPath != "" {
+		t.Errorf("Expected stdout captured but not written to a file, got %q in %q", result.Stdout, result.StdoutPath)
+	}
+
+	// A stderr output without a stderr name gets a file in the output directory
+	entries, err := os.ReadDir("output")
+	if err != nil {
+		t.Fatalf("Failed to read output directory: %v", err)
+	}
+	if len(entries) != 1 || !strings.HasPrefix(entries[0].Name(), "stderr-") {
+		t.Fatalf("Expected only the stderr output in the output directory, got %v", entries)
+	}
+	if result.OutputFiles["errors"] != result.StderrPath || filepath.Base(result.StderrPath) != entries[0].Name() {
+		t.Errorf("Expected stderr output to be %s, got %s", result.StderrPath, result.OutputFiles["errors"])
+	}
+}
@@ -0,0 +1,1117 @@
+package cwlgo
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"reflect"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+
+	"gopkg.in/yaml.v3"
+)
+
+// Severity is how serious a validation finding is
+type Severity string
+
+// Validation severities. Documents with errors are rejected by the parser
+// or fail when run; warnings point at parts that are ignored.
+const (
+	SeverityError   Severity = "error"
+	SeverityWarning Severity = "warning"
+)
+
+// Finding is a problem found in a CWL document
+type Finding struct {
+	Severity Severity
+	File     string
+	Line     int // 1-based; zero when the position is unknown
+	Column   int
+	Message  string
+}
+
+// String formats the finding as "file:line:column: severity: message"
+func (f Finding) String() string {
+	position := f.File
+	if f.Line > 0 {
+		position = fmt.Sprintf("%s:%d:%d", f.File, f.Line, f.Column)
+	}
+	return fmt.Sprintf("%s: %s: %s", position, f.Severity, f.Message)
+}
+
+// ValidationReport lists the findings of validating a document and the
+// documents its steps run, ordered by file and position
+type ValidationReport struct {
+	Findings []Finding
+}
+
+// Valid reports whether no errors were found
+func (r *ValidationReport) Valid() bool {
+	return len(r.Errors()) == 0
+}
+
+// Errors returns the findings with error severity
+func (r *ValidationReport) Errors() []Finding {
+	return r.filter(SeverityError)
+}
+
+// Warnings returns the findings with warning severity
+func (r *ValidationReport) Warnings() []Finding {
+	return r.filter(SeverityWarning)
+}
+
+// filter returns the findings with a severity
+func (r *ValidationReport) filter(severity Severity) []Finding {
+	var findings []Finding
+	for _, f := range r.Findings {
+		if f.Severity == severity {
+			findings = append(findings, f)
+		}
+	}
+	return findings
+}
+
+// ValidateFile checks a CWL document and the documents run by its steps,
+// and reports every problem found instead of stopping at the first one:
+// malformed YAML, fields of the wrong type, unknown types and classes,
+// references to unknown inputs, sources or step outputs, and unsupported
+// requirements. Unknown fields are errors under StrictValidation and
+// warnings otherwise. Every process of a packed document is checked, and
+// a fragment, e.g. "packed.cwl#main", selects the one run by default. The
+// error is only set if the file cannot be read.
+func (p *Parser) ValidateFile(filePath string) (*ValidationReport, error) {
+	path, fragment := splitFragment(filePath)
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, &CWLError{
+			Err:     err,
+			Message: fmt.Sprintf("failed to open CWL file: %s", path),
+		}
+	}
+
+	v := &validator{
+		parser:  p,
+		report:  &ValidationReport{},
+		visited: map[string]bool{filepath.Clean(path): true},
+		file:    path,
+		baseDir: filepath.Dir(path),
+	}
+	if root := v.parseDocument(data); root != nil {
+		v.document(root, fragment)
+	}
+
+	// The parser's own checks, such as step cycles, cover what remains
+	if v.report.Valid() {
+		if _, err := p.ParseProcessFile(filePath); err != nil {
+			v.report.Findings = append(v.report.Findings, Finding{
+				Severity: SeverityError,
+				File:     path,
+				Message:  err.Error(),
+			})
+		}
+	}
+
+	sort.SliceStable(v.report.Findings, func(i, j int) bool {
+		a, b := v.report.Findings[i], v.report.Findings[j]
+		if a.File != b.File {
+			return a.File < b.File
+		}
+		if a.Line != b.Line {
+			return a.Line < b.Line
+		}
+		return a.Column < b.Column
+	})
+	return v.report, nil
+}
+
+// validator collects the findings of one document. Validators of the
+// documents run by steps share the report.
+type validator struct {
+	parser  *Parser
+	report  *ValidationReport
+	visited map[string]bool // Run files already validated
+	file    string
+	baseDir string
+
+	// origins records the file of nodes taken from other documents by
+	// $import and $mixin directives
+	origins map[*yaml.Node]string
+
+	// The processes of a packed document by ID, the document's cwlVersion
+	// and what is known of the processes validated so far
+	graph        map[string]*yaml.Node
+	graphVersion string
+	graphInfo    map[string]*processInfo
+}
+
+// processInfo is what a workflow step needs to know about the process it
+// runs: its input IDs with whether each is required, and its output IDs
+type processInfo struct {
+	inputs  map[string]bool
+	outputs map[string]bool
+}
+
+// fieldKind is the expected shape of a field's value
+type fieldKind int
+
+const (
+	kindAny fieldKind = iota
+	kindString
+	kindBool
+	kindInt
+	kindMap
+	kindList
+	kindStringList
+	kindStringOrList // A string or a list of strings
+	kindIntList
+)
+
+// String describes a field kind for messages
+func (k fieldKind) String() string {
+	switch k {
+	case kindString:
+		return "a string"
+	case kindBool:
+		return "a boolean"
+	case kindInt:
+		return "an integer"
+	case kindMap:
+		return "an object"
+	case kindList:
+		return "a list"
+	case kindStringList:
+		return "a list of strings"
+	case kindStringOrList:
+		return "a string or a list of strings"
+	case kindIntList:
+		return "a list of integers"
+	}
+	return "a value"
+}
+
+// Fields of each kind of object, derived from the types the parser decodes
+// them into, so that the validator accepts exactly what the parser reads
+var (
+	commandLineToolFields      = structFields(CommandLineTool{})
+	workflowFields             = structFields(Workflow{})
+	expressionToolFields       = structFields(ExpressionTool{})
+	commandInputFields         = structFields(CommandInputParameter{})
+	commandOutputFields        = structFields(CommandOutputParameter{})
+	commandLineBindingFields   = structFields(CommandLineBinding{})
+	commandOutputBindingFields = structFields(CommandOutputBinding{})
+	expressionToolOutputFields = structFields(ExpressionToolOutputParameter{})
+	workflowInputFields        = structFields(WorkflowInputParameter{})
+	workflowOutputFields       = structFields(WorkflowOutputParameter{})
+	workflowStepFields         = structFields(WorkflowStep{})
+	workflowStepInputFields    = structFields(WorkflowStepInput{})
+
+	// A packed document is only a container for its processes
+	packedDocumentFields = map[string]fieldKind{
+		"cwlVersion": kindString, "$graph": kindList,
+		"$namespaces": kindMap, "$schemas": kindStringList,
+	}
+)
+
+// structFields returns the fields of a struct by their YAML names, with
+// the kind of value each field's type accepts. Fields of type interface{}
+// accept any value, unless tagged cwl:"stringOrList".
+func structFields(v interface{}) map[string]fieldKind {
+	t := reflect.TypeOf(v)
+	fields := make(map[string]fieldKind, t.NumField())
+	for i := 0; i < t.NumField(); i++ {
+		field := t.Field(i)
+		name, _, _ := strings.Cut(field.Tag.Get("yaml"), ",")
+		if name == "" || name == "-" {
+			continue
+		}
+		if field.Tag.Get("cwl") == "stringOrList" {
+			fields[name] = kindStringOrList
+			continue
+		}
+		fields[name] = typeKind(field.Type)
+	}
+	return fields
+}
+
+// typeKind returns the kind of value a Go type is decoded from
+func typeKind(t reflect.Type) fieldKind {
+	if t.Kind() == reflect.Ptr {
+		t = t.Elem()
+	}
+	switch t.Kind() {
+	case reflect.String:
+		return kindString
+	case reflect.Bool:
+		return kindBool
+	case reflect.Int, reflect.Int64:
+		return kindInt
+	case reflect.Map, reflect.Struct:
+		return kindMap
+	case reflect.Slice:
+		switch t.Elem().Kind() {
+		case reflect.String:
+			return kindStringList
+		case reflect.Int:
+			return kindIntList
+		}
+		return kindList
+	}
+	return kindAny
+}
+
+// toolRequirements are the requirement classes the executor supports for
+// tools; workflows may also declare the workflowFeatures
+var toolRequirements = map[string]bool{
+	"DockerRequirement":           true,
+	"SingularityRequirement":      true,
+	"EnvVarRequirement":           true,
+	"ResourceRequirement":         true,
+	"InlineJavascriptRequirement": true,
+	"NetworkAccess":               true,
+	"WorkReuse":                   true,
+}
+
+// cwlTypes are the CWL type names, without the "?" and "[]" shorthands
+var cwlTypes = map[string]bool{
+	"null": true, "boolean": true, "int": true, "long": true, "float": true,
+	"double": true, "string": true, "File": true, "Directory": true, "Any": true,
+}
+
+// schemaTypes are the types defined by a schema object
+var schemaTypes = map[string]bool{"array": true, "enum": true, "record": true}
+
+// inputReference matches references to inputs in expressions
+var inputReference = regexp.MustCompile(`inputs\.([A-Za-z_][A-Za-z0-9_]*)`)
+
+// yamlErrorLine extracts the line number from a YAML syntax error
+var yamlErrorLine = regexp.MustCompile(`line (\d+)`)
+
+// parseDocument parses YAML or JSON into a node tree, expands its
+// directives and returns its root mapping, or nil after reporting why
+// there is none
+func (v *validator) parseDocument(data []byte) *yaml.Node {
+	var doc yaml.Node
+	if err := yaml.Unmarshal(data, &doc); err != nil {
+		finding := Finding{Severity: SeverityError, File: v.file, Message: err.Error()}
+		if match := yamlErrorLine.FindStringSubmatch(err.Error()); match != nil {
+			finding.Line, _ = strconv.Atoi(match[1])
+			finding.Column = 1
+		}
+		v.report.Findings = append(v.report.Findings, finding)
+		return nil
+	}
+	if len(doc.Content) == 0 {
+		v.report.Findings = append(v.report.Findings, Finding{Severity: SeverityError, File: v.file, Message: "document is empty"})
+		return nil
+	}
+
+	if hasDirectives(data) {
+		base, err := fileURI(v.file)
+		if err != nil {
+			v.report.Findings = append(v.report.Findings, Finding{Severity: SeverityError, File: v.file, Message: err.Error()})
+			return nil
+		}
+		r := &directiveResolver{root: v.parser.Root}
+		if err := r.resolve(&doc, base, []string{referenceString(base)}); err != nil {
+			v.report.Findings = append(v.report.Findings, Finding{Severity: SeverityError, File: v.file, Message: err.Error()})
+			return nil
+		}
+		v.origins = r.origins
+	}
+
+	root := doc.Content[0]
+	normalizeDocumentNode(root)
+	if root.Kind != yaml.MappingNode {
+		v.errorf(root, "document must be an object")
+		return nil
+	}
+	return root
+}
+
+// errorf records an error at a node's position
+func (v *validator) errorf(node *yaml.Node, format string, args ...interface{}) {
+	v.add(SeverityError, node, format, args...)
+}
+
+// warnf records a warning at a node's position
+func (v *validator) warnf(node *yaml.Node, format string, args ...interface{}) {
+	v.add(SeverityWarning, node, format, args...)
+}
+
+// add records a finding at a node's position
+func (v *validator) add(severity Severity, node *yaml.Node, format string, args ...interface{}) {
+	file := v.file
+	if origin, ok := v.origins[node]; ok {
+		file = origin
+	}
+	v.report.Findings = append(v.report.Findings, Finding{
+		Severity: severity,
+		File:     file,
+		Line:     node.Line,
+		Column:   node.Column,
+		Message:  fmt.Sprintf(format, args...),
+	})
+}
+
+// document validates a document's process or, for a packed document, all
+// the processes of its $graph, and returns what is known of the process
+// selected by the fragment, as the loader would select it
+func (v *validator) document(root *yaml.Node, fragment string) *processInfo {
+	graphNode := mappingValue(root, "$graph")
+	if graphNode == nil {
+		info := v.process(root, "")
+		if id := mappingValue(root, "id"); fragment != "" && !matchesFragment(scalarValue(id), fragment) {
+			v.errorf(root, "no process with ID %s", fragment)
+			return nil
+		}
+		return info
+	}
+
+	fields := v.fields(root, "packed document", packedDocumentFields)
+	if versionNode := fields["cwlVersion"]; versionNode != nil {
+		v.graphVersion = versionNode.Value
+	}
+	if graphNode.Kind != yaml.SequenceNode {
+		return nil
+	}
+
+	v.graph = make(map[string]*yaml.Node)
+	v.graphInfo = make(map[string]*processInfo)
+	for _, item := range graphNode.Content {
+		if item.Kind != yaml.MappingNode {
+			v.errorf(item, "$graph entries must be processes")
+			continue
+		}
+		idNode := mappingValue(item, "id")
+		id := graphID(scalarValue(idNode))
+		switch {
+		case id == "":
+			v.errorf(item, "$graph entries must have an id")
+		case v.graph[id] != nil:
+			v.errorf(idNode, "duplicate process ID %s in $graph", id)
+		default:
+			v.graph[id] = item
+		}
+	}
+	for _, id := range nodeIDs(v.graph) {
+		v.graphProcess(id)
+	}
+
+	switch {
+	case fragment != "":
+	case v.graph[graphMain] != nil:
+		fragment = graphMain
+	case len(v.graph) == 1:
+		fragment = nodeIDs(v.graph)[0]
+	default:
+		v.errorf(graphNode, "$graph has no %q process; select one of %s with a #fragment", graphMain, strings.Join(nodeIDs(v.graph), ", "))
+		return nil
+	}
+	if v.graph[fragment] == nil {
+		v.errorf(graphNode, "no process with ID %s in $graph", fragment)
+		return nil
+	}
+	return v.graphProcess(fragment)
+}
+
+// graphProcess validates a process of a packed document once, and returns
+// what is known of it. A process that is still being validated, because a
+// step runs a process containing it, returns nil; the parser reports the
+// cycle.
+func (v *validator) graphProcess(id string) *processInfo {
+	if info, ok := v.graphInfo[id]; ok {
+		return info
+	}
+	v.graphInfo[id] = nil
+	info := v.process(v.graph[id], v.graphVersion)
+	v.graphInfo[id] = info
+	return info
+}
+
+// process validates a CommandLineTool, Workflow or ExpressionTool. Inline
+// processes inherit cwlVersion from the enclosing document. It returns nil
+// if the class is unknown.
+func (v *validator) process(node *yaml.Node, cwlVersion string) *processInfo {
+	classNode := mappingValue(node, "class")
+	if classNode == nil {
+		v.errorf(node, "class is required")
+		return nil
+	}
+
+	if versionNode := mappingValue(node, "cwlVersion"); versionNode != nil {
+		switch versionNode.Value {
+		case "v1.0", "v1.1", "v1.2":
+		default:
+			v.warnf(versionNode, "unknown cwlVersion %s", versionNode.Value)
+		}
+	} else if cwlVersion == "" {
+		v.errorf(node, "cwlVersion is required")
+	}
+
+	switch classNode.Value {
+	case "CommandLineTool":
+		return v.commandLineTool(node)
+	case "Workflow":
+		return v.workflow(node)
+	case "ExpressionTool":
+		return v.expressionTool(node)
+	}
+	v.errorf(classNode, "unsupported process class: %s", classNode.Value)
+	return nil
+}
+
+// commandLineTool validates a CommandLineTool
+func (v *validator) commandLineTool(node *yaml.Node) *processInfo {
+	fields := v.fields(node, "CommandLineTool", commandLineToolFields)
+	v.requirements(fields["requirements"], toolRequirements, false)
+	v.requirements(fields["hints"], toolRequirements, true)
+
+	if baseCommand := fields["baseCommand"]; baseCommand != nil {
+		for _, item := range stringValues(baseCommand) {
+			if item.Tag != "!!str" {
+				v.errorf(item, "baseCommand must be a string or array of strings")
+			}
+		}
+	}
+
+	info := v.parameters(fields["inputs"], "input", commandInputFields, false, func(param map[string]*yaml.Node) {
+		if binding := param["inputBinding"]; binding != nil {
+			v.fields(binding, "inputBinding", commandLineBindingFields)
+		}
+	})
+	outputs := v.parameters(fields["outputs"], "output", commandOutputFields, true, func(param map[string]*yaml.Node) {
+		if binding := param["outputBinding"]; binding != nil {
+			v.fields(binding, "outputBinding", commandOutputBindingFields)
+		}
+	})
+	info.outputs = outputs.inputs
+
+	// Arguments are strings or bindings
+	bound := false
+	if arguments := fields["arguments"]; arguments != nil {
+		for _, argument := range arguments.Content {
+			bound = true
+			if argument.Kind == yaml.MappingNode {
+				v.fields(argument, "argument", commandLineBindingFields)
+			} else if argument.Kind != yaml.ScalarNode {
+				v.errorf(argument, "arguments must be strings or objects")
+			}
+		}
+	}
+	for _, pair := range mappingPairs(fields["inputs"]) {
+		if mappingValue(pair[1], "inputBinding") != nil {
+			bound = true
+		}
+	}
+	if fields["baseCommand"] == nil && !bound {
+		v.errorf(node, "baseCommand is required when no arguments or input bindings build the command line")
+	}
+
+	v.expressionReferences(node, info.inputs, "")
+	return info
+}
+
+// expressionTool validates an ExpressionTool
+func (v *validator) expressionTool(node *yaml.Node) *processInfo {
+	fields := v.fields(node, "ExpressionTool", expressionToolFields)
+	v.requirements(fields["requirements"], toolRequirements, false)
+	v.requirements(fields["hints"], toolRequirements, true)
+
+	if expression := fields["expression"]; expression == nil {
+		v.errorf(node, "expression is required")
+	} else if !IsExpression(expression.Value) {
+		v.errorf(expression, "expression must be a CWL expression")
+	}
+
+	info := v.parameters(fields["inputs"], "input", commandInputFields, false, nil)
+	outputs := v.parameters(fields["outputs"], "output", expressionToolOutputFields, false, nil)
+	info.outputs = outputs.inputs
+
+	v.expressionReferences(node, info.inputs, "")
+	return info
+}
+
+// workflow validates a Workflow and the processes its steps run
+func (v *validator) workflow(node *yaml.Node) *processInfo {
+	fields := v.fields(node, "Workflow", workflowFields)
+	supported := make(map[string]bool)
+	for class := range toolRequirements {
+		supported[class] = true
+	}
+	for class := range workflowFeatures {
+		supported[class] = true
+	}
+	v.requirements(fields["requirements"], supported, false)
+	v.requirements(fields["hints"], supported, true)
+
+	cwlVersion := ""
+	if versionNode := fields["cwlVersion"]; versionNode != nil {
+		cwlVersion = versionNode.Value
+	}
+
+	info := v.parameters(fields["inputs"], "input", workflowInputFields, false, nil)
+
+	// Every source must name a workflow input or an output of a step
+	known := make(map[string]bool)
+	for inputID := range info.inputs {
+		known[inputID] = true
+	}
+
+	steps := fields["steps"]
+	if steps == nil {
+		v.errorf(node, "steps is required")
+	}
+	type stepNodes struct {
+		key    *yaml.Node
+		fields map[string]*yaml.Node
+	}
+	var stepList []stepNodes
+	for _, pair := range mappingPairs(steps) {
+		stepID := pair[0].Value
+		if pair[1].Kind != yaml.MappingNode {
+			v.errorf(pair[1], "step %s must be an object", stepID)
+			continue
+		}
+		stepFields := v.fields(pair[1], "step "+stepID, workflowStepFields)
+		stepList = append(stepList, stepNodes{key: pair[0], fields: stepFields})
+		if out := stepFields["out"]; out != nil {
+			for _, item := range out.Content {
+				if outputID := stepOutputID(item); outputID != "" {
+					known[stepID+"/"+outputID] = true
+				}
+			}
+		}
+	}
+
+	for _, step := range stepList {
+		v.step(step.key, step.fields, known, supported, cwlVersion)
+	}
+
+	info.outputs = make(map[string]bool)
+	for _, pair := range mappingPairs(fields["outputs"]) {
+		outputID := pair[0].Value
+		info.outputs[outputID] = true
+		if pair[1].Kind != yaml.MappingNode {
+			v.errorf(pair[1], "workflow output %s must be an object", outputID)
+			continue
+		}
+		outputFields := v.fields(pair[1], "workflow output "+outputID, workflowOutputFields)
+		v.typeField(pair[1], outputFields["type"], false)
+		v.sources(outputFields["outputSource"], known, "workflow output "+outputID)
+		v.mergeMethods(outputFields, "workflow output "+outputID)
+	}
+	return info
+}
+
+// step validates a workflow step: its sources, scatter, expressions, and
+// the process it runs
+func (v *validator) step(key *yaml.Node, fields map[string]*yaml.Node, known, supported map[string]bool, cwlVersion string) {
+	stepID := key.Value
+	v.requirements(fields["requirements"], supported, false)
+	v.requirements(fields["hints"], supported, true)
+
+	stepInputs := make(map[string]bool)
+	in := fields["in"]
+	if in == nil {
+		v.errorf(key, "step %s: in is required", stepID)
+	}
+	for _, pair := range mappingPairs(in) {
+		inputID := pair[0].Value
+		stepInputs[inputID] = true
+		where := fmt.Sprintf("input %s of step %s", inputID, stepID)
+		if pair[1].Kind != yaml.MappingNode {
+			v.sources(pair[1], known, where)
+			continue
+		}
+		inputFields := v.fields(pair[1], where, workflowStepInputFields)
+		v.sources(inputFields["source"], known, where)
+		v.mergeMethods(inputFields, where)
+	}
+
+	if scatter := fields["scatter"]; scatter != nil {
+		for _, name := range stringValues(scatter) {
+			if !stepInputs[strings.TrimPrefix(name.Value, "#")] {
+				v.errorf(name, "step %s scatters over %s, which is not one of its inputs", stepID, name.Value)
+			}
+		}
+	}
+	if method := fields["scatterMethod"]; method != nil {
+		switch method.Value {
+		case ScatterDotProduct, ScatterNestedCrossProduct, ScatterFlatCrossProduct:
+		default:
+			v.errorf(method, "unsupported scatterMethod %s on step %s", method.Value, stepID)
+		}
+	}
+
+	// valueFrom and when expressions see the step's inputs
+	v.expressionReferences(in, stepInputs, "step "+stepID)
+	if when := fields["when"]; when != nil {
+		v.expressionReferences(when, stepInputs, "step "+stepID)
+	}
+
+	run := fields["run"]
+	if run == nil {
+		v.errorf(key, "step %s: run is required", stepID)
+		return
+	}
+	info := v.run(run, cwlVersion)
+	if info == nil {
+		return
+	}
+
+	if out := fields["out"]; out != nil {
+		for _, item := range out.Content {
+			outputID := stepOutputID(item)
+			if _, ok := info.outputs[outputID]; outputID != "" && !ok {
+				v.errorf(item, "step %s has no output %s", stepID, outputID)
+			}
+		}
+	}
+	for inputID, required := range info.inputs {
+		if required && !stepInputs[inputID] {
+			v.warnf(key, "step %s does not set required input %s", stepID, inputID)
+		}
+	}
+}
+
+// run validates the process a step runs, from a file relative to the
+// document or inline, and returns its inputs and outputs
+func (v *validator) run(node *yaml.Node, cwlVersion string) *processInfo {
+	switch node.Kind {
+	case yaml.MappingNode:
+		return v.process(node, cwlVersion)
+	case yaml.ScalarNode:
+	default:
+		v.errorf(node, "step run must be a file reference or an inline process")
+		return nil
+	}
+
+	path, fragment := splitFragment(strings.TrimPrefix(node.Value, "file://"))
+	if path == "" {
+		if v.graph == nil {
+			v.errorf(node, "step runs %s, but the document has no $graph", node.Value)
+			return nil
+		}
+		if v.graph[fragment] == nil {
+			v.errorf(node, "step runs %s, which is not in $graph", node.Value)
+			return nil
+		}
+		return v.graphProcess(fragment)
+	}
+	if !filepath.IsAbs(path) {
+		path = filepath.Join(v.baseDir, path)
+	}
+	if v.parser.Root != "" && !withinRoot(path, v.parser.Root) {
+		v.errorf(node, "step runs %s, which is outside the root %s", node.Value, v.parser.Root)
+		return nil
+	}
+	data, err := os.ReadFile(path)
+	if err != nil {
+		v.errorf(node, "failed to open run file %s: %v", node.Value, err)
+		return nil
+	}
+
+	child := &validator{
+		parser:  v.parser,
+		report:  v.report,
+		visited: v.visited,
+		file:    path,
+		baseDir: filepath.Dir(path),
+	}
+	root := child.parseDocument(data)
+	if root == nil {
+		return nil
+	}
+
+	// Report the findings of a file once, however many steps run it
+	if v.visited[filepath.Clean(path)] {
+		discard := *child
+		discard.report = &ValidationReport{}
+		return discard.document(root, fragment)
+	}
+	v.visited[filepath.Clean(path)] = true
+	return child.document(root, fragment)
+}
+
+// fields checks an object's fields against their expected kinds, reports
+// unknown ones and returns the values by name. Fields with a namespace
+// prefix, like "edam:format", are extensions and always allowed.
+func (v *validator) fields(node *yaml.Node, what string, known map[string]fieldKind) map[string]*yaml.Node {
+	values := make(map[string]*yaml.Node)
+	for _, pair := range mappingPairs(node) {
+		name, value := pair[0].Value, pair[1]
+		kind, ok := known[name]
+		if !ok {
+			if strings.Contains(name, ":") {
+				continue
+			}
+			if v.parser.StrictValidation {
+				v.errorf(pair[0], "unknown field %s in %s", name, what)
+			} else {
+				v.warnf(pair[0], "unknown field %s in %s is ignored", name, what)
+			}
+			continue
+		}
+		if !hasKind(value, kind) {
+			v.errorf(value, "%s in %s must be %s", name, what, kind)
+			continue
+		}
+		values[name] = value
+	}
+	return values
+}
+
+// parameters validates the inputs or outputs of a process, calling check
+// with the fields of each, and returns the IDs with whether each is
+// required. Tool outputs may have the stdout and stderr types.
+func (v *validator) parameters(node *yaml.Node, what string, known map[string]fieldKind, streams bool, check func(map[string]*yaml.Node)) *processInfo {
+	info := &processInfo{inputs: make(map[string]bool)}
+	for _, pair := range mappingPairs(node) {
+		id := pair[0].Value
+		if pair[1].Kind != yaml.MappingNode {
+			v.errorf(pair[1], "%s %s must be an object with a type", what, id)
+			info.inputs[id] = false
+			continue
+		}
+		fields := v.fields(pair[1], what+" "+id, known)
+		v.typeField(pair[1], fields["type"], streams)
+		info.inputs[id] = fields["default"] == nil && !optionalType(fields["type"])
+		if check != nil {
+			check(fields)
+		}
+	}
+	return info
+}
+
+// typeField checks a parameter's type
+func (v *validator) typeField(param, node *yaml.Node, streams bool) {
+	if node == nil {
+		v.errorf(param, "type is required")
+		return
+	}
+	v.checkType(node, streams)
+}
+
+// checkType checks a type: a name with optional "?" and "[]" suffixes, a
+// union list, or an array, record or enum schema. Names with a namespace
+// or a "#" refer to user-defined types and are not checked.
+func (v *validator) checkType(node *yaml.Node, streams bool) {
+	switch node.Kind {
+	case yaml.ScalarNode:
+		name := strings.TrimSuffix(node.Value, "?")
+		for strings.HasSuffix(name, "[]") {
+			name = strings.TrimSuffix(name, "[]")
+		}
+		if cwlTypes[name] || strings.ContainsAny(name, "#:") {
+			return
+		}
+		if streams && (name == "stdout" || name == "stderr") && name == node.Value {
+			return
+		}
+		v.errorf(node, "unknown type %s", node.Value)
+
+	case yaml.SequenceNode:
+		for _, item := range node.Content {
+			v.checkType(item, false)
+		}
+
+	case yaml.MappingNode:
+		typeNode := mappingValue(node, "type")
+		if typeNode == nil {
+			v.errorf(node, "type schema must have a type")
+			return
+		}
+		switch typeNode.Value {
+		case "array":
+			if items := mappingValue(node, "items"); items != nil {
+				v.checkType(items, false)
+			} else {
+				v.errorf(node, "array type must have items")
+			}
+		case "enum":
+			if symbols := mappingValue(node, "symbols"); symbols == nil || !hasKind(symbols, kindStringList) {
+				v.errorf(node, "enum type must have a list of symbols")
+			}
+		case "record":
+			fields := mappingValue(node, "fields")
+			if fields == nil {
+				return
+			}
+			switch fields.Kind {
+			case yaml.MappingNode:
+				for _, pair := range mappingPairs(fields) {
+					v.recordFieldType(pair[1])
+				}
+			case yaml.SequenceNode:
+				for _, item := range fields.Content {
+					v.recordFieldType(item)
+				}
+			default:
+				v.errorf(fields, "record fields must be a list or an object")
+			}
+		default:
+			v.errorf(typeNode, "unknown type %s", typeNode.Value)
+		}
+
+	default:
+		v.errorf(node, "type must be a name, a list or a schema")
+	}
+}
+
+// recordFieldType checks the type of a record field, given as a type or
+// as an object with a name or other details besides the type
+func (v *validator) recordFieldType(node *yaml.Node) {
+	if node.Kind == yaml.MappingNode {
+		typeNode := mappingValue(node, "type")
+		if mappingValue(node, "name") != nil || typeNode == nil || !schemaTypes[typeNode.Value] {
+			v.typeField(node, typeNode, false)
+			return
+		}
+	}
+	v.checkType(node, false)
+}
+
+// requirements checks a requirements or hints list. Unsupported
+// requirements are errors; unsupported hints are ignored with a warning,
+// unless their class has a namespace prefix.
+func (v *validator) requirements(node *yaml.Node, supported map[string]bool, hints bool) {
+	if node == nil {
+		return
+	}
+	for _, item := range node.Content {
+		if item.Kind != yaml.MappingNode {
+			v.errorf(item, "requirements and hints must be objects")
+			continue
+		}
+		classNode := mappingValue(item, "class")
+		if classNode == nil {
+			v.errorf(item, "requirement must have a 'class' field")
+			continue
+		}
+		class := classNode.Value
+		if !supported[class] {
+			switch {
+			case !hints:
+				v.errorf(classNode, "unsupported requirement class: %s", class)
+			case !strings.Contains(class, ":"):
+				v.warnf(classNode, "hint %s is not supported and is ignored", class)
+			}
+			continue
+		}
+
+		switch class {
+		case "DockerRequirement", "SingularityRequirement":
+			prefix := "docker"
+			if class == "SingularityRequirement" {
+				prefix = "singularity"
+			}
+			found := false
+			for _, source := range []string{"Pull", "Load", "File", "Import", "ImageId"} {
+				if mappingValue(item, prefix+source) != nil {
+					found = true
+				}
+			}
+			if !found {
+				v.errorf(item, "%s must specify at least one of: %sPull, %sLoad, %sFile, %sImport, or %sImageId", class, prefix, prefix, prefix, prefix, prefix)
+			}
+		case "EnvVarRequirement":
+			envDef := mappingValue(item, "envDef")
+			if envDef == nil || envDef.Kind != yaml.SequenceNode {
+				v.errorf(item, "EnvVarRequirement must have an 'envDef' list")
+				continue
+			}
+			for _, def := range envDef.Content {
+				if def.Kind != yaml.MappingNode || mappingValue(def, "name") == nil || mappingValue(def, "value") == nil {
+					v.errorf(def, "envDef items must be objects with a 'name' and a 'value'")
+				}
+			}
+		case "NetworkAccess":
+			if mappingValue(item, "networkAccess") == nil {
+				v.errorf(item, "NetworkAccess must have a 'networkAccess' field")
+			}
+		case "WorkReuse":
+			if mappingValue(item, "enableReuse") == nil {
+				v.errorf(item, "WorkReuse must have an 'enableReuse' field")
+			}
+		}
+	}
+}
+
+// sources checks that every source names a workflow input or step output
+func (v *validator) sources(node *yaml.Node, known map[string]bool, where string) {
+	if node == nil {
+		return
+	}
+	if !hasKind(node, kindStringOrList) {
+		v.errorf(node, "source of %s must be a string or a list of strings", where)
+		return
+	}
+	for _, source := range stringValues(node) {
+		if !known[strings.TrimPrefix(source.Value, "#")] {
+			v.errorf(source, "%s has unknown source %s", where, source.Value)
+		}
+	}
+}
+
+// mergeMethods checks the linkMerge and pickValue fields of a sink
+func (v *validator) mergeMethods(fields map[string]*yaml.Node, where string) {
+	if method := fields["linkMerge"]; method != nil && !validLinkMerge(method.Value) {
+		v.errorf(method, "unsupported linkMerge %s on %s", method.Value, where)
+	}
+	if method := fields["pickValue"]; method != nil && !validPickValue(method.Value) {
+		v.errorf(method, "unsupported pickValue %s on %s", method.Value, where)
+	}
+}
+
+// expressionReferences reports expressions under node that refer to inputs
+// not in the given set. Inline processes are skipped: they have their own
+// inputs.
+func (v *validator) expressionReferences(node *yaml.Node, inputs map[string]bool, where string) {
+	if node == nil {
+		return
+	}
+	switch node.Kind {
+	case yaml.ScalarNode:
+		if !strings.Contains(node.Value, "$(") && !strings.Contains(node.Value, "${") {
+			return
+		}
+		for _, match := range inputReference.FindAllStringSubmatch(node.Value, -1) {
+			if _, ok := inputs[match[1]]; ok {
+				continue
+			}
+			if where != "" {
+				v.errorf(node, "expression in %s refers to unknown input %s", where, match[1])
+			} else {
+				v.errorf(node, "expression refers to unknown input %s", match[1])
+			}
+		}
+	case yaml.MappingNode:
+		for _, pair := range mappingPairs(node) {
+			if pair[0].Value == "run" || pair[0].Value == "default" {
+				continue
+			}
+			v.expressionReferences(pair[1], inputs, where)
+		}
+	case yaml.SequenceNode:
+		for _, item := range node.Content {
+			v.expressionReferences(item, inputs, where)
+		}
+	}
+}
+
+// mappingPairs returns the key and value nodes of a mapping node
+func mappingPairs(node *yaml.Node) [][2]*yaml.Node {
+	if node == nil || node.Kind != yaml.MappingNode {
+		return nil
+	}
+	result := make([][2]*yaml.Node, 0, len(node.Content)/2)
+	for i := 0; i+1 < len(node.Content); i += 2 {
+		result = append(result, [2]*yaml.Node{node.Content[i], node.Content[i+1]})
+	}
+	return result
+}
+
+// mappingValue returns the value of a mapping node's field, or nil
+func mappingValue(node *yaml.Node, name string) *yaml.Node {
+	for _, pair := range mappingPairs(node) {
+		if pair[0].Value == name {
+			return pair[1]
+		}
+	}
+	return nil
+}
+
+// scalarValue returns the value of a scalar node, or "" for other nodes
+// and nil
+func scalarValue(node *yaml.Node) string {
+	if node == nil || node.Kind != yaml.ScalarNode {
+		return ""
+	}
+	return node.Value
+}
+
+// nodeIDs returns the IDs of nodes in order
+func nodeIDs(nodes map[string]*yaml.Node) []string {
+	ids := make([]string, 0, len(nodes))
+	for id := range nodes {
+		ids = append(ids, id)
+	}
+	sort.Strings(ids)
+	return ids
+}
+
+// hasKind reports whether a node has the expected kind. Null values are
+// treated as absent.
+func hasKind(node *yaml.Node, kind fieldKind) bool {
+	if node.Kind == yaml.ScalarNode && node.Tag == "!!null" {
+		return true
+	}
+	switch kind {
+	case kindString:
+		return node.Kind == yaml.ScalarNode
+	case kindBool:
+		return node.Kind == yaml.ScalarNode && node.Tag == "!!bool"
+	case kindInt:
+		return node.Kind == yaml.ScalarNode && node.Tag == "!!int"
+	case kindMap:
+		return node.Kind == yaml.MappingNode
+	case kindList:
+		return node.Kind == yaml.SequenceNode
+	case kindStringList, kindIntList:
+		if node.Kind != yaml.SequenceNode {
+			return false
+		}
+		item := kindString
+		if kind == kindIntList {
+			item = kindInt
+		}
+		for _, child := range node.Content {
+			if !hasKind(child, item) {
+				return false
+			}
+		}
+		return true
+	case kindStringOrList:
+		return node.Kind == yaml.ScalarNode || hasKind(node, kindStringList)
+	}
+	return true
+}
+
+// stringValues returns the scalar nodes of a string or list of strings
+func stringValues(node *yaml.Node) []*yaml.Node {
+	if node.Kind == yaml.ScalarNode {
+		return []*yaml.Node{node}
+	}
+	return node.Content
+}
+
+// stepOutputID returns the ID of an item of a step's out list
+func stepOutputID(node *yaml.Node) string {
+	if node.Kind == yaml.MappingNode {
+		if id := mappingValue(node, "id"); id != nil {
+			return id.Value
+		}
+		return ""
+	}
+	return node.Value
+}
+
+// optionalType reports whether a type accepts null
+func optionalType(node *yaml.Node) bool {
+	if node == nil {
+		return false
+	}
+	switch node.Kind {
+	case yaml.ScalarNode:
+		return node.Value == "null" || strings.HasSuffix(node.Value, "?") || node.Value == "Any"
+	case yaml.SequenceNode:
+		for _, item := range node.Content {
+			if item.Kind == yaml.ScalarNode && item.Value == "null" {
+				return true
+			}
+		}
+	}
+	return false
+}
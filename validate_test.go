@@ -0,0 +1,220 @@
+package cwlgo
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+// invalidToolCWL has one problem per line marked in validateFindings
+const invalidToolCWL = `cwlVersion: v1.2
+class: CommandLineTool
+baseCommand: [echo, 3]
+inputs:
+  message:
+    type: strin
+    inputBinding:
+      position: first
+outputs:
+  out:
+    type: stdout
+requirements:
+  - class: SchemaDefRequirement
+hints:
+  - class: SoftwareRequirement
+arguments:
+  - valueFrom: $(inputs.mesage)
+colour: blue
+stdout: out.txt
+`
+
+// invalidWorkflowCWL runs invalidToolCWL, saved as tool.cwl
+const invalidWorkflowCWL = `cwlVersion: v1.2
+class: Workflow
+inputs:
+  msg:
+    type: string
+steps:
+  one:
+    run: tool.cwl
+    in:
+      message: msgs
+    out: [out, missing]
+    scatter: nope
+  two:
+    run: nothere.cwl
+    in: {x: one/out}
+    out: [y]
+outputs:
+  result:
+    type: File
+    outputSource: two/z
+`
+
+func TestValidateFile(t *testing.T) {
+	dir := t.TempDir()
+	toolPath := filepath.Join(dir, "tool.cwl")
+	workflowPath := filepath.Join(dir, "workflow.cwl")
+	for path, content := range map[string]string{toolPath: invalidToolCWL, workflowPath: invalidWorkflowCWL} {
+		if err := os.WriteFile(path, []byte(content), 0644); err != nil {
+			t.Fatalf("Failed to write %s: %v", path, err)
+		}
+	}
+
+	type finding struct {
+		severity Severity
+		file     string
+		line     int
+		message  string
+	}
+	expected := []finding{
+		{SeverityError, toolPath, 3, "baseCommand must be a string or array of strings"},
+		{SeverityError, toolPath, 6, "unknown type strin"},
+		{SeverityError, toolPath, 8, "position in inputBinding must be an integer"},
+		{SeverityError, toolPath, 13, "unsupported requirement class: SchemaDefRequirement"},
+		{SeverityWarning, toolPath, 15, "hint SoftwareRequirement is not supported"},
+		{SeverityError, toolPath, 17, "unknown input mesage"},
+		{SeverityError, toolPath, 18, "unknown field colour"},
+		{SeverityError, workflowPath, 10, "unknown source msgs"},
+		{SeverityError, workflowPath, 11, "step one has no output missing"},
+		{SeverityError, workflowPath, 12, "scatters over nope"},
+		{SeverityError, workflowPath, 14, "failed to open run file nothere.cwl"},
+		{SeverityError, workflowPath, 20, "unknown source two/z"},
+	}
+
+	report, err := NewParser().ValidateFile(workflowPath)
+	if err != nil {
+		t.Fatalf("Failed to validate: %v", err)
+	}
+	if report.Valid() {
+		t.Fatal("Expected the workflow to be invalid")
+	}
+	if len(report.Findings) != len(expected) {
+		t.Fatalf("Expected %d findings, got %d: %v", len(expected), len(report.Findings), report.Findings)
+	}
+	for i, want := range expected {
+		got := report.Findings[i]
+		if got.Severity != want.severity || got.File != want.file || got.Line != want.line || !strings.Contains(got.Message, want.message) {
+			t.Errorf("Expected %s at %s:%d containing %q, got %s", want.severity, want.file, want.line, want.message, got)
+		}
+	}
+
+	// Unknown fields are only warnings without StrictValidation
+	parser := &Parser{StrictValidation: false}
+	report, err = parser.ValidateFile(toolPath)
+	if err != nil {
+		t.Fatalf("Failed to validate: %v", err)
+	}
+	for _, f := range report.Findings {
+		if strings.Contains(f.Message, "colour") && f.Severity != SeverityWarning {
+			t.Errorf("Expected unknown field to be a warning, got %s", f)
+		}
+	}
+}
+
+func TestValidateFileValid(t *testing.T) {
+	tests := []struct {
+		name     string
+		content  string
+		findings int
+		valid    bool
+	}{
+		{
+			name: "Valid tool",
+			content: `cwlVersion: v1.2
+class: CommandLineTool
+baseCommand: echo
+requirements:
+  - class: InlineJavascriptRequirement
+hints:
+  - class: cwlgo:Priority
+    priority: 1
+inputs:
+  message:
+    type: string[]?
+    inputBinding: {position: 1}
+  mode:
+    type: {type: enum, symbols: [a, b]}
+outputs:
+  out:
+    type: stdout
+stdout: $(inputs.message)
+`,
+			valid: true,
+		},
+		{
+			name:     "Malformed YAML",
+			content:  "cwlVersion: v1.2\nclass: [CommandLineTool\n",
+			findings: 1,
+		},
+		{
+			name:     "Unknown class",
+			content:  "cwlVersion: v1.2\nclass: Operation\n",
+			findings: 1,
+		},
+		{
+			name: "Step cycle found by the parser",
+			content: `cwlVersion: v1.2
+class: Workflow
+inputs: {}
+outputs: {}
+steps:
+  a:
+    run: {class: ExpressionTool, expression: "$({'x': 1})", inputs: {y: {type: Any}}, outputs: {x: {type: Any}}}
+    in: {y: b/x}
+    out: [x]
+  b:
+    run: {class: ExpressionTool, expression: "$({'x': 1})", inputs: {y: {type: Any}}, outputs: {x: {type: Any}}}
+    in: {y: a/x}
+    out: [x]
+`,
+			findings: 1,
+		},
+	}
+
+	dir := t.TempDir()
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			path := filepath.Join(dir, "doc.cwl")
+			if err := os.WriteFile(path, []byte(tt.content), 0644); err != nil {
+				t.Fatalf("Failed to write document: %v", err)
+			}
+			report, err := NewParser().ValidateFile(path)
+			if err != nil {
+				t.Fatalf("Failed to validate: %v", err)
+			}
+			if report.Valid() != tt.valid {
+				t.Errorf("Expected valid %v, got %v: %v", tt.valid, report.Valid(), report.Findings)
+			}
+			if len(report.Findings) != tt.findings {
+				t.Errorf("Expected %d findings, got %v", tt.findings, report.Findings)
+			}
+		})
+	}
+}
+
+func TestStructFields(t *testing.T) {
+	fields := structFields(WorkflowStep{})
+
+	expected := map[string]fieldKind{
+		"id": kindString, "in": kindMap, "out": kindList, "run": kindAny,
+		"requirements": kindList, "hints": kindList, "label": kindString, "doc": kindString,
+		"scatter": kindStringOrList, "scatterMethod": kindString, "when": kindString,
+	}
+	if len(fields) != len(expected) {
+		t.Errorf("Expected %d fields, got %v", len(expected), fields)
+	}
+	for name, kind := range expected {
+		if fields[name] != kind {
+			t.Errorf("Expected %s to be %s, got %s", name, kind, fields[name])
+		}
+	}
+
+	if kind := structFields(CommandLineTool{})["successCodes"]; kind != kindIntList {
+		t.Errorf("Expected successCodes to be %s, got %s", kindIntList, kind)
+	}
+	if kind := structFields(CommandLineBinding{})["separate"]; kind != kindBool {
+		t.Errorf("Expected separate to be %s, got %s", kindBool, kind)
+	}
+}
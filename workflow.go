@@ -0,0 +1,777 @@
+package cwlgo
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync"
+
+	"gopkg.in/yaml.v3"
+)
+
+// Process is a CWL document that can be run by a workflow step
+type Process interface {
+	IsProcess() bool
+}
+
+// IsProcess implements the Process interface
+func (t *CommandLineTool) IsProcess() bool {
+	return true
+}
+
+// Workflow represents a CWL Workflow document
+type Workflow struct {
+	// Required fields
+	CWLVersion string                  `yaml:"cwlVersion" json:"cwlVersion"`
+	Class      string                  `yaml:"class" json:"class"` // Must be "Workflow"
+	Steps      map[string]WorkflowStep `yaml:"steps" json:"steps"`
+
+	// Optional fields
+	Inputs       map[string]WorkflowInputParameter  `yaml:"inputs" json:"inputs"`
+	Outputs      map[string]WorkflowOutputParameter `yaml:"outputs" json:"outputs"`
+	ID           string                             `yaml:"id,omitempty" json:"id,omitempty"`
+	Requirements []map[string]interface{}           `yaml:"requirements,omitempty" json:"requirements,omitempty"`
+	Hints        []map[string]interface{}           `yaml:"hints,omitempty" json:"hints,omitempty"`
+	Label        string                             `yaml:"label,omitempty" json:"label,omitempty"`
+	Doc          string                             `yaml:"doc,omitempty" json:"doc,omitempty"`
+
+	// Schema-salad metadata
+	Namespaces map[string]string `yaml:"$namespaces,omitempty" json:"$namespaces,omitempty"` // Prefix -> namespace IRI
+	Schemas    []string          `yaml:"$schemas,omitempty" json:"$schemas,omitempty"`       // Ontology files used for format checking
+}
+
+// IsProcess implements the Process interface
+func (w *Workflow) IsProcess() bool {
+	return true
+}
+
+// WorkflowInputParameter represents an input parameter for a Workflow
+type WorkflowInputParameter struct {
+	ID      string      `yaml:"id,omitempty" json:"id,omitempty"`
+	Label   string      `yaml:"label,omitempty" json:"label,omitempty"`
+	Doc     string      `yaml:"doc,omitempty" json:"doc,omitempty"`
+	Type    interface{} `yaml:"type" json:"type"`
+	Default interface{} `yaml:"default,omitempty" json:"default,omitempty"`
+	Format  interface{} `yaml:"format,omitempty" json:"format,omitempty"`
+}
+
+// WorkflowOutputParameter represents an output parameter for a Workflow
+type WorkflowOutputParameter struct {
+	ID           string      `yaml:"id,omitempty" json:"id,omitempty"`
+	Label        string      `yaml:"label,omitempty" json:"label,omitempty"`
+	Doc          string      `yaml:"doc,omitempty" json:"doc,omitempty"`
+	Type         interface{} `yaml:"type" json:"type"`
+	Format       interface{} `yaml:"format,omitempty" json:"format,omitempty"`
+	OutputSource interface{} `yaml:"outputSource,omitempty" json:"outputSource,omitempty" cwl:"stringOrList"` // String or []string
+	LinkMerge    string      `yaml:"linkMerge,omitempty" json:"linkMerge,omitempty"`
+	PickValue    string      `yaml:"pickValue,omitempty" json:"pickValue,omitempty"`
+}
+
+// WorkflowStep represents a step of a Workflow
+type WorkflowStep struct {
+	ID            string                       `yaml:"id,omitempty" json:"id,omitempty"`
+	In            map[string]WorkflowStepInput `yaml:"in" json:"in"`
+	Out           []interface{}                `yaml:"out" json:"out"` // Output IDs as strings or {id: ...} objects
+	Run           interface{}                  `yaml:"run" json:"run"` // Path to a document or an inline process
+	Requirements  []map[string]interface{}     `yaml:"requirements,omitempty" json:"requirements,omitempty"`
+	Hints         []map[string]interface{}     `yaml:"hints,omitempty" json:"hints,omitempty"`
+	Label         string                       `yaml:"label,omitempty" json:"label,omitempty"`
+	Doc           string                       `yaml:"doc,omitempty" json:"doc,omitempty"`
+	Scatter       interface{}                  `yaml:"scatter,omitempty" json:"scatter,omitempty" cwl:"stringOrList"` // String or []string
+	ScatterMethod string                       `yaml:"scatterMethod,omitempty" json:"scatterMethod,omitempty"`
+	When          string                       `yaml:"when,omitempty" json:"when,omitempty"` // Expression deciding whether the step runs
+
+	// Process is the document Run refers to, loaded by the parser
+	Process Process `yaml:"-" json:"-"`
+}
+
+// WorkflowStepInput represents an input of a workflow step
+type WorkflowStepInput struct {
+	ID        string      `yaml:"id,omitempty" json:"id,omitempty"`
+	Source    interface{} `yaml:"source,omitempty" json:"source,omitempty" cwl:"stringOrList"` // String or []string
+	LinkMerge string      `yaml:"linkMerge,omitempty" json:"linkMerge,omitempty"`
+	PickValue string      `yaml:"pickValue,omitempty" json:"pickValue,omitempty"`
+	Default   interface{} `yaml:"default,omitempty" json:"default,omitempty"`     // Used when the sources yield null
+	ValueFrom string      `yaml:"valueFrom,omitempty" json:"valueFrom,omitempty"` // Expression computing the value
+}
+
+// UnmarshalYAML accepts the "in: {x: source}" shorthand
+func (in *WorkflowStepInput) UnmarshalYAML(value *yaml.Node) error {
+	if value.Kind != yaml.MappingNode {
+		return value.Decode(&in.Source)
+	}
+	type plain WorkflowStepInput
+	return value.Decode((*plain)(in))
+}
+
+// UnmarshalJSON accepts the "in: {x: source}" shorthand
+func (in *WorkflowStepInput) UnmarshalJSON(data []byte) error {
+	if trimmed := strings.TrimSpace(string(data)); !strings.HasPrefix(trimmed, "{") {
+		return json.Unmarshal(data, &in.Source)
+	}
+	type plain WorkflowStepInput
+	return json.Unmarshal(data, (*plain)(in))
+}
+
+// linkMerge methods
+const (
+	MergeNested    = "merge_nested"
+	MergeFlattened = "merge_flattened"
+)
+
+// workflowFeatures are requirements that only affect how a workflow is
+// run and are not inherited by the tools of its steps
+var workflowFeatures = map[string]bool{
+	"ScatterFeatureRequirement":       true,
+	"SubworkflowFeatureRequirement":   true,
+	"MultipleInputFeatureRequirement": true,
+	"StepInputExpressionRequirement":  true,
+}
+
+// ExecuteProcess runs a CommandLineTool, Workflow or ExpressionTool and
+// returns its outputs
+func (e *Executor) ExecuteProcess(ctx context.Context, process Process, inputs map[string]interface{}) (map[string]interface{}, error) {
+	switch p := process.(type) {
+	case *CommandLineTool:
+		result, err := e.Execute(ctx, p, inputs)
+		if err != nil {
+			return nil, err
+		}
+		return result.Outputs, nil
+	case *Workflow:
+		result, err := e.ExecuteWorkflow(ctx, p, inputs)
+		if err != nil {
+			return nil, err
+		}
+		return result.Outputs, nil
+	case *ExpressionTool:
+		return e.ExecuteExpressionTool(ctx, p, inputs)
+	}
+	return nil, &CWLError{
+		Err:     ErrUnsupported,
+		Message: fmt.Sprintf("unsupported process type: %T", process),
+	}
+}
+
+// WorkflowResult contains the results of executing a Workflow
+type WorkflowResult struct {
+	RunID     string                 // ID under which the run can be resumed
+	Outputs   map[string]interface{} // Output ID -> value
+	OutputDir string                 // Directory holding the outputs of all steps
+	CacheErrs []error                // Jobs whose outputs could not be stored in Executor.Cache
+}
+
+// workflowRun holds the state shared by the jobs of one workflow execution
+type workflowRun struct {
+	executor *Executor
+	dir      string    // Run directory, which job IDs are relative to
+	state    *runState // Checkpointed progress of the run
+
+	cacheErrs []error // Failures to store job outputs in the call cache
+	cacheMu   sync.Mutex
+}
+
+// ExecuteWorkflow executes a Workflow with the given inputs under a new run
+// ID. Steps run in dependency order, each job in its own directory under
+// the run directory in ./output. Errors of the run name its ID; to know the
+// ID before the run starts, pass one from NewRunID to ResumeWorkflow.
+func (e *Executor) ExecuteWorkflow(ctx context.Context, wf *Workflow, inputs map[string]interface{}) (*WorkflowResult, error) {
+	return e.ResumeWorkflow(ctx, wf, inputs, NewRunID())
+}
+
+// ResumeWorkflow executes a Workflow under the given run ID, in the run
+// directory ./output/workflow-<runID>. Progress is checkpointed there as
+// jobs finish; if an earlier attempt of the run was interrupted, only the
+// jobs it did not complete are run again.
+func (e *Executor) ResumeWorkflow(ctx context.Context, wf *Workflow, inputs map[string]interface{}, runID string) (*WorkflowResult, error) {
+	if !validRunID.MatchString(runID) {
+		return nil, &CWLError{Err: ErrExecution, Message: fmt.Sprintf("invalid run ID: %q", runID)}
+	}
+
+	cwd, err := os.Getwd()
+	if err != nil {
+		return nil, &CWLError{Err: err, Message: "failed to get current working directory"}
+	}
+
+	outputRoot := filepath.Join(cwd, "output")
+	if err := os.MkdirAll(outputRoot, 0755); err != nil {
+		return nil, &CWLError{Err: err, Message: "failed to create output directory"}
+	}
+	runDir := filepath.Join(outputRoot, "workflow-"+runID)
+	if err := os.MkdirAll(runDir, 0755); err != nil {
+		return nil, &CWLError{Err: err, Message: "failed to create workflow run directory"}
+	}
+
+	// Jobs run in their own directories, so relative paths must be resolved now
+	resolved := make(map[string]interface{}, len(inputs))
+	for inputID, value := range inputs {
+		resolved[inputID] = absolutizeFiles(value, cwd)
+	}
+
+	digest, err := runDigest(wf, resolved)
+	if err != nil {
+		return nil, err
+	}
+	state, err := openRunState(runDir, runID, digest)
+	if err != nil {
+		return nil, err
+	}
+	defer state.close()
+
+	run := &workflowRun{executor: e, dir: runDir, state: state}
+	outputs, err := run.execute(ctx, wf, resolved, runDir)
+	if err != nil {
+		return nil, &CWLError{Err: err, Message: fmt.Sprintf("run %s failed", runID)}
+	}
+
+	return &WorkflowResult{RunID: runID, Outputs: outputs, OutputDir: runDir, CacheErrs: run.cacheErrs}, nil
+}
+
+// execute runs each step of a workflow as soon as the steps it depends on
+// are done, so that independent steps run in parallel as the executor's
+// scheduler allows, and collects its outputs
+func (r *workflowRun) execute(ctx context.Context, wf *Workflow, inputs map[string]interface{}, dir string) (map[string]interface{}, error) {
+	// values holds workflow inputs by ID and step outputs by "step/output"
+	values := make(map[string]interface{})
+	for inputID, param := range wf.Inputs {
+		value, ok := inputs[inputID]
+		if !ok || value == nil {
+			value = param.Default
+		}
+		values[inputID] = value
+	}
+
+	order, err := stepOrder(wf)
+	if err != nil {
+		return nil, err
+	}
+
+	ctx, cancel := context.WithCancel(ctx)
+	defer cancel()
+
+	// done is closed once a step's outputs are in values
+	done := make(map[string]chan struct{}, len(order))
+	for _, stepID := range order {
+		done[stepID] = make(chan struct{})
+	}
+
+	var (
+		wg       sync.WaitGroup
+		mu       sync.Mutex
+		firstErr error
+	)
+	fail := func(err error) {
+		mu.Lock()
+		defer mu.Unlock()
+		// The first failure cancels the remaining steps
+		if firstErr == nil {
+			firstErr = err
+			cancel()
+		}
+	}
+
+	for _, stepID := range order {
+		wg.Add(1)
+		go func(stepID string, step WorkflowStep) {
+			defer wg.Done()
+
+			for _, dep := range stepDependencies(step) {
+				select {
+				case <-done[dep]:
+				case <-ctx.Done():
+					return
+				}
+			}
+
+			mu.Lock()
+			stepInputs, err := resolveStepInputs(wf, stepID, step, values)
+			mu.Unlock()
+			if err != nil {
+				fail(err)
+				return
+			}
+
+			outputs, err := r.runStep(ctx, wf, stepID, step, stepInputs, dir)
+			if err != nil {
+				fail(err)
+				return
+			}
+
+			mu.Lock()
+			for _, outputID := range stepOutputIDs(step) {
+				values[stepID+"/"+outputID] = outputs[outputID]
+			}
+			mu.Unlock()
+			close(done[stepID])
+		}(stepID, wf.Steps[stepID])
+	}
+	wg.Wait()
+
+	if firstErr != nil {
+		return nil, firstErr
+	}
+	if err := ctx.Err(); err != nil {
+		return nil, &CWLError{Err: err, Message: "workflow was cancelled"}
+	}
+
+	outputs := make(map[string]interface{}, len(wf.Outputs))
+	for outputID, param := range wf.Outputs {
+		value, err := gatherSources(sourceIDs(param.OutputSource), values, param.LinkMerge, param.PickValue)
+		if err != nil {
+			return nil, &CWLError{
+				Err:     err,
+				Message: fmt.Sprintf("failed to collect workflow output %s", outputID),
+			}
+		}
+		outputs[outputID] = value
+	}
+
+	return outputs, nil
+}
+
+// runStep runs a step once, or once per combination of its scattered inputs
+func (r *workflowRun) runStep(ctx context.Context, wf *Workflow, stepID string, step WorkflowStep, inputs map[string]interface{}, dir string) (map[string]interface{}, error) {
+	if len(scatterInputs(step)) > 0 {
+		if !hasRequirement(wf, step, "ScatterFeatureRequirement") {
+			return nil, &CWLError{
+				Err:     ErrExecution,
+				Message: fmt.Sprintf("step %s uses scatter but ScatterFeatureRequirement is not declared", stepID),
+			}
+		}
+		return r.runScatter(ctx, wf, stepID, step, inputs, dir)
+	}
+
+	return r.runJob(ctx, wf, step, inputs, filepath.Join(dir, stepID))
+}
+
+// runJob runs the process of a step with the given inputs in jobDir, unless
+// an earlier attempt of the run completed it, and checkpoints its outputs
+func (r *workflowRun) runJob(ctx context.Context, wf *Workflow, step WorkflowStep, inputs map[string]interface{}, jobDir string) (map[string]interface{}, error) {
+	job, err := filepath.Rel(r.dir, jobDir)
+	if err != nil {
+		return nil, &CWLError{Err: err, Message: fmt.Sprintf("job directory %s is outside the run directory", jobDir)}
+	}
+
+	if outputs, ok := r.state.completed(job); ok {
+		return outputs, nil
+	}
+
+	interrupted, err := r.state.start(job)
+	if err != nil {
+		return nil, err
+	}
+	if _, ok := step.Process.(*CommandLineTool); ok && interrupted {
+		// Drop the partial outputs of the interrupted attempt. Subworkflow
+		// directories are kept for the jobs within them that completed.
+		if err := os.RemoveAll(jobDir); err != nil {
+			return nil, &CWLError{Err: err, Message: fmt.Sprintf("failed to clear job directory %s", jobDir)}
+		}
+	}
+
+	outputs, err := r.runProcess(ctx, wf, step, inputs, jobDir)
+	if err != nil {
+		return nil, err
+	}
+	if err := r.state.finish(job, outputs); err != nil {
+		return nil, err
+	}
+	return outputs, nil
+}
+
+// runProcess runs the process of a step with the given inputs in jobDir.
+// Input valueFrom expressions are evaluated first; a job whose when
+// condition is then false is skipped and has null outputs.
+func (r *workflowRun) runProcess(ctx context.Context, wf *Workflow, step WorkflowStep, inputs map[string]interface{}, jobDir string) (map[string]interface{}, error) {
+	inputs, err := evaluateValueFrom(wf, step, inputs)
+	if err != nil {
+		return nil, err
+	}
+
+	if step.When != "" {
+		run, err := evaluateWhen(wf, step, inputs)
+		if err != nil {
+			return nil, err
+		}
+		if !run {
+			return map[string]interface{}{}, nil
+		}
+	}
+
+	switch process := step.Process.(type) {
+	case *CommandLineTool:
+		tool := *process
+		tool.Requirements = withoutWorkflowFeatures(
+			inheritRequirements(process.Requirements, wf.Requirements, step.Requirements))
+
+		// Wait until the tool's cores and RAM are free
+		release, err := r.executor.Scheduler().Acquire(ctx, jobRequest(filepath.Base(jobDir), &tool, stepPriority(step)))
+		if err != nil {
+			return nil, err
+		}
+		defer release()
+
+		// Only the tool's own inputs are passed on
+		toolInputs := make(map[string]interface{}, len(tool.Inputs))
+		for inputID := range tool.Inputs {
+			if value, ok := inputs[inputID]; ok && value != nil {
+				toolInputs[inputID] = value
+			}
+		}
+
+		execCtx, err := NewExecutionContext(jobDir)
+		if err != nil {
+			return nil, err
+		}
+		defer execCtx.Cleanup()
+
+		// Tools run in their output directory
+		execCtx.WorkingDir = execCtx.OutputDir
+
+		result, err := r.executor.execute(ctx, &tool, toolInputs, execCtx)
+		if err != nil {
+			return nil, &CWLError{Err: err, Message: fmt.Sprintf("step %s failed", step.ID)}
+		}
+		if result.CacheErr != nil {
+			r.cacheMu.Lock()
+			r.cacheErrs = append(r.cacheErrs, &CWLError{Err: result.CacheErr, Message: fmt.Sprintf("step %s", step.ID)})
+			r.cacheMu.Unlock()
+		}
+		return result.Outputs, nil
+
+	case *ExpressionTool:
+		tool := *process
+		tool.Requirements = withoutWorkflowFeatures(
+			inheritRequirements(process.Requirements, wf.Requirements, step.Requirements))
+
+		toolInputs := make(map[string]interface{}, len(tool.Inputs))
+		for inputID := range tool.Inputs {
+			if value, ok := inputs[inputID]; ok && value != nil {
+				toolInputs[inputID] = value
+			}
+		}
+
+		outputs, err := r.executor.ExecuteExpressionTool(ctx, &tool, toolInputs)
+		if err != nil {
+			return nil, &CWLError{Err: err, Message: fmt.Sprintf("step %s failed", step.ID)}
+		}
+		return outputs, nil
+
+	case *Workflow:
+		if !hasRequirement(wf, step, "SubworkflowFeatureRequirement") {
+			return nil, &CWLError{
+				Err:     ErrExecution,
+				Message: fmt.Sprintf("step %s runs a Workflow but SubworkflowFeatureRequirement is not declared", step.ID),
+			}
+		}
+
+		subworkflow := *process
+		subworkflow.Requirements = inheritRequirements(process.Requirements, wf.Requirements, step.Requirements)
+
+		outputs, err := r.execute(ctx, &subworkflow, inputs, jobDir)
+		if err != nil {
+			return nil, &CWLError{Err: err, Message: fmt.Sprintf("step %s failed", step.ID)}
+		}
+		return outputs, nil
+
+	case nil:
+		return nil, &CWLError{
+			Err:     ErrExecution,
+			Message: fmt.Sprintf("step %s has no process to run", step.ID),
+		}
+
+	default:
+		return nil, &CWLError{
+			Err:     ErrExecution,
+			Message: fmt.Sprintf("step %s runs an unsupported process type %T", step.ID, process),
+		}
+	}
+}
+
+// resolveStepInputs returns the values of a step's inputs from their
+// sources, falling back to the input's default when they yield null
+func resolveStepInputs(wf *Workflow, stepID string, step WorkflowStep, values map[string]interface{}) (map[string]interface{}, error) {
+	inputs := make(map[string]interface{}, len(step.In))
+	for inputID, in := range step.In {
+		sources := sourceIDs(in.Source)
+		if len(sources) > 1 && !hasRequirement(wf, step, "MultipleInputFeatureRequirement") {
+			return nil, &CWLError{
+				Err:     ErrExecution,
+				Message: fmt.Sprintf("input %s of step %s has multiple sources but MultipleInputFeatureRequirement is not declared", inputID, stepID),
+			}
+		}
+
+		value, err := gatherSources(sources, values, in.LinkMerge, in.PickValue)
+		if err != nil {
+			return nil, &CWLError{
+				Err:     err,
+				Message: fmt.Sprintf("failed to collect input %s of step %s", inputID, stepID),
+			}
+		}
+		if value == nil {
+			value = in.Default
+		}
+		inputs[inputID] = value
+	}
+	return inputs, nil
+}
+
+// gatherSources returns the value of an input or output from its sources.
+// Multiple sources, or a single source with an explicit linkMerge, are
+// merged into a list, then pickValue is applied.
+func gatherSources(sources []string, values map[string]interface{}, linkMerge, pickValue string) (interface{}, error) {
+	var value interface{}
+	if len(sources) == 1 && linkMerge == "" {
+		value = values[sources[0]]
+	} else if len(sources) > 0 {
+		merged := []interface{}{}
+		for _, source := range sources {
+			switch linkMerge {
+			case "", MergeNested:
+				merged = append(merged, values[source])
+			case MergeFlattened:
+				if items, ok := values[source].([]interface{}); ok {
+					merged = append(merged, items...)
+				} else {
+					merged = append(merged, values[source])
+				}
+			default:
+				return nil, &CWLError{
+					Err:     ErrInvalidCWL,
+					Message: fmt.Sprintf("unsupported linkMerge: %s", linkMerge),
+				}
+			}
+		}
+		value = merged
+	}
+
+	if pickValue == "" {
+		return value, nil
+	}
+	return applyPickValue(value, pickValue)
+}
+
+// evaluateValueFrom returns a step's inputs with their valueFrom
+// expressions applied. Each expression sees the input's value as self and
+// the values of all inputs before any valueFrom as inputs.
+func evaluateValueFrom(wf *Workflow, step WorkflowStep, inputs map[string]interface{}) (map[string]interface{}, error) {
+	var evaluated map[string]interface{}
+
+	for inputID, in := range step.In {
+		if in.ValueFrom == "" {
+			continue
+		}
+		if !hasRequirement(wf, step, "StepInputExpressionRequirement") {
+			return nil, &CWLError{
+				Err:     ErrExecution,
+				Message: fmt.Sprintf("input %s of step %s uses valueFrom but StepInputExpressionRequirement is not declared", inputID, step.ID),
+			}
+		}
+
+		if evaluated == nil {
+			evaluated = make(map[string]interface{}, len(inputs))
+			for k, v := range inputs {
+				evaluated[k] = v
+			}
+		}
+
+		exprCtx := &ExpressionContext{
+			Inputs:     inputs,
+			Self:       inputs[inputID],
+			JavaScript: hasRequirement(wf, step, "InlineJavascriptRequirement"),
+		}
+		value, err := exprCtx.EvaluateString(in.ValueFrom)
+		if err != nil {
+			return nil, &CWLError{
+				Err:     err,
+				Message: fmt.Sprintf("failed to evaluate valueFrom of input %s of step %s", inputID, step.ID),
+			}
+		}
+		evaluated[inputID] = value
+	}
+
+	if evaluated == nil {
+		return inputs, nil
+	}
+	return evaluated, nil
+}
+
+// stepOrder returns the step IDs of a workflow so that every step comes
+// after the steps it takes inputs from
+func stepOrder(wf *Workflow) ([]string, error) {
+	deps := make(map[string]map[string]bool, len(wf.Steps))
+	for stepID, step := range wf.Steps {
+		deps[stepID] = make(map[string]bool)
+		for _, dep := range stepDependencies(step) {
+			deps[stepID][dep] = true
+		}
+	}
+
+	var order []string
+	done := make(map[string]bool, len(wf.Steps))
+	for len(order) < len(wf.Steps) {
+		var ready []string
+		for stepID, stepDeps := range deps {
+			if done[stepID] {
+				continue
+			}
+			blocked := false
+			for dep := range stepDeps {
+				if !done[dep] {
+					blocked = true
+					break
+				}
+			}
+			if !blocked {
+				ready = append(ready, stepID)
+			}
+		}
+		if len(ready) == 0 {
+			return nil, &CWLError{
+				Err:     ErrInvalidCWL,
+				Message: "workflow steps have a dependency cycle",
+			}
+		}
+		sort.Strings(ready)
+		for _, stepID := range ready {
+			done[stepID] = true
+		}
+		order = append(order, ready...)
+	}
+
+	return order, nil
+}
+
+// stepDependencies returns the IDs of the steps whose outputs a step reads
+func stepDependencies(step WorkflowStep) []string {
+	seen := make(map[string]bool)
+	var deps []string
+	for _, in := range step.In {
+		for _, source := range sourceIDs(in.Source) {
+			i := strings.Index(source, "/")
+			if i < 0 || seen[source[:i]] {
+				continue
+			}
+			seen[source[:i]] = true
+			deps = append(deps, source[:i])
+		}
+	}
+	sort.Strings(deps)
+	return deps
+}
+
+// sourceIDs returns the IDs referenced by a source or outputSource field
+func sourceIDs(source interface{}) []string {
+	var ids []string
+	switch s := source.(type) {
+	case string:
+		ids = append(ids, strings.TrimPrefix(s, "#"))
+	case []interface{}:
+		for _, item := range s {
+			if str, ok := item.(string); ok {
+				ids = append(ids, strings.TrimPrefix(str, "#"))
+			}
+		}
+	case []string:
+		for _, str := range s {
+			ids = append(ids, strings.TrimPrefix(str, "#"))
+		}
+	}
+	return ids
+}
+
+// stepOutputIDs returns the output IDs listed in a step's out field
+func stepOutputIDs(step WorkflowStep) []string {
+	var ids []string
+	for _, out := range step.Out {
+		switch o := out.(type) {
+		case string:
+			ids = append(ids, o)
+		case map[string]interface{}:
+			if id, ok := o["id"].(string); ok {
+				ids = append(ids, id)
+			}
+		}
+	}
+	return ids
+}
+
+// hasRequirement reports whether a workflow or step declares a requirement
+func hasRequirement(wf *Workflow, step WorkflowStep, class string) bool {
+	for _, reqs := range [][]map[string]interface{}{wf.Requirements, step.Requirements} {
+		for _, req := range reqs {
+			if req["class"] == class {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+// inheritRequirements returns a process's requirements extended with those
+// of the enclosing workflow and step, which the process does not itself
+// declare. Step requirements take precedence over workflow requirements.
+func inheritRequirements(own []map[string]interface{}, inherited ...[]map[string]interface{}) []map[string]interface{} {
+	declared := make(map[interface{}]bool)
+	for _, req := range own {
+		declared[req["class"]] = true
+	}
+
+	requirements := append([]map[string]interface{}{}, own...)
+	for i := len(inherited) - 1; i >= 0; i-- {
+		for _, req := range inherited[i] {
+			class, _ := req["class"].(string)
+			if declared[class] {
+				continue
+			}
+			declared[class] = true
+			requirements = append(requirements, req)
+		}
+	}
+	return requirements
+}
+
+// withoutWorkflowFeatures drops the requirements that only apply to
+// workflows, before they are handed to a tool
+func withoutWorkflowFeatures(requirements []map[string]interface{}) []map[string]interface{} {
+	var filtered []map[string]interface{}
+	for _, req := range requirements {
+		if class, _ := req["class"].(string); !workflowFeatures[class] {
+			filtered = append(filtered, req)
+		}
+	}
+	return filtered
+}
+
+// absolutizeFiles returns a copy of value with relative File and Directory
+// paths resolved against baseDir
+func absolutizeFiles(value interface{}, baseDir string) interface{} {
+	switch v := value.(type) {
+	case map[string]interface{}:
+		class, _ := v["class"].(string)
+		if class != "File" && class != "Directory" {
+			return v
+		}
+		path, _ := v["path"].(string)
+		if path == "" || filepath.IsAbs(path) {
+			return v
+		}
+		file := make(map[string]interface{}, len(v))
+		for k, item := range v {
+			file[k] = item
+		}
+		file["path"] = filepath.Join(baseDir, path)
+		return file
+
+	case []interface{}:
+		items := make([]interface{}, len(v))
+		for i, item := range v {
+			items[i] = absolutizeFiles(item, baseDir)
+		}
+		return items
+	}
+	return value
+}
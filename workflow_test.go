@@ -0,0 +1,331 @@
+package cwlgo
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+const echoToolCWL = `cwlVersion: v1.2
+class: CommandLineTool
+baseCommand: echo
+inputs:
+  message:
+    type: string
+    inputBinding:
+      position: 1
+outputs:
+  output:
+    type: stdout
+stdout: output.txt
+`
+
+const scatterWorkflowCWL = `cwlVersion: v1.2
+class: Workflow
+requirements:
+  - class: ScatterFeatureRequirement
+inputs:
+  messages:
+    type: string[]
+outputs:
+  greetings:
+    type: File[]
+    outputSource: echo/output
+steps:
+  echo:
+    run: echo.cwl
+    scatter: message
+    in:
+      message: messages
+    out: [output]
+`
+
+// enterTempDir makes a new temporary directory the current directory for
+// the rest of the test, so that workflow runs under ./output are removed
+func enterTempDir(t *testing.T) {
+	t.Helper()
+	cwd, err := os.Getwd()
+	if err != nil {
+		t.Fatalf("Failed to get current directory: %v", err)
+	}
+	if err := os.Chdir(t.TempDir()); err != nil {
+		t.Fatalf("Failed to enter temporary directory: %v", err)
+	}
+	t.Cleanup(func() {
+		if err := os.Chdir(cwd); err != nil {
+			t.Fatalf("Failed to restore current directory: %v", err)
+		}
+	})
+}
+
+func writeWorkflowFiles(t *testing.T, files map[string]string) string {
+	dir := t.TempDir()
+	for name, content := range files {
+		if err := os.WriteFile(filepath.Join(dir, name), []byte(content), 0644); err != nil {
+			t.Fatalf("Failed to write %s: %v", name, err)
+		}
+	}
+	return dir
+}
+
+func TestParseWorkflowFile(t *testing.T) {
+	dir := writeWorkflowFiles(t, map[string]string{
+		"echo.cwl":     echoToolCWL,
+		"workflow.cwl": scatterWorkflowCWL,
+		"broken.cwl":   strings.Replace(scatterWorkflowCWL, "message: messages", "message: missing", 1),
+	})
+
+	parser := NewParser()
+
+	wf, err := parser.ParseWorkflowFile(filepath.Join(dir, "workflow.cwl"))
+	if err != nil {
+		t.Fatalf("Failed to parse workflow: %v", err)
+	}
+
+	step, ok := wf.Steps["echo"]
+	if !ok {
+		t.Fatalf("Expected step echo, got %v", wf.Steps)
+	}
+	if _, ok := step.Process.(*CommandLineTool); !ok {
+		t.Errorf("Expected step to run a CommandLineTool, got %T", step.Process)
+	}
+	if sources := sourceIDs(step.In["message"].Source); !reflect.DeepEqual(sources, []string{"messages"}) {
+		t.Errorf("Expected source shorthand to be read, got %v", sources)
+	}
+
+	if _, err := parser.ParseWorkflowFile(filepath.Join(dir, "broken.cwl")); err == nil {
+		t.Error("Expected error for unknown source, got nil")
+	}
+}
+
+func TestExecuteWorkflowScatter(t *testing.T) {
+	enterTempDir(t)
+
+	dir := writeWorkflowFiles(t, map[string]string{
+		"echo.cwl":     echoToolCWL,
+		"workflow.cwl": scatterWorkflowCWL,
+	})
+
+	wf, err := NewParser().ParseWorkflowFile(filepath.Join(dir, "workflow.cwl"))
+	if err != nil {
+		t.Fatalf("Failed to parse workflow: %v", err)
+	}
+
+	executor := NewExecutor()
+	executor.MaxCores = 2
+
+	result, err := executor.ExecuteWorkflow(context.Background(), wf, map[string]interface{}{
+		"messages": []interface{}{"a", "b", "c"},
+	})
+	if err != nil {
+		t.Fatalf("Failed to execute workflow: %v", err)
+	}
+
+	greetings, ok := result.Outputs["greetings"].([]interface{})
+	if !ok || len(greetings) != 3 {
+		t.Fatalf("Expected 3 greetings, got %v", result.Outputs["greetings"])
+	}
+
+	for i, expected := range []string{"a", "b", "c"} {
+		file, ok := greetings[i].(map[string]interface{})
+		if !ok {
+			t.Fatalf("Expected File object, got %v", greetings[i])
+		}
+		data, err := os.ReadFile(file["path"].(string))
+		if err != nil {
+			t.Fatalf("Failed to read output: %v", err)
+		}
+		if strings.TrimSpace(string(data)) != expected {
+			t.Errorf("Expected greeting %d to be %q, got %q", i, expected, data)
+		}
+	}
+}
+
+func TestScatterJobs(t *testing.T) {
+	inputs := map[string]interface{}{
+		"a":     []interface{}{1, 2},
+		"b":     []interface{}{"x", "y", "z"},
+		"fixed": "f",
+	}
+
+	tests := []struct {
+		name        string
+		method      string
+		jobs        int
+		dims        []int
+		expectError bool
+	}{
+		{name: "Dot product of unequal arrays", method: ScatterDotProduct, expectError: true},
+		{name: "Nested cross product", method: ScatterNestedCrossProduct, jobs: 6, dims: []int{2, 3}},
+		{name: "Flat cross product", method: ScatterFlatCrossProduct, jobs: 6, dims: []int{6}},
+		{name: "Unknown method", method: "zip", expectError: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			step := WorkflowStep{Scatter: []interface{}{"a", "b"}, ScatterMethod: tt.method}
+
+			jobs, dims, err := scatterJobs("step", step, inputs)
+			if tt.expectError {
+				if err == nil {
+					t.Error("Expected error, got nil")
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("Failed to expand scatter: %v", err)
+			}
+
+			if len(jobs) != tt.jobs || !reflect.DeepEqual(dims, tt.dims) {
+				t.Errorf("Expected %d jobs with dims %v, got %d with %v", tt.jobs, tt.dims, len(jobs), dims)
+			}
+			if jobs[1]["a"] != 1 || jobs[1]["b"] != "y" || jobs[1]["fixed"] != "f" {
+				t.Errorf("Expected second job a=1 b=y fixed=f, got %v", jobs[1])
+			}
+		})
+	}
+
+	// Nested outputs follow the order of the scattered inputs
+	nested := reshape([]interface{}{1, 2, 3, 4, 5, 6}, []int{2, 3})
+	expected := []interface{}{[]interface{}{1, 2, 3}, []interface{}{4, 5, 6}}
+	if !reflect.DeepEqual(nested, expected) {
+		t.Errorf("Expected %v, got %v", expected, nested)
+	}
+}
+
+const outerWorkflowCWL = `cwlVersion: v1.2
+class: Workflow
+requirements:
+  - class: SubworkflowFeatureRequirement
+  - class: StepInputExpressionRequirement
+inputs:
+  name:
+    type: string?
+outputs:
+  greeting:
+    type: File
+    outputSource: inner/greeting
+steps:
+  inner:
+    run: inner.cwl
+    in:
+      message:
+        source: name
+        default: world
+        valueFrom: hello $(self)
+    out: [greeting]
+`
+
+const innerWorkflowCWL = `cwlVersion: v1.2
+class: Workflow
+inputs:
+  message:
+    type: string
+outputs:
+  greeting:
+    type: File
+    outputSource: echo/output
+steps:
+  echo:
+    run: echo.cwl
+    in:
+      message: message
+    out: [output]
+`
+
+func TestExecuteSubworkflow(t *testing.T) {
+	enterTempDir(t)
+
+	dir := writeWorkflowFiles(t, map[string]string{
+		"echo.cwl":  echoToolCWL,
+		"inner.cwl": innerWorkflowCWL,
+		"outer.cwl": outerWorkflowCWL,
+	})
+
+	wf, err := NewParser().ParseWorkflowFile(filepath.Join(dir, "outer.cwl"))
+	if err != nil {
+		t.Fatalf("Failed to parse workflow: %v", err)
+	}
+	if _, ok := wf.Steps["inner"].Process.(*Workflow); !ok {
+		t.Fatalf("Expected step to run a Workflow, got %T", wf.Steps["inner"].Process)
+	}
+
+	// The null input falls back to the default before valueFrom is applied
+	result, err := NewExecutor().ExecuteWorkflow(context.Background(), wf, map[string]interface{}{})
+	if err != nil {
+		t.Fatalf("Failed to execute workflow: %v", err)
+	}
+
+	greeting, ok := result.Outputs["greeting"].(map[string]interface{})
+	if !ok {
+		t.Fatalf("Expected greeting File, got %v", result.Outputs["greeting"])
+	}
+	data, err := os.ReadFile(greeting["path"].(string))
+	if err != nil {
+		t.Fatalf("Failed to read output: %v", err)
+	}
+	if strings.TrimSpace(string(data)) != "hello world" {
+		t.Errorf("Expected %q, got %q", "hello world", data)
+	}
+}
+
+func TestGatherSources(t *testing.T) {
+	values := map[string]interface{}{
+		"a":      []interface{}{1, 2},
+		"b":      3,
+		"step/c": nil,
+	}
+
+	tests := []struct {
+		name      string
+		sources   []string
+		linkMerge string
+		pickValue string
+		expected  interface{}
+	}{
+		{name: "Single source", sources: []string{"a"}, expected: []interface{}{1, 2}},
+		{name: "Single source nested", sources: []string{"b"}, linkMerge: MergeNested, expected: []interface{}{3}},
+		{name: "Default merge_nested", sources: []string{"a", "b"}, expected: []interface{}{[]interface{}{1, 2}, 3}},
+		{name: "merge_flattened", sources: []string{"a", "b"}, linkMerge: MergeFlattened, expected: []interface{}{1, 2, 3}},
+		{name: "Flattened then picked", sources: []string{"step/c", "a"}, linkMerge: MergeFlattened, pickValue: PickFirstNonNull, expected: 1},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := gatherSources(tt.sources, values, tt.linkMerge, tt.pickValue)
+			if err != nil {
+				t.Fatalf("Failed to gather sources: %v", err)
+			}
+			if !reflect.DeepEqual(got, tt.expected) {
+				t.Errorf("Expected %v, got %v", tt.expected, got)
+			}
+		})
+	}
+}
+
+func TestResolveStepInputsMultipleSources(t *testing.T) {
+	values := map[string]interface{}{"a": 1, "b": 2}
+	step := WorkflowStep{
+		In: map[string]WorkflowStepInput{
+			"merged": {Source: []interface{}{"a", "b"}},
+		},
+	}
+
+	// Multiple sources need MultipleInputFeatureRequirement
+	_, err := resolveStepInputs(&Workflow{}, "merge", step, values)
+	if err == nil || !strings.Contains(err.Error(), "MultipleInputFeatureRequirement") {
+		t.Errorf("Expected error for multiple sources without MultipleInputFeatureRequirement, got %v", err)
+	}
+
+	wf := &Workflow{Requirements: []map[string]interface{}{{"class": "MultipleInputFeatureRequirement"}}}
+	inputs, err := resolveStepInputs(wf, "merge", step, values)
+	if err != nil {
+		t.Fatalf("Failed to resolve step inputs: %v", err)
+	}
+	if expected := []interface{}{1, 2}; !reflect.DeepEqual(inputs["merged"], expected) {
+		t.Errorf("Expected %v, got %v", expected, inputs["merged"])
+	}
+}